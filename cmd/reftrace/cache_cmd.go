@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"reft-go/nf"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache of parsed modules",
+	Long: `reft lint and the Python bindings cache the modules they parse in
+reftrace/modules under the cache directory of the user, or under
+$` + nf.CacheDirEnv + `, so modules are only parsed again when they change.`,
+}
+
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove the cache of parsed modules",
+	Args:  cobra.NoArgs,
+	Run:   runCacheClean,
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheCleanCmd)
+}
+
+func runCacheClean(cmd *cobra.Command, args []string) {
+	dir, err := nf.CacheDir()
+	if err == nil {
+		err = nf.CleanCache(dir)
+	}
+	if err != nil {
+		color.New(color.FgRed).Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	color.New(color.FgGreen).Printf("Removed %s\n", dir)
+}
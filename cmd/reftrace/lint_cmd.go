@@ -1,23 +1,46 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"log"
 	"os"
+	"os/signal"
 	"reft-go/nf"
+	_ "reft-go/nf/configlint" // registers the built-in config checks
+	"reft-go/nf/corelint"
 	"reft-go/parser"
 	"strconv"
+	"time"
 
 	"github.com/antlr4-go/antlr/v4"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 	"go.starlark.net/starlark"
 )
 
 var (
-	rulesFile string
-	dir       string
-	ruleToRun string
+	rulesFile      string
+	dir            string
+	ruleToRun      string
+	format         string
+	outputFile     string
+	fix            bool
+	dryRun         bool
+	baselineFile   string
+	updateBaseline bool
+	rulesPath      []string
+	jobs           int
+	maxSteps       uint64
+	ruleTimeout    time.Duration
+	profileRules   bool
+	watch          bool
+	watchInterval  time.Duration
+	noCache        bool
+	changedSince   string
+	goRules        []string
 )
 
 var lintCmd = &cobra.Command{
@@ -32,6 +55,44 @@ func init() {
 	lintCmd.Flags().StringVarP(&rulesFile, "rules", "r", "rules.py", "Path to the rules file")
 	lintCmd.Flags().StringVarP(&dir, "directory", "d", ".", "Directory to lint")
 	lintCmd.Flags().StringVarP(&ruleToRun, "name", "n", "", "Name of a single rule to run")
+	lintCmd.Flags().IntVarP(&jobs, "jobs", "j", 0, "Number of rule calls to run in parallel (default: number of CPUs)")
+	lintCmd.Flags().Uint64Var(&maxSteps, "max-steps", 0, "Maximum number of Starlark steps of a rule on one file (0 for no limit)")
+	lintCmd.Flags().DurationVar(&ruleTimeout, "rule-timeout", time.Minute, "Maximum time a rule may run on one file (0 for no limit)")
+	lintCmd.Flags().BoolVar(&profileRules, "profile-rules", false, "Print the time and steps each rule took")
+	lintCmd.Flags().StringSliceVar(&rulesPath, "rules-path", nil, "Directories to look for modules loaded by the rules file in")
+	addOutputFlags(lintCmd)
+	addFixFlags(lintCmd)
+	lintCmd.Flags().StringVar(&baselineFile, "baseline", "", "Only report problems not recorded in this baseline file")
+	lintCmd.Flags().BoolVar(&updateBaseline, "update-baseline", false, "Record the current problems in the baseline file")
+	lintCmd.Flags().BoolVarP(&watch, "watch", "w", false, "Lint again whenever a module, a config file or the rules change")
+	lintCmd.Flags().DurationVar(&watchInterval, "watch-interval", nf.DefaultWatchInterval, "How often --watch looks for changes")
+	lintCmd.Flags().StringSliceVar(&goRules, "go-rules", nil, "Go rules to run besides the rules file, by ID, category or tag (all for every rule)")
+	lintCmd.Flags().StringVar(&changedSince, "changed-since", "", "Only report problems on lines changed since this git ref")
+	lintCmd.Flags().BoolVar(&noCache, "no-cache", false, "Parse every module, without the cache of parsed modules")
+}
+
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&format, "format", "f", "text", "Output format: text, json, sarif, junit, checkstyle or github")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write the output to a file instead of stdout")
+}
+
+func addFixFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&fix, "fix", false, "Apply the fixes suggested by the rules")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "With --fix, print the fixes as a unified diff instead of applying them")
+}
+
+// openOutput returns where to write the lint output and a function to close it
+func openOutput() (io.Writer, func() error, error) {
+	if outputFile == "" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(outputFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	// no escape codes in files
+	color.NoColor = true
+	return f, f.Close, nil
 }
 
 type StarlarkParamInfo struct {
@@ -109,12 +170,57 @@ func parse(filePath string) ([]nf.ParamInfo, []nf.IncludeStatement) {
 }
 
 func runLint(cmd *cobra.Command, args []string) {
+	outputFormat, err := nf.ParseFormat(format)
+	if err != nil {
+		log.Fatalf("Linting failed: %v", err)
+	}
+	output, closeOutput, err := openOutput()
+	if err != nil {
+		log.Fatalf("Linting failed: %v", err)
+	}
 	config := nf.LintConfig{
-		RulesFile: rulesFile,
-		Directory: dir,
-		RuleToRun: ruleToRun,
+		RulesFile:      rulesFile,
+		Directory:      dir,
+		RuleToRun:      ruleToRun,
+		Format:         outputFormat,
+		Fix:            fix,
+		DryRun:         dryRun,
+		Baseline:       baselineFile,
+		UpdateBaseline: updateBaseline,
+		RulesPath:      rulesPath,
+		Jobs:           jobs,
+		MaxSteps:       maxSteps,
+		RuleTimeout:    ruleTimeout,
+		ProfileRules:   profileRules,
+		WatchInterval:  watchInterval,
+		ChangedSince:   changedSince,
+		Warnings:       os.Stderr,
+	}
+	if dryRun && !fix {
+		log.Fatalf("Linting failed: --dry-run requires --fix")
+	}
+	if !noCache {
+		config.Cache = nf.NewModuleCache(version)
+	}
+	if len(goRules) > 0 {
+		rules, err := corelint.Select(goRules)
+		if err != nil {
+			log.Fatalf("Linting failed: %v", err)
+		}
+		config.BuiltinRules = corelint.Builtins(rules)
+		// like reft lint nfcore, so both report the same problems
+		config.LoadSettings, config.SettingsFiles = corelint.LoadSettings, corelint.NFCoreFiles
+	}
+	if watch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		err = nf.WatchLint(ctx, config, output)
+	} else {
+		err = nf.RunLintWithConfig(config, output)
+	}
+	if closeErr := closeOutput(); closeErr != nil && err == nil {
+		err = closeErr
 	}
-	err := nf.RunLintWithConfig(config, os.Stdout)
 	if err != nil {
 		log.Fatalf("Linting failed: %v", err)
 	}
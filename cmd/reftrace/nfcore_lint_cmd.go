@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"reft-go/nf"
 	"reft-go/nf/corelint"
 	"sort"
 
@@ -19,15 +21,79 @@ var nfcoreCmd = &cobra.Command{
 func init() {
 	lintCmd.AddCommand(nfcoreCmd)
 	nfcoreCmd.Flags().StringVarP(&dir, "directory", "d", ".", "Directory to lint")
+	addOutputFlags(nfcoreCmd)
+	addFixFlags(nfcoreCmd)
 }
 
 func runNFCoreLint(cmd *cobra.Command, args []string) {
+	outputFormat, err := nf.ParseFormat(format)
+	if err != nil {
+		color.New(color.FgRed).Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	if dryRun && !fix {
+		color.New(color.FgRed).Printf("Error: --dry-run requires --fix\n")
+		os.Exit(1)
+	}
+
 	results, err := corelint.NFCoreLint(dir)
 	if err != nil {
 		color.New(color.FgRed).Printf("Error: %s\n", err)
 		os.Exit(1)
 	}
 
+	output, closeOutput, err := openOutput()
+	if err != nil {
+		color.New(color.FgRed).Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	var hasErrors bool
+	if fix {
+		err = fixNFCoreResults(output, results, outputFormat)
+	}
+	// the diff is the only output of a dry run, it fails like reft lint
+	// if errors would be left after the fixes
+	if err == nil && dryRun {
+		hasErrors = corelint.HasErrors(results)
+	} else if err == nil {
+		if outputFormat == nf.FormatText {
+			hasErrors = printNFCoreResults(output, results)
+		} else {
+			grouped := corelint.ToGroupedOutput(results)
+			hasErrors, err = nf.WriteReport(output, outputFormat, grouped)
+			if note := nf.DroppedOutputNote(outputFormat, grouped); note != "" {
+				fmt.Fprintln(os.Stderr, note)
+			}
+		}
+	}
+	if closeErr := closeOutput(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		color.New(color.FgRed).Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	if hasErrors {
+		os.Exit(1)
+	}
+}
+
+// fixNFCoreResults applies the fixes of the results, and drops the fixed
+// problems from them. With --dry-run it writes the diff instead.
+func fixNFCoreResults(output io.Writer, results []corelint.LintResults, outputFormat nf.Format) error {
+	fixed, err := corelint.FixResults(results, dryRun, output)
+	if err != nil || dryRun {
+		return err
+	}
+	if outputFormat == nf.FormatText {
+		nf.PrintFixSummary(output, fixed)
+	}
+	return nil
+}
+
+func printNFCoreResults(output io.Writer, results []corelint.LintResults) bool {
 	errorPrinter := color.New(color.FgRed)
 	warningPrinter := color.New(color.FgYellow)
 	pathPrinter := color.New(color.FgCyan)
@@ -42,22 +108,20 @@ func runNFCoreLint(cmd *cobra.Command, args []string) {
 	// Print warnings and errors for each module
 	for _, result := range results {
 		if len(result.Warnings) > 0 || len(result.Errors) > 0 {
-			fmt.Printf("\nModule: %s\n", result.ModulePath)
+			fmt.Fprintf(output, "\nModule: %s\n", result.ModulePath)
 		}
 
 		for _, warning := range result.Warnings {
-			pathPrinter.Printf("  • Line %d\n", warning.Line)
-			warningPrinter.Printf("    %s\n", warning.Warning)
+			pathPrinter.Fprintf(output, "  • Line %d\n", warning.Line)
+			warningPrinter.Fprintf(output, "    %s\n", warning.Warning)
 		}
 
 		for _, err := range result.Errors {
 			hasErrors = true
-			pathPrinter.Printf("  • Line %d\n", err.Line)
-			errorPrinter.Printf("    %s\n", err.Error.Error())
+			pathPrinter.Fprintf(output, "  • Line %d\n", err.Line)
+			errorPrinter.Fprintf(output, "    %s\n", err.Error.Error())
 		}
 	}
 
-	if hasErrors {
-		os.Exit(1)
-	}
+	return hasErrors
 }
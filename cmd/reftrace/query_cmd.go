@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"reft-go/nf"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query <selector>",
+	Short: "Find processes, directives, workflow calls and more with a selector",
+	Long: `Find the parts of a pipeline matching a selector, like in CSS:
+
+  reft query 'process[label="process_high"] > directive[name=container]'
+  reft query 'workflow call[target=FASTQC]'
+  reft query 'input[type=path], output[type=path]'
+
+The kinds are module, process, directive, input, output, workflow, call,
+include, param and function, or * for any. A space selects descendants
+and > selects children. Attributes are matched with [attr], [attr=v],
+[attr!=v], [attr^=v], [attr$=v], [attr*=v] and [attr~=regexp].
+
+Exits with 1 if nothing matches.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runQuery,
+}
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+	queryCmd.Flags().StringVarP(&dir, "directory", "d", ".", "Directory to search")
+	queryCmd.Flags().StringVarP(&format, "format", "f", "text", "Output format: text or json")
+}
+
+func runQuery(cmd *cobra.Command, args []string) {
+	query, err := nf.ParseQuery(args[0])
+	if err != nil {
+		color.New(color.FgRed).Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	outputFormat, err := nf.ParseFormat(format)
+	if err != nil {
+		color.New(color.FgRed).Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	modules, parseErrors, err := nf.ParseDirectory(dir)
+	if err != nil {
+		color.New(color.FgRed).Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	// the query runs on the modules that parsed
+	for _, parseErr := range parseErrors {
+		d := parseErr.Diagnostic()
+		color.New(color.FgRed).Fprintf(os.Stderr, "Error: %s: %s\n", d.Location(), d.Message)
+	}
+
+	matches := query.Select(nf.QueryModel(modules))
+	if err := nf.WriteQueryResults(os.Stdout, outputFormat, matches); err != nil {
+		color.New(color.FgRed).Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	if len(matches) == 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reft-go/nf"
+	"reft-go/nf/corelint"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "List the rules and show their documentation",
+	Long: `List the rules reft lint knows about: the rule_, configrule_ and
+project_rule_ functions of the rules file, and the rules written in Go.
+
+The rules of the rules file are documented by the docstring of their
+function. Its first line is the description and the rest of it is what
+reft rules explain shows, ideally with examples of what the rule reports
+and what it accepts:
+
+  def rule_has_container(module):
+      """Processes must set a container
+
+      Bad:
+
+          process FOO {}
+
+      Good:
+
+          process FOO { container 'ubuntu:24.04' }
+      """`,
+}
+
+var rulesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List every rule with its severity, description and source",
+	Args:  cobra.NoArgs,
+	Run:   runRulesList,
+}
+
+var rulesExplainCmd = &cobra.Command{
+	Use:   "explain <rule>",
+	Short: "Show the documentation of a rule",
+	Args:  cobra.ExactArgs(1),
+	Run:   runRulesExplain,
+}
+
+func init() {
+	rootCmd.AddCommand(rulesCmd)
+	rulesCmd.AddCommand(rulesListCmd, rulesExplainCmd)
+	for _, cmd := range []*cobra.Command{rulesListCmd, rulesExplainCmd} {
+		cmd.Flags().StringVarP(&rulesFile, "rules", "r", "rules.py", "Path to the rules file")
+		cmd.Flags().StringVarP(&dir, "directory", "d", ".", "Directory whose settings apply")
+		cmd.Flags().StringSliceVar(&rulesPath, "rules-path", nil, "Directories to look for modules loaded by the rules file in")
+	}
+	rulesListCmd.Flags().StringVarP(&format, "format", "f", "text", "Output format: text or json")
+}
+
+// listRules returns every rule. Without --rules, a missing rules.py only
+// leaves out the rules of the rules file.
+func listRules(cmd *cobra.Command) ([]nf.RuleInfo, error) {
+	config := nf.LintConfig{
+		RulesFile:    rulesFile,
+		Directory:    dir,
+		RulesPath:    rulesPath,
+		BuiltinRules: corelint.Builtins(corelint.Rules()),
+		// the corelint rules are configured by .nf-core.yml too
+		LoadSettings: corelint.LoadSettings,
+		Warnings:     os.Stderr,
+	}
+	if _, err := os.Stat(rulesFile); os.IsNotExist(err) && !cmd.Flags().Changed("rules") {
+		config.RulesFile = ""
+	}
+	return nf.ListRules(config)
+}
+
+func runRulesList(cmd *cobra.Command, args []string) {
+	outputFormat, err := nf.ParseFormat(format)
+	if err != nil {
+		color.New(color.FgRed).Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	rules, err := listRules(cmd)
+	if err != nil {
+		color.New(color.FgRed).Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	if err := nf.WriteRuleList(os.Stdout, outputFormat, rules); err != nil {
+		color.New(color.FgRed).Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func runRulesExplain(cmd *cobra.Command, args []string) {
+	rules, err := listRules(cmd)
+	if err != nil {
+		color.New(color.FgRed).Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+	found := nf.FindRules(rules, args[0])
+	if len(found) == 0 {
+		color.New(color.FgRed).Printf("Error: no rule named %s, see reft rules list\n", args[0])
+		os.Exit(1)
+	}
+	for i, rule := range found {
+		if i > 0 {
+			fmt.Println()
+		}
+		nf.WriteRuleDoc(os.Stdout, rule)
+	}
+}
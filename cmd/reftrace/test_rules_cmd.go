@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"reft-go/nf"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	golden       bool
+	updateGolden bool
+)
+
+var testRulesCmd = &cobra.Command{
+	Use:   "test-rules [fixtures]",
+	Short: "Test the rules of a rules file on fixtures with expectation comments",
+	Long: `Run the rules of a rules file on the modules and config files of a
+fixtures directory (default: tests), and report the diagnostics they
+expect but don't get and the ones they get but don't expect.
+
+A fixture expects a diagnostic with a comment on its line, or on the
+line before. The message in quotes is optional, it needs to be part of
+the message of the diagnostic:
+
+  process FOO { // expect: rule_no_labels "has no labels"
+
+  // expect: rule_container_tag "latest"
+  container 'ubuntu:latest'
+
+With --golden, all the output of the rules on a fixture is compared to
+the fixture's .golden file too, --update writes them.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runTestRules,
+}
+
+func init() {
+	rootCmd.AddCommand(testRulesCmd)
+	testRulesCmd.Flags().StringVarP(&rulesFile, "rules", "r", "rules.py", "Path to the rules file")
+	testRulesCmd.Flags().StringSliceVar(&rulesPath, "rules-path", nil, "Directories to look for modules loaded by the rules file in")
+	testRulesCmd.Flags().BoolVar(&golden, "golden", false, "Compare all the output on each fixture to its .golden file")
+	testRulesCmd.Flags().BoolVar(&updateGolden, "update", false, "Write the .golden files of the fixtures")
+}
+
+func runTestRules(cmd *cobra.Command, args []string) {
+	fixtures := "tests"
+	if len(args) > 0 {
+		fixtures = args[0]
+	}
+	err := nf.RunRuleTests(nf.RuleTestConfig{
+		RulesFile:    rulesFile,
+		Fixtures:     fixtures,
+		RulesPath:    rulesPath,
+		Golden:       golden,
+		UpdateGolden: updateGolden,
+	}, os.Stdout)
+	if err != nil {
+		color.New(color.FgRed).Printf("Error: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -4,19 +4,20 @@ go 1.22
 
 require (
 	github.com/antlr4-go/antlr/v4 v4.13.1
+	github.com/dlclark/regexp2 v1.10.0
 	github.com/fatih/color v1.17.0
 	github.com/magnetde/starlark-re v0.1.1
 	github.com/spf13/cobra v1.8.1
 	go.starlark.net v0.0.0-20240725214946-42030a7cedce
+	golang.org/x/sys v0.21.0
 	google.golang.org/protobuf v1.33.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/dlclark/regexp2 v1.10.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	golang.org/x/exp v0.0.0-20240604190554-fc45aab8b7f8 // indirect
-	golang.org/x/sys v0.21.0 // indirect
 )
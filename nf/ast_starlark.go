@@ -0,0 +1,196 @@
+package nf
+
+import (
+	"fmt"
+	"reflect"
+	"reft-go/parser"
+	"sort"
+
+	"go.starlark.net/starlark"
+)
+
+/*
+astChildren returns the nodes directly under node, in the order the
+BaseVisitor visits them.
+
+The module node has the top-level statements and functions as children,
+a function its body.
+*/
+func astChildren(node parser.ASTNodeNoVisit) []parser.ASTNodeNoVisit {
+	var children []parser.ASTNodeNoVisit
+	add := func(child parser.ASTNodeNoVisit) {
+		if isNilNode(child) {
+			return
+		}
+		switch child.(type) {
+		case *parser.EmptyStatement, *parser.EmptyExpression:
+			return
+		}
+		children = append(children, child)
+	}
+
+	switch n := node.(type) {
+	case *parser.ModuleNode:
+		for _, statement := range n.StatementBlock.GetStatements() {
+			add(statement)
+		}
+		for _, method := range n.Methods {
+			add(method)
+		}
+		sort.SliceStable(children, func(i, j int) bool {
+			return children[i].GetLineNumber() < children[j].GetLineNumber()
+		})
+		return children
+	case *parser.MethodNode:
+		add(n.GetCode())
+		return children
+	}
+
+	v := NewBaseVisitor()
+	v.VisitExpressionHook = func(expr parser.Expression) { add(expr) }
+	v.VisitStatementHook = func(statement parser.Statement) { add(statement) }
+	switch n := node.(type) {
+	case parser.Expression:
+		n.Visit(v)
+	case parser.Statement:
+		n.Visit(v)
+	}
+	return children
+}
+
+func isNilNode(node parser.ASTNodeNoVisit) bool {
+	if node == nil {
+		return true
+	}
+	value := reflect.ValueOf(node)
+	return value.Kind() == reflect.Pointer && value.IsNil()
+}
+
+var _ starlark.Value = (*StarlarkNode)(nil)
+var _ starlark.HasAttrs = (*StarlarkNode)(nil)
+
+// StarlarkNode is a read-only view of any node of the AST
+type StarlarkNode struct {
+	Node parser.ASTNodeNoVisit
+}
+
+// newStarlarkNode returns None for a missing node
+func newStarlarkNode(node parser.ASTNodeNoVisit) starlark.Value {
+	if isNilNode(node) {
+		return starlark.None
+	}
+	return &StarlarkNode{node}
+}
+
+// Kind is the name of the type of the node, like MethodCallExpression
+func (n *StarlarkNode) Kind() string {
+	t := reflect.TypeOf(n.Node)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+func (n *StarlarkNode) String() string {
+	return fmt.Sprintf("Node(%s, %d:%d)", n.Kind(), n.Node.GetLineNumber(), n.Node.GetColumnNumber())
+}
+
+func (n *StarlarkNode) Type() string {
+	return "node"
+}
+
+func (n *StarlarkNode) Freeze() {}
+
+func (n *StarlarkNode) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (n *StarlarkNode) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: node")
+}
+
+func (n *StarlarkNode) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "kind":
+		return starlark.String(n.Kind()), nil
+	case "children":
+		children := astChildren(n.Node)
+		values := make([]starlark.Value, len(children))
+		for i, child := range children {
+			values[i] = &StarlarkNode{child}
+		}
+		return starlark.NewList(values), nil
+	case "text":
+		return starlark.String(n.Node.GetText()), nil
+	case "name":
+		// the name of calls, variables, functions and parameters
+		switch node := n.Node.(type) {
+		case *parser.MethodCallExpression:
+			return starlark.String(node.GetMethodAsString()), nil
+		case *parser.VariableExpression:
+			return starlark.String(node.GetName()), nil
+		case *parser.MethodNode:
+			return starlark.String(node.GetName()), nil
+		}
+		return starlark.None, nil
+	case "value":
+		if constant, ok := n.Node.(*parser.ConstantExpression); ok {
+			if value, ok := constantToStarlark(constant.GetValue()); ok {
+				return value, nil
+			}
+		}
+		return starlark.None, nil
+	case "line":
+		return starlark.MakeInt(n.Node.GetLineNumber()), nil
+	case "column":
+		return starlark.MakeInt(n.Node.GetColumnNumber()), nil
+	case "last_line":
+		return starlark.MakeInt(n.Node.GetLastLineNumber()), nil
+	case "last_column":
+		return starlark.MakeInt(n.Node.GetLastColumnNumber()), nil
+	case "span":
+		return &StarlarkSpan{SpanOf(n.Node)}, nil
+	default:
+		return nil, starlark.NoSuchAttrError(fmt.Sprintf("node has no attribute %q", name))
+	}
+}
+
+func (n *StarlarkNode) AttrNames() []string {
+	return []string{"kind", "children", "text", "name", "value", "line", "column", "last_line", "last_column", "span"}
+}
+
+/*
+walkFunc implements walk():
+
+	walk(node, fn)
+
+fn is called with node and every node under it, parents before their
+children. When fn returns False, the children of the node are skipped.
+*/
+func walkFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var node *StarlarkNode
+	var fn starlark.Callable
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &node, &fn); err != nil {
+		return nil, err
+	}
+	var walk func(parser.ASTNodeNoVisit) error
+	walk = func(current parser.ASTNodeNoVisit) error {
+		result, err := starlark.Call(thread, fn, starlark.Tuple{&StarlarkNode{current}}, nil)
+		if err != nil {
+			return err
+		}
+		if result == starlark.False {
+			return nil
+		}
+		for _, child := range astChildren(current) {
+			if err := walk(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(node.Node); err != nil {
+		return nil, err
+	}
+	return starlark.None, nil
+}
@@ -14,6 +14,7 @@ type BaseVisitor struct {
 	VisitPropertyExpressionHook   func(expr *parser.PropertyExpression)
 	VisitExpressionHook           func(expr parser.Expression)
 	VisitBlockStatementHook       func(block *parser.BlockStatement)
+	VisitStatementHook            func(statement parser.Statement)
 }
 
 // NewBaseVisitor creates a new BaseVisitor
@@ -114,6 +115,10 @@ func (v *BaseVisitor) VisitCatchStatement(statement *parser.CatchStatement) {
 func (v *BaseVisitor) VisitEmptyStatement(statement *parser.EmptyStatement) {}
 
 func (v *BaseVisitor) VisitStatement(statement parser.Statement) {
+	if v.VisitStatementHook != nil {
+		v.VisitStatementHook(statement)
+		return
+	}
 	statement.Visit(v)
 }
 
@@ -0,0 +1,213 @@
+package nf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const baselineVersion = 1
+
+/*
+A Baseline records the diagnostics of a pipeline so that later runs only
+report new ones.
+
+Diagnostics are identified by a fingerprint of the rule, the file relative
+to the linted directory, the process the diagnostic is in and the message
+with numbers and whitespace normalized. Line numbers are left out so
+the baseline survives unrelated edits. A fingerprint recorded n times
+hides up to n matching diagnostics.
+*/
+type Baseline struct {
+	Version int             `json:"version"`
+	Entries []BaselineEntry `json:"entries"`
+}
+
+type BaselineEntry struct {
+	Fingerprint string `json:"fingerprint"`
+	Rule        string `json:"rule"`
+	Path        string `json:"path"`
+	Process     string `json:"process,omitempty"`
+	Message     string `json:"message"`
+	Count       int    `json:"count"`
+}
+
+var (
+	numberPattern     = regexp.MustCompile(`\d+(\.\d+)?`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+func normalizeMessage(message string) string {
+	message = numberPattern.ReplaceAllString(message, "#")
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(message, " "))
+}
+
+// baselineContext resolves the parts of a fingerprint that depend on the pipeline
+type baselineContext struct {
+	root    string
+	modules map[string]*Module
+}
+
+func newBaselineContext(root string, modules []*Module) *baselineContext {
+	byPath := make(map[string]*Module)
+	for _, module := range modules {
+		byPath[module.Path] = module
+	}
+	return &baselineContext{root: root, modules: byPath}
+}
+
+func (c *baselineContext) relPath(path string) string {
+	if rel, err := filepath.Rel(c.root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+// processAt returns the name of the process whose definition spans line
+func (c *baselineContext) processAt(path string, line int) string {
+	module, ok := c.modules[path]
+	if !ok || line == 0 {
+		return ""
+	}
+	for _, process := range module.Processes {
+		if process.Closure == nil {
+			continue
+		}
+		if line >= process.Closure.GetLineNumber() && line <= process.Closure.GetLastLineNumber() {
+			return process.Name
+		}
+	}
+	return ""
+}
+
+func (c *baselineContext) entry(rule string, d Diagnostic) BaselineEntry {
+	entry := BaselineEntry{
+		Rule:    rule,
+		Path:    c.relPath(d.Path),
+		Process: c.processAt(d.Path, d.Line),
+		Message: normalizeMessage(d.Message),
+	}
+	if d.Code != "" {
+		entry.Message = d.Code + ": " + entry.Message
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{entry.Rule, entry.Path, entry.Process, entry.Message}, "\x00")))
+	entry.Fingerprint = hex.EncodeToString(sum[:8])
+	return entry
+}
+
+// NewBaseline records the diagnostics in output.
+func NewBaseline(output GroupedOutput, root string, modules []*Module) *Baseline {
+	c := newBaselineContext(root, modules)
+	entries := make(map[string]*BaselineEntry)
+	for rule, byPath := range output {
+		for _, result := range byPath {
+			for _, d := range result.Diagnostics {
+				entry := c.entry(rule, d)
+				if existing, ok := entries[entry.Fingerprint]; ok {
+					existing.Count++
+					continue
+				}
+				entry.Count = 1
+				entries[entry.Fingerprint] = &entry
+			}
+		}
+	}
+
+	baseline := &Baseline{Version: baselineVersion, Entries: []BaselineEntry{}}
+	for _, entry := range entries {
+		baseline.Entries = append(baseline.Entries, *entry)
+	}
+	sort.Slice(baseline.Entries, func(i, j int) bool {
+		a, b := baseline.Entries[i], baseline.Entries[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+	return baseline
+}
+
+func LoadBaseline(path string) (*Baseline, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var baseline Baseline
+	if err := json.Unmarshal(content, &baseline); err != nil {
+		return nil, fmt.Errorf("invalid baseline file %s: %v", path, err)
+	}
+	if baseline.Version != baselineVersion {
+		return nil, fmt.Errorf("unsupported baseline version %d in %s", baseline.Version, path)
+	}
+	return &baseline, nil
+}
+
+func (b *Baseline) Save(path string) error {
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// Total is the number of diagnostics in the baseline.
+func (b *Baseline) Total() int {
+	total := 0
+	for _, entry := range b.Entries {
+		total += entry.Count
+	}
+	return total
+}
+
+// Filter removes the diagnostics recorded in the baseline from output
+// and returns how many were removed.
+func (b *Baseline) Filter(output GroupedOutput, root string, modules []*Module) int {
+	remaining := make(map[string]int)
+	for _, entry := range b.Entries {
+		remaining[entry.Fingerprint] += entry.Count
+	}
+	c := newBaselineContext(root, modules)
+
+	// Visit the diagnostics in a stable order so the same ones are
+	// hidden on every run when a fingerprint occurs more often than recorded
+	rules := make([]string, 0, len(output))
+	for rule := range output {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+
+	removed := 0
+	for _, rule := range rules {
+		byPath := output[rule]
+		paths := make([]string, 0, len(byPath))
+		for path := range byPath {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			result := byPath[path]
+			kept := result.Diagnostics[:0]
+			for _, d := range result.Diagnostics {
+				fingerprint := c.entry(rule, d).Fingerprint
+				if remaining[fingerprint] > 0 {
+					remaining[fingerprint]--
+					removed++
+					continue
+				}
+				kept = append(kept, d)
+			}
+			result.Diagnostics = kept
+			byPath[path] = result
+		}
+	}
+	return removed
+}
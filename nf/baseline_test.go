@@ -0,0 +1,113 @@
+package nf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBaselineFilter(t *testing.T) {
+	root := "/pipeline"
+	recorded := GroupedOutput{
+		"max_cpus": {
+			"/pipeline/main.nf": {Diagnostics: []Diagnostic{
+				{Path: "/pipeline/main.nf", Line: 3, Severity: SeverityError, Message: "cpus 16 is more than 8"},
+				{Path: "/pipeline/main.nf", Line: 9, Severity: SeverityError, Message: "cpus 32 is more than 8"},
+			}},
+		},
+	}
+	baseline := NewBaseline(recorded, root, nil)
+	if len(baseline.Entries) != 1 || baseline.Entries[0].Count != 2 || baseline.Entries[0].Path != "main.nf" {
+		t.Fatalf("Expected one entry recorded twice, got %+v", baseline.Entries)
+	}
+
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := baseline.Save(path); err != nil {
+		t.Fatal("Failed to save baseline:", err)
+	}
+	loaded, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatal("Failed to load baseline:", err)
+	}
+
+	// The lines moved and a third violation was added
+	current := GroupedOutput{
+		"max_cpus": {
+			"/pipeline/main.nf": {Diagnostics: []Diagnostic{
+				{Path: "/pipeline/main.nf", Line: 5, Severity: SeverityError, Message: "cpus 16 is more than 8"},
+				{Path: "/pipeline/main.nf", Line: 11, Severity: SeverityError, Message: "cpus 32 is more than 8"},
+				{Path: "/pipeline/main.nf", Line: 20, Severity: SeverityError, Message: "cpus 64 is more than 8"},
+			}},
+			"/pipeline/other.nf": {Diagnostics: []Diagnostic{
+				{Path: "/pipeline/other.nf", Line: 5, Severity: SeverityError, Message: "cpus 16 is more than 8"},
+			}},
+		},
+	}
+	if removed := loaded.Filter(current, root, nil); removed != 2 {
+		t.Errorf("Expected 2 diagnostics to be filtered, got %d", removed)
+	}
+	if remaining := current["max_cpus"]["/pipeline/main.nf"].Diagnostics; len(remaining) != 1 || remaining[0].Line != 20 {
+		t.Errorf("Expected only the new diagnostic in main.nf to remain, got %+v", remaining)
+	}
+	if remaining := current["max_cpus"]["/pipeline/other.nf"].Diagnostics; len(remaining) != 1 {
+		t.Errorf("Expected the diagnostic in another file to remain, got %+v", remaining)
+	}
+}
+
+func TestLintBaseline(t *testing.T) {
+	rulesContent := `
+def rule_max_cpus(module):
+    for process in module.processes:
+        for cpus in process.directives.cpus:
+            if cpus.num > 8:
+                error("too many cpus", line=cpus.line)
+`
+	processContent := `
+process FOO {
+    cpus 16
+    script:
+    """
+    echo "test"
+    """
+}
+`
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	processFile := filepath.Join(tmpDir, "process.nf")
+	baselineFile := filepath.Join(tmpDir, "reftrace-baseline.json")
+	if err := os.WriteFile(rulesFile, []byte(rulesContent), 0644); err != nil {
+		t.Fatal("Failed to write rules file:", err)
+	}
+	if err := os.WriteFile(processFile, []byte(processContent), 0644); err != nil {
+		t.Fatal("Failed to write process file:", err)
+	}
+
+	var output strings.Builder
+	config := LintConfig{RulesFile: rulesFile, Directory: processFile, Baseline: baselineFile}
+	if err := RunLintWithConfig(config, &output); err == nil {
+		t.Fatal("Expected a missing baseline file to fail")
+	}
+
+	config.UpdateBaseline = true
+	if err := RunLintWithConfig(config, &output); err != nil {
+		t.Fatal("Failed to update baseline:", err)
+	}
+
+	// Shift the process down and add a new one
+	shifted := "\n\n" + processContent + strings.ReplaceAll(processContent, "FOO", "BAR")
+	if err := os.WriteFile(processFile, []byte(shifted), 0644); err != nil {
+		t.Fatal("Failed to write process file:", err)
+	}
+	output.Reset()
+	config.UpdateBaseline = false
+	if err := RunLintWithConfig(config, &output); err == nil {
+		t.Fatal("Expected the new violation to fail the lint")
+	}
+	if !strings.Contains(output.String(), processFile+":13: too many cpus") {
+		t.Errorf("Expected the new violation to be reported, got:\n%s", output.String())
+	}
+	if strings.Contains(output.String(), processFile+":5: too many cpus") {
+		t.Errorf("Expected the baselined violation to be hidden, got:\n%s", output.String())
+	}
+}
@@ -0,0 +1,25 @@
+package nf
+
+// BuiltinRule is a check written in Go that runs alongside the rule_*
+// functions of the rules file. Run returns the output for each file it
+// reports on, keyed by path.
+//
+// Packages that build on nf (e.g. configlint) register their rules from
+// init, so nf does not need to import them.
+type BuiltinRule struct {
+	Name string
+	Run  func(dir string, modules []*Module) (map[string]RuleModuleOutput, error)
+	// Info documents the rule for `reft rules`. Its ID and kind are set
+	// from the rule, and its source from Run if empty.
+	Info RuleInfo
+}
+
+var builtinRules []BuiltinRule
+
+func RegisterBuiltinRule(rule BuiltinRule) {
+	builtinRules = append(builtinRules, rule)
+}
+
+func BuiltinRules() []BuiltinRule {
+	return builtinRules
+}
@@ -0,0 +1,295 @@
+package nf
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	pb "reft-go/nf/proto"
+	"reft-go/parser"
+	"runtime/debug"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// cacheRevision is part of the keys of the cache. Bump it when a change to
+// the parser, to the snapshots or to pb.Module makes the cached entries
+// wrong.
+const cacheRevision = 2
+
+// CacheDirEnv overrides the directory the cache of parsed modules is kept
+// in, under reftrace/modules like the cache directory of the user
+const CacheDirEnv = "REFTRACE_CACHE_DIR"
+
+/*
+CacheDir returns where parsed modules are cached: reftrace/modules under
+$REFTRACE_CACHE_DIR, or under the cache directory of the user, so nothing
+is written to the directories that are parsed. The cache has a directory
+of its own, so cleaning it can't remove other files.
+*/
+func CacheDir() (string, error) {
+	base := os.Getenv(CacheDirEnv)
+	if base == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		base = dir
+	}
+	return filepath.Join(base, "reftrace", "modules"), nil
+}
+
+/*
+ModuleCache stores the results of parsing modules. An entry is keyed by
+the hash of cacheRevision, the version of RefTrace, the VCS revision it
+was built from, the path and the content of the module, so a module is
+only parsed again when it changes or RefTrace does.
+
+Entries hold the parse error of the modules that failed to parse. For the
+others they hold the parser.Snapshot lint builds the syntax tree its rules
+need from, which skips lexing and the failed SLL attempt, and the
+pb.Module the C API returns as is.
+
+The methods of a nil cache parse every module.
+*/
+type ModuleCache struct {
+	Dir     string
+	Version string
+}
+
+/*
+NewModuleCache returns the cache of the modules parsed by version of
+RefTrace, in CacheDir. It is nil, which parses every module, if there is
+no cache directory, or if RefTrace was built from a modified tree, whose
+parser may change without its revision changing.
+*/
+func NewModuleCache(version string) *ModuleCache {
+	dir, err := CacheDir()
+	if err != nil {
+		return nil
+	}
+	revision, modified := buildRevision()
+	if modified {
+		return nil
+	}
+	if revision != "" {
+		version += "+" + revision
+	}
+	return &ModuleCache{Dir: dir, Version: version}
+}
+
+// buildRevision returns the VCS revision RefTrace was built from, if it is
+// known, and whether the tree had local changes
+func buildRevision() (string, bool) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", false
+	}
+	var revision string
+	var modified bool
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	return revision, modified
+}
+
+// cacheEntryExt is the extension of the entries, CleanCache only removes
+// files with it and the temporary files of store
+const cacheEntryExt = ".gob"
+
+type cacheEntry struct {
+	// the marshaled pb.Module, empty if the module failed to parse
+	Module   []byte
+	Snapshot *parser.Snapshot
+	Error    *cachedError
+}
+
+type cachedError struct {
+	Message string
+	// Syntax is set for syntax errors, lint reports their position
+	Syntax    *parser.SyntaxException
+	LikelyBug bool
+}
+
+func (e *cachedError) err() error {
+	if e.Syntax != nil {
+		return e.Syntax
+	}
+	return errors.New(e.Message)
+}
+
+func newCacheEntry(module *Module, snapshot *parser.Snapshot, err error, likelyBug bool) (*cacheEntry, error) {
+	if err != nil {
+		cached := &cachedError{Message: err.Error(), LikelyBug: likelyBug}
+		if syntaxErr, ok := err.(*parser.SyntaxException); ok {
+			cached.Syntax = syntaxErr
+		}
+		return &cacheEntry{Error: cached}, nil
+	}
+	data, err := proto.Marshal(module.ToProto())
+	if err != nil {
+		return nil, err
+	}
+	return &cacheEntry{Module: data, Snapshot: snapshot}, nil
+}
+
+func (c *ModuleCache) entryPath(path string, content []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00", cacheRevision, c.Version, path)
+	h.Write(content)
+	return filepath.Join(c.Dir, hex.EncodeToString(h.Sum(nil))+cacheEntryExt)
+}
+
+func (c *ModuleCache) load(path string, content []byte) (*cacheEntry, bool) {
+	file, err := os.Open(c.entryPath(path, content))
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+	var entry cacheEntry
+	if err := gob.NewDecoder(bufio.NewReader(file)).Decode(&entry); err != nil {
+		return nil, false
+	}
+	return &entry, true
+}
+
+// store writes the entry, unless the module changed while it was parsed.
+// A cache that can't be written only makes parsing slower, so errors are
+// ignored.
+func (c *ModuleCache) store(path string, content []byte, entry *cacheEntry) {
+	if current, err := os.ReadFile(path); err != nil || !bytes.Equal(current, content) {
+		return
+	}
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(entry); err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return
+	}
+	// write to a temporary file first, parallel parsers may read the entry
+	tmp, err := os.CreateTemp(c.Dir, "entry-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.entryPath(path, content))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// parse builds the module at path and stores its entry
+func (c *ModuleCache) parse(path string, content []byte) (*Module, error, bool) {
+	ast, snapshot, err := parser.BuildASTSnapshot(path)
+	module, err, likelyBug := moduleFromAST(path, ast, err)
+	if entry, entryErr := newCacheEntry(module, snapshot, err, likelyBug); entryErr == nil {
+		c.store(path, content, entry)
+	}
+	return module, err, likelyBug
+}
+
+// buildModule is BuildModule, but the modules that didn't change since
+// they were last parsed are built from their snapshot.
+func (c *ModuleCache) buildModule(path string) (*Module, error, bool) {
+	if c == nil {
+		return BuildModule(path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return BuildModule(path)
+	}
+	if entry, ok := c.load(path, content); ok {
+		if entry.Error != nil {
+			return nil, entry.Error.err(), entry.Error.LikelyBug
+		}
+		if entry.Snapshot != nil {
+			// a snapshot that doesn't parse is parsed again below
+			if ast, err := parser.BuildASTFromSnapshot(path, content, entry.Snapshot); err == nil {
+				return moduleFromAST(path, ast, nil)
+			}
+		}
+	}
+	return c.parse(path, content)
+}
+
+// ParseModule returns the module at path as the C API returns it, from the
+// cache if it didn't change since it was last parsed.
+func (c *ModuleCache) ParseModule(path string) *pb.ModuleResult {
+	result := &pb.ModuleResult{FilePath: path}
+	content, readErr := os.ReadFile(path)
+	if readErr == nil {
+		if entry, ok := c.load(path, content); ok {
+			if entry.Error != nil {
+				result.Result = parseErrorResult(entry.Error.Message, entry.Error.LikelyBug)
+				return result
+			}
+			module := &pb.Module{}
+			if err := proto.Unmarshal(entry.Module, module); err == nil {
+				result.Result = &pb.ModuleResult_Module{Module: module}
+				return result
+			}
+		}
+	}
+
+	var module *Module
+	var err error
+	var likelyBug bool
+	if readErr == nil {
+		module, err, likelyBug = c.parse(path, content)
+	} else {
+		module, err, likelyBug = BuildModule(path)
+	}
+	if err != nil {
+		result.Result = parseErrorResult(err.Error(), likelyBug)
+	} else {
+		result.Result = &pb.ModuleResult_Module{Module: module.ToProto()}
+	}
+	return result
+}
+
+/*
+CleanCache removes the entries of the cache in dir, and dir if nothing
+else is left in it. Other files in dir are kept.
+*/
+func CleanCache(dir string) error {
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !(filepath.Ext(name) == cacheEntryExt || strings.HasPrefix(name, "entry-")) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	// fails if other files are left, which is fine
+	os.Remove(dir)
+	return nil
+}
+
+func parseErrorResult(message string, likelyBug bool) *pb.ModuleResult_Error {
+	return &pb.ModuleResult_Error{Error: &pb.ParseError{Error: message, LikelyRtBug: likelyBug}}
+}
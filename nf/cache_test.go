@@ -0,0 +1,151 @@
+package nf
+
+import (
+	"os"
+	"path/filepath"
+	"reft-go/parser"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const cachedProcess = "process FOO {\n    script:\n    \"\"\"\n    echo test\n    \"\"\"\n}\n"
+
+func TestModuleCacheParseErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	mainFile := filepath.Join(tmpDir, "main.nf")
+	writeFiles(t, map[string]string{mainFile: cachedProcess})
+
+	// the module parses, so the error can only come from the cache
+	cache := &ModuleCache{Dir: t.TempDir(), Version: "test"}
+	cache.store(mainFile, []byte(cachedProcess), &cacheEntry{Error: &cachedError{Message: "unexpected input", LikelyBug: true}})
+
+	result := cache.ParseModule(mainFile)
+	if err := result.GetError(); err == nil || err.Error != "unexpected input" || !err.LikelyRtBug {
+		t.Fatalf("Expected the cached parse error, got %v", result)
+	}
+
+	// another version or a change of the module parses it again
+	for _, cache := range []*ModuleCache{{Dir: cache.Dir, Version: "other"}, cache} {
+		if result := cache.ParseModule(mainFile); result.GetModule() == nil {
+			t.Errorf("Expected the module to be parsed again, got %v", result)
+		}
+		writeFiles(t, map[string]string{mainFile: cachedProcess + "\n"})
+	}
+}
+
+func TestModuleCacheParseModule(t *testing.T) {
+	tmpDir := t.TempDir()
+	mainFile := filepath.Join(tmpDir, "main.nf")
+	writeFiles(t, map[string]string{mainFile: cachedProcess})
+	base := t.TempDir()
+	t.Setenv(CacheDirEnv, base)
+	cacheDir := filepath.Join(base, "reftrace", "modules")
+
+	cache := NewModuleCache("test")
+	if cache == nil || cache.Dir != cacheDir {
+		t.Fatalf("Expected a cache in %s, got %+v", cacheDir, cache)
+	}
+	first := cache.ParseModule(mainFile)
+	if first.GetModule() == nil || len(first.GetModule().Processes) != 1 {
+		t.Fatalf("Expected a module with one process, got %v", first)
+	}
+	entries, _ := filepath.Glob(filepath.Join(cacheDir, "*"+cacheEntryExt))
+	if len(entries) != 1 {
+		t.Fatalf("Expected one cache entry, got %v", entries)
+	}
+	if second := cache.ParseModule(mainFile); !proto.Equal(first, second) {
+		t.Errorf("Expected the cached module %v, got %v", first, second)
+	}
+
+	// nothing is written to the parsed directory
+	files, _ := os.ReadDir(tmpDir)
+	if len(files) != 1 {
+		t.Errorf("Expected only main.nf in the parsed directory, got %v", files)
+	}
+}
+
+func TestModuleCacheLint(t *testing.T) {
+	tmpDir := t.TempDir()
+	mainFile := filepath.Join(tmpDir, "main.nf")
+	otherFile := filepath.Join(tmpDir, "other.nf")
+	writeFiles(t, map[string]string{mainFile: cachedProcess, otherFile: cachedProcess})
+
+	// main.nf parses, so its syntax error can only come from the cache
+	cache := &ModuleCache{Dir: t.TempDir(), Version: "test"}
+	syntaxErr := &parser.SyntaxException{Message: "unexpected input", StartLine: 2, StartCharPosition: 4, StopLine: 2, StopCharPosition: 8}
+	cache.store(mainFile, []byte(cachedProcess), &cacheEntry{Error: &cachedError{Message: syntaxErr.Error(), Syntax: syntaxErr}})
+
+	for run := 0; run < 2; run++ {
+		modules, parseErrors, err := ParseDirectoryCached(tmpDir, cache)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(parseErrors) != 1 || parseErrors[0].Path != mainFile || parseErrors[0].LikelyBug {
+			t.Fatalf("Expected the cached error of main.nf, got %v", parseErrors)
+		}
+		if got, ok := parseErrors[0].Err.(*parser.SyntaxException); !ok || *got != *syntaxErr {
+			t.Errorf("Expected the cached syntax error %v, got %#v", syntaxErr, parseErrors[0].Err)
+		}
+		if len(modules) != 1 || len(modules[0].Processes) != 1 {
+			t.Fatalf("Expected other.nf with one process, got %v", modules)
+		}
+	}
+
+	// other.nf is cached with the snapshot its AST is built from again
+	entry, ok := cache.load(otherFile, []byte(cachedProcess))
+	if !ok || entry.Snapshot == nil || len(entry.Snapshot.Tokens) == 0 || len(entry.Module) == 0 {
+		t.Fatalf("Expected an entry with the snapshot and the module of other.nf, got %+v", entry)
+	}
+	ast, err := parser.BuildASTFromSnapshot(otherFile, []byte(cachedProcess), entry.Snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ast.Source != cachedProcess {
+		t.Errorf("Expected the source of other.nf, got %q", ast.Source)
+	}
+}
+
+func TestCleanCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	mainFile := filepath.Join(tmpDir, "main.nf")
+	writeFiles(t, map[string]string{mainFile: cachedProcess})
+	base := t.TempDir()
+	t.Setenv(CacheDirEnv, base)
+	cacheDir, err := CacheDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := &ModuleCache{Dir: cacheDir, Version: "test"}
+	cache.store(mainFile, []byte(cachedProcess), &cacheEntry{Error: &cachedError{Message: "unexpected input"}})
+	unrelated := []string{filepath.Join(base, "notes.txt"), filepath.Join(cacheDir, "notes.txt")}
+	for _, path := range unrelated {
+		writeFiles(t, map[string]string{path: "keep me"})
+	}
+
+	if err := CleanCache(cacheDir); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := filepath.Glob(filepath.Join(cacheDir, "*"+cacheEntryExt)); len(entries) != 0 {
+		t.Errorf("Expected the entries to be removed, got %v", entries)
+	}
+	for _, path := range unrelated {
+		if data, err := os.ReadFile(path); err != nil || string(data) != "keep me" {
+			t.Errorf("Expected %s to be kept, got %q, %v", path, data, err)
+		}
+	}
+
+	// the directory of the cache is removed once it only holds entries
+	os.Remove(unrelated[1])
+	cache.store(mainFile, []byte(cachedProcess), &cacheEntry{Error: &cachedError{Message: "unexpected input"}})
+	if err := CleanCache(cacheDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cacheDir); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed, got %v", cacheDir, err)
+	}
+	if _, err := os.Stat(base); err != nil {
+		t.Errorf("Expected %s to be kept, got %v", base, err)
+	}
+}
@@ -0,0 +1,164 @@
+package nf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+ChangedLines are the lines of the modules and config files that changed
+since a git ref, by absolute path. Files git doesn't track yet changed on
+every line. Lines that were only deleted don't count, but their file does.
+*/
+type ChangedLines map[string]*changedFile
+
+type changedFile struct {
+	// set for new files
+	all bool
+	// first and last line of each change
+	ranges [][2]int
+}
+
+// the +start,count part of a hunk header, the count is 1 if left out
+var hunkPattern = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+/*
+GitChangedLines runs git in dir to find the lines changed since ref: the
+changes in commits after ref and the staged and unstaged ones. The
+untracked files that aren't ignored are changed too.
+*/
+func GitChangedLines(dir, ref string) (ChangedLines, error) {
+	diff, err := git(dir, "diff", "--unified=0", "--no-color", "--no-ext-diff", "--no-prefix", "--relative", ref, "--", "*.nf", "*.config")
+	if err != nil {
+		return nil, err
+	}
+	changed, err := parseGitDiff(dir, diff)
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := git(dir, "ls-files", "--others", "--exclude-standard", "-z", "--", "*.nf", "*.config")
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range strings.Split(string(untracked), "\x00") {
+		if path != "" {
+			changed[filepath.Join(dir, path)] = &changedFile{all: true}
+		}
+	}
+	return changed, nil
+}
+
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("git %s failed: %s", args[0], message)
+		}
+		return nil, fmt.Errorf("git %s failed: %v", args[0], err)
+	}
+	return out, nil
+}
+
+// parseGitDiff reads the output of git diff --unified=0 --no-prefix, with
+// paths relative to dir
+func parseGitDiff(dir string, diff []byte) (ChangedLines, error) {
+	changed := make(ChangedLines)
+	var file *changedFile
+	// added lines can start with +++ too, file names are only in headers
+	header := false
+	scanner := bufio.NewScanner(bytes.NewReader(diff))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			header, file = true, nil
+		case header && strings.HasPrefix(line, "+++ "):
+			file = nil
+			path := strings.TrimPrefix(line, "+++ ")
+			if path == "/dev/null" {
+				continue
+			}
+			// git quotes paths with unusual characters
+			if strings.HasPrefix(path, `"`) {
+				unquoted, err := strconv.Unquote(path)
+				if err != nil {
+					return nil, fmt.Errorf("unexpected path in git diff: %s", path)
+				}
+				path = unquoted
+			}
+			file = &changedFile{}
+			changed[filepath.Join(dir, path)] = file
+		case strings.HasPrefix(line, "@@ ") && file != nil:
+			header = false
+			match := hunkPattern.FindStringSubmatch(line)
+			if match == nil {
+				return nil, fmt.Errorf("unexpected hunk in git diff: %s", line)
+			}
+			start, _ := strconv.Atoi(match[1])
+			count := 1
+			if match[2] != "" {
+				count, _ = strconv.Atoi(match[2])
+			}
+			if count > 0 {
+				file.ranges = append(file.ranges, [2]int{start, start + count - 1})
+			}
+		}
+	}
+	return changed, scanner.Err()
+}
+
+// Contains reports whether the line of the file changed. Line 0, the file
+// as a whole, changed if anything in the file did.
+func (c ChangedLines) Contains(path string, line int) bool {
+	file, ok := c[filepath.Clean(path)]
+	if !ok {
+		return false
+	}
+	if file.all || line == 0 {
+		return true
+	}
+	i := sort.Search(len(file.ranges), func(i int) bool { return file.ranges[i][1] >= line })
+	return i < len(file.ranges) && file.ranges[i][0] <= line
+}
+
+// Filter removes the diagnostics that aren't on changed lines, and the
+// outputs of rules on files that didn't change. Diagnostics on dir, the
+// linted directory, are about the whole project and are kept. It returns
+// how many diagnostics it removed.
+func (c ChangedLines) Filter(output GroupedOutput, dir string) int {
+	dir = filepath.Clean(dir)
+	changed := func(path string, line int) bool {
+		return filepath.Clean(path) == dir || c.Contains(path, line)
+	}
+	removed := 0
+	for _, byPath := range output {
+		for path, result := range byPath {
+			kept := result.Diagnostics[:0]
+			for _, d := range result.Diagnostics {
+				if changed(d.Path, d.Line) {
+					kept = append(kept, d)
+				} else {
+					removed++
+				}
+			}
+			result.Diagnostics = kept
+			if !changed(path, 0) {
+				result.Outputs = nil
+			}
+			byPath[path] = result
+		}
+	}
+	return removed
+}
@@ -0,0 +1,129 @@
+package nf
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGitDiff(t *testing.T) {
+	diff := `diff --git main.nf main.nf
+index 3b18e51..a1b2c3d 100644
+--- main.nf
++++ main.nf
+@@ -3 +3 @@ process FOO {
+-    cpus 2
++    cpus 4
+@@ -10,0 +11,3 @@ process FOO {
++    memory '1 GB'
++    time '1h'
++++ not a header
+@@ -20,2 +22,0 @@ workflow {
+-    FOO()
+-    BAR()
+diff --git "modules/a b.nf" "modules/a b.nf"
+deleted file mode 100644
+--- "modules/a b.nf"
++++ /dev/null
+@@ -1 +0,0 @@
+-process A {}
+diff --git "conf/t\303\251st.config" "conf/t\303\251st.config"
+--- "conf/t\303\251st.config"
++++ "conf/t\303\251st.config"
+@@ -5 +4,0 @@
+-    cpus = 1
+`
+	changed, err := parseGitDiff("/pipeline", []byte(diff))
+	if err != nil {
+		t.Fatal("Failed to parse diff:", err)
+	}
+	tests := []struct {
+		path    string
+		line    int
+		changed bool
+	}{
+		{"/pipeline/main.nf", 3, true},
+		{"/pipeline/main.nf", 4, false},
+		{"/pipeline/main.nf", 11, true},
+		{"/pipeline/main.nf", 13, true},
+		{"/pipeline/main.nf", 14, false},
+		{"/pipeline/main.nf", 22, false},
+		{"/pipeline/main.nf", 0, true},
+		{"/pipeline/modules/a b.nf", 0, false},
+		// only deleted lines, the file changed but no line did
+		{"/pipeline/conf/tést.config", 0, true},
+		{"/pipeline/conf/tést.config", 4, false},
+	}
+	for _, tt := range tests {
+		if got := changed.Contains(tt.path, tt.line); got != tt.changed {
+			t.Errorf("Contains(%s, %d) = %v, expected %v", tt.path, tt.line, got, tt.changed)
+		}
+	}
+
+	output := GroupedOutput{"rule": {
+		"/pipeline/main.nf": {
+			Diagnostics: []Diagnostic{{Path: "/pipeline/main.nf", Line: 3}, {Path: "/pipeline/main.nf", Line: 5}},
+			Outputs:     []string{"kept"},
+		},
+		"/pipeline/other.nf": {
+			Diagnostics: []Diagnostic{{Path: "/pipeline/other.nf", Line: 3}},
+			Outputs:     []string{"removed"},
+		},
+		"/pipeline": {Diagnostics: []Diagnostic{{Path: "/pipeline"}}},
+	}}
+	if removed := changed.Filter(output, "/pipeline"); removed != 2 {
+		t.Errorf("Expected 2 diagnostics to be removed, got %d", removed)
+	}
+	if d := output["rule"]["/pipeline/main.nf"].Diagnostics; len(d) != 1 || d[0].Line != 3 {
+		t.Errorf("Expected the diagnostic on line 3 to be kept, got %v", d)
+	}
+	if outputs := output["rule"]["/pipeline/other.nf"].Outputs; outputs != nil {
+		t.Errorf("Expected the outputs on the unchanged file to be removed, got %v", outputs)
+	}
+	if d := output["rule"]["/pipeline"].Diagnostics; len(d) != 1 {
+		t.Errorf("Expected the project diagnostic to be kept, got %v", d)
+	}
+}
+
+func TestGitChangedLines(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	tmpDir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		if _, err := git(tmpDir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mainFile := filepath.Join(tmpDir, "main.nf")
+	writeFiles(t, map[string]string{mainFile: "line 1\nline 2\nline 3\n"})
+	run("init", "-q")
+	run("add", "main.nf")
+	run("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial")
+
+	newFile := filepath.Join(tmpDir, "modules", "new.nf")
+	readme := filepath.Join(tmpDir, "README.md")
+	writeFiles(t, map[string]string{
+		mainFile: "line 1\nchanged\nline 3\n",
+		newFile:  "process NEW {}\n",
+		readme:   "not a module\n",
+	})
+	changed, err := GitChangedLines(tmpDir, "HEAD")
+	if err != nil {
+		t.Fatal("Failed to find changed lines:", err)
+	}
+	if len(changed) != 2 {
+		t.Errorf("Expected main.nf and modules/new.nf to change, got %v", changed)
+	}
+	if !changed.Contains(mainFile, 2) || changed.Contains(mainFile, 1) {
+		t.Errorf("Expected only line 2 of main.nf to change, got %v", changed[mainFile])
+	}
+	if !changed.Contains(newFile, 1) {
+		t.Error("Expected the untracked module to change")
+	}
+
+	if _, err := GitChangedLines(tmpDir, "no-such-ref"); err == nil {
+		t.Error("Expected an error for an unknown ref")
+	}
+}
@@ -0,0 +1,25 @@
+package nf
+
+import (
+	"reft-go/parser"
+
+	"go.starlark.net/starlark"
+)
+
+// StarlarkConfig is a config file passed to the configrule_* functions
+// of the rules file.
+type StarlarkConfig struct {
+	Path     string
+	Value    starlark.Value
+	Comments []parser.Comment
+}
+
+// ConfigLoader parses the config files under a directory.
+// configlint registers one from init, like the built-in rules.
+type ConfigLoader func(dir string) ([]StarlarkConfig, error)
+
+var configLoader ConfigLoader
+
+func RegisterConfigLoader(loader ConfigLoader) {
+	configLoader = loader
+}
@@ -0,0 +1,97 @@
+package configlint
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	pb "reft-go/nf/proto"
+	"reft-go/parser"
+)
+
+type ConfigFile struct {
+	Path          string
+	ProcessScopes []ProcessScope
+	Params        []ConfigParam
+	Comments      []parser.Comment
+}
+
+func (c *ConfigFile) ToProto() *pb.ConfigFile {
+	protoConfig := &pb.ConfigFile{
+		Path: c.Path,
+	}
+
+	for _, scope := range c.ProcessScopes {
+		protoConfig.ProcessScopes = append(protoConfig.ProcessScopes, scope.ToProto())
+	}
+
+	return protoConfig
+}
+
+// BuildConfigFile parses a Nextflow config file.
+// The returned bool is true if the error is likely a bug in RefTrace.
+func BuildConfigFile(filePath string) (*ConfigFile, error, bool) {
+	ast, err := parser.BuildAST(filePath)
+	if err != nil {
+		if _, ok := err.(*parser.SyntaxException); ok {
+			return nil, err, false
+		}
+		return nil, err, true
+	}
+
+	return &ConfigFile{
+		Path:          filePath,
+		ProcessScopes: ParseConfigModule(ast),
+		Params:        ParseConfigParams(ast.StatementBlock, ast),
+		Comments:      ast.Comments,
+	}, nil, false
+}
+
+// ProcessConfigDirectory parses every *.config file under dir.
+// The config files are sorted by path.
+func ProcessConfigDirectory(dir string) ([]*ConfigFile, error) {
+	var configs []*ConfigFile
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errors []error
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() && filepath.Ext(path) == ".config" {
+			wg.Add(1)
+			go func(path string) {
+				defer wg.Done()
+				config, err, _ := BuildConfigFile(path)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					errors = append(errors, fmt.Errorf("%s: %v", path, err))
+					return
+				}
+				configs = append(configs, config)
+			}(path)
+		}
+		return nil
+	})
+
+	wg.Wait()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(errors) > 0 {
+		return nil, fmt.Errorf("encountered %d errors: %v", len(errors), errors)
+	}
+
+	sort.Slice(configs, func(i, j int) bool {
+		return configs[i].Path < configs[j].Path
+	})
+
+	return configs, nil
+}
@@ -0,0 +1,8 @@
+package configlint
+
+import "embed"
+
+// The long-form documentation of the rules, shown by `reft rules explain`
+//
+//go:embed docs/*.md
+var docs embed.FS
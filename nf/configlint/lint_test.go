@@ -148,3 +148,93 @@ func TestParseConfigDuplicateArg(t *testing.T) {
 		t.Fatalf("Expected param to be 'extra_trimgalore_args', got %s", value.Params[0])
 	}
 }
+
+func TestParseConfigValues(t *testing.T) {
+	testCase := `
+process {
+    withName: 'STAR_ALIGN' {
+        cpus   = 12
+        memory = 72.GB
+        time   = '1d 6h'
+        disk   = '500 GB'
+        ext.args = { "--outdir ${params.outdir}" }
+        ext.prefix = params.prefix
+        publishDir = [
+            path: 'results',
+            enabled: false
+        ]
+    }
+}
+`
+	testFilePath := filepath.Join(t.TempDir(), "test.config")
+	if err := os.WriteFile(testFilePath, []byte(testCase), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	config, err, _ := BuildConfigFile(testFilePath)
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+	if len(config.ProcessScopes) != 1 || len(config.ProcessScopes[0].NamedScopes) != 1 {
+		t.Fatalf("Expected 1 process scope with 1 named scope")
+	}
+
+	values := make(map[string]Value)
+	var options []NamedOption
+	for _, directive := range config.ProcessScopes[0].NamedScopes[0].Directives {
+		values[directive.Name] = directive.Value.Value
+		if directive.Name == "publishDir" {
+			options = directive.Options
+		}
+	}
+
+	expected := map[string]Value{
+		"cpus":       {Kind: NumberValue, Num: 12},
+		"memory":     {Kind: MemoryValue, Num: 72 * (1 << 30), Source: "72.GB"},
+		"time":       {Kind: DurationValue, Num: 30 * 60 * 60 * 1000, Source: "1d 6h"},
+		"disk":       {Kind: MemoryValue, Num: 500 * (1 << 30), Source: "500 GB"},
+		"ext.prefix": {Kind: ParamValue, Str: "prefix"},
+	}
+	for name, want := range expected {
+		got := values[name]
+		if got.Kind != want.Kind || got.Num != want.Num || got.Str != want.Str || got.Source != want.Source {
+			t.Errorf("%s: expected %s %v, got %s %v", name, want.Kind, want.String(), got.Kind, got.String())
+		}
+	}
+
+	closure := values["ext.args"]
+	if closure.Kind != ClosureValue || closure.Source != `{ "--outdir ${params.outdir}" }` {
+		t.Errorf("ext.args: expected closure source, got %s %q", closure.Kind, closure.Source)
+	}
+
+	if len(options) != 2 {
+		t.Fatalf("Expected 2 publishDir options, got %d", len(options))
+	}
+	if v := options[0].Value.Value; v.Kind != StringValue || v.Str != "results" {
+		t.Errorf("Expected path to be 'results', got %s", v.String())
+	}
+	if v := options[1].Value.Value; v.Kind != BoolValue || v.Bool {
+		t.Errorf("Expected enabled to be false, got %s", v.String())
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]float64{
+		"2h":       2 * 60 * 60 * 1000,
+		"1d 6h":    30 * 60 * 60 * 1000,
+		"30.min":   30 * 60 * 1000,
+		"1.5 s":    1500,
+		"10 hours": 10 * 60 * 60 * 1000,
+	}
+	for text, want := range tests {
+		got, ok := ParseDuration(text)
+		if !ok || got != want {
+			t.Errorf("ParseDuration(%q) = %v, %v; want %v", text, got, ok, want)
+		}
+	}
+	for _, text := range []string{"", "2 weeks", "h2", "2h foo"} {
+		if _, ok := ParseDuration(text); ok {
+			t.Errorf("ParseDuration(%q) should fail", text)
+		}
+	}
+}
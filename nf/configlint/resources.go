@@ -0,0 +1,449 @@
+package configlint
+
+import (
+	"fmt"
+	"path/filepath"
+	"reft-go/nf"
+	"reft-go/nf/directives"
+	"reft-go/parser"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	nf.RegisterBuiltinRule(nf.BuiltinRule{
+		Name: "resource_limits",
+		Run:  ruleResourceLimits,
+		Info: nf.RuleInfo{
+			Severity:    nf.SeverityError,
+			Category:    "config",
+			Description: "Processes should not request more cpus, memory or time than the config allows",
+			Doc:         nf.RuleDoc(docs, "resource_limits"),
+		},
+	})
+}
+
+// The resources compared against their limits.
+// cpus are a count, memory is in bytes and time is in milliseconds.
+var limitedResources = []string{"cpus", "memory", "time"}
+
+// legacy nf-core params that cap the resources
+var legacyLimitParams = map[string]string{
+	"cpus":   "max_cpus",
+	"memory": "max_memory",
+	"time":   "max_time",
+}
+
+// ResourceLimit is the cap on a resource and where it was set.
+type ResourceLimit struct {
+	Resource   string
+	Amount     float64
+	ConfigPath string
+	Line       int
+}
+
+// ResourceRequest is the amount of a resource a process asks for on a
+// given attempt, and where it was set.
+type ResourceRequest struct {
+	Resource string
+	Amount   float64
+	Attempt  int
+	Path     string
+	Line     int
+}
+
+// ResourceViolation is a request that is above its limit.
+type ResourceViolation struct {
+	Process ProcessRef
+	Request ResourceRequest
+	Limit   ResourceLimit
+}
+
+// UnknownLimit is a resourceLimits entry of a process for a resource
+// the config sets no limit for.
+type UnknownLimit struct {
+	Process  ProcessRef
+	Resource string
+	Line     int
+}
+
+func resourceAmount(resource string, value Value) (float64, bool) {
+	value = coerceValue(resource, value)
+	switch {
+	case resource == "cpus" && value.Kind == NumberValue:
+		return value.Num, true
+	case resource == "memory" && value.Kind == MemoryValue:
+		return value.Num, true
+	case resource == "time" && value.Kind == DurationValue:
+		return value.Num, true
+	}
+	return 0, false
+}
+
+/*
+evalResource evaluates a cpus, memory or time value for a task attempt.
+
+Only the forms used for retry escalation are understood: constants,
+memory and duration literals, task.attempt and the arithmetic operators,
+optionally inside a closure, e.g. { 6.GB * task.attempt }. Values wrapped
+in the legacy check_max function are already capped and aren't evaluated.
+*/
+func evalResource(expr parser.Expression, attempt int) (Value, bool) {
+	switch e := expr.(type) {
+	case *parser.ClosureExpression:
+		bs, ok := e.GetCode().(*parser.BlockStatement)
+		if !ok || len(bs.GetStatements()) != 1 {
+			return Value{}, false
+		}
+		switch stmt := bs.GetStatements()[0].(type) {
+		case *parser.ExpressionStatement:
+			return evalResource(stmt.GetExpression(), attempt)
+		case *parser.ReturnStatement:
+			return evalResource(stmt.GetExpression(), attempt)
+		}
+		return Value{}, false
+	case *parser.PropertyExpression:
+		if e.GetObjectExpression().GetText() == "task" && e.GetPropertyAsString() == "attempt" {
+			return Value{Kind: NumberValue, Num: float64(attempt)}, true
+		}
+	case *parser.BinaryExpression:
+		left, ok := evalResource(e.GetLeftExpression(), attempt)
+		if !ok {
+			return Value{}, false
+		}
+		right, ok := evalResource(e.GetRightExpression(), attempt)
+		if !ok {
+			return Value{}, false
+		}
+		return combineResources(e.GetOperation().GetText(), left, right)
+	}
+	value := makeValue(expr, nil)
+	switch value.Kind {
+	case NumberValue, MemoryValue, DurationValue, StringValue:
+		return value, true
+	}
+	return Value{}, false
+}
+
+func combineResources(op string, left, right Value) (Value, bool) {
+	switch op {
+	case "*":
+		if left.Kind == NumberValue {
+			return Value{Kind: right.Kind, Num: left.Num * right.Num}, right.Kind != StringValue
+		}
+		if right.Kind == NumberValue {
+			return Value{Kind: left.Kind, Num: left.Num * right.Num}, left.Kind != StringValue
+		}
+	case "/":
+		if right.Kind == NumberValue && right.Num != 0 {
+			return Value{Kind: left.Kind, Num: left.Num / right.Num}, left.Kind != StringValue
+		}
+	case "+", "-":
+		if left.Kind == right.Kind && left.Kind != StringValue {
+			if op == "-" {
+				return Value{Kind: left.Kind, Num: left.Num - right.Num}, true
+			}
+			return Value{Kind: left.Kind, Num: left.Num + right.Num}, true
+		}
+	}
+	return Value{}, false
+}
+
+// configDirective is a directive set in a config file
+type configDirective struct {
+	ConfigPath string
+	Directive  Directive
+	// set by a withName or withLabel selector rather than the process scope
+	Selected bool
+}
+
+/*
+effectiveDirectives returns the config directives that apply to a
+process, keyed by name. As in Nextflow, withName selectors take
+precedence over withLabel selectors, which take precedence over the
+process scope defaults.
+*/
+func effectiveDirectives(process nf.Process, configs []*ConfigFile) map[string]configDirective {
+	defaults := make(map[string]configDirective)
+	labelled := make(map[string]configDirective)
+	named := make(map[string]configDirective)
+	for _, config := range configs {
+		for _, processScope := range config.ProcessScopes {
+			for _, directive := range processScope.Directives {
+				defaults[directive.Name] = configDirective{config.Path, directive, false}
+			}
+			for _, selector := range processScope.NamedScopes {
+				if matched, err := selectorMatches(selector, process); err != nil || !matched {
+					continue
+				}
+				target := labelled
+				if selector.Kind == WithName {
+					target = named
+				}
+				for _, directive := range selector.Directives {
+					target[directive.Name] = configDirective{config.Path, directive, true}
+				}
+			}
+		}
+	}
+	for name, directive := range labelled {
+		defaults[name] = directive
+	}
+	for name, directive := range named {
+		defaults[name] = directive
+	}
+	return defaults
+}
+
+// configLimits returns the limits set by resourceLimits in the config,
+// falling back to the legacy params.max_* values.
+func configLimits(configDirectives map[string]configDirective, configs []*ConfigFile) map[string]ResourceLimit {
+	limits := make(map[string]ResourceLimit)
+	for _, config := range configs {
+		for _, param := range config.Params {
+			for _, resource := range limitedResources {
+				if param.Name != legacyLimitParams[resource] {
+					continue
+				}
+				if amount, ok := resourceAmount(resource, param.Value); ok {
+					limits[resource] = ResourceLimit{resource, amount, config.Path, param.LineNumber}
+				}
+			}
+		}
+	}
+	if resourceLimits, ok := configDirectives["resourceLimits"]; ok {
+		for _, option := range resourceLimits.Directive.Options {
+			if amount, ok := resourceAmount(option.Name, option.Value.Value); ok {
+				limits[option.Name] = ResourceLimit{option.Name, amount, resourceLimits.ConfigPath, option.LineNumber}
+			}
+		}
+	}
+	return limits
+}
+
+// processLimits returns the limits set by the resourceLimits directive
+// of a process definition.
+func processLimits(module *nf.Module, process nf.Process) map[string]ResourceLimit {
+	limits := make(map[string]ResourceLimit)
+	for _, directive := range process.Directives {
+		d, ok := directive.(*directives.ResourceLimitsDirective)
+		if !ok {
+			continue
+		}
+		if d.Cpus != nil {
+			limits["cpus"] = ResourceLimit{"cpus", float64(*d.Cpus), module.Path, d.Line()}
+		}
+		if d.Memory != nil {
+			if amount, ok := ParseMemory(strings.Trim(*d.Memory, `'"`)); ok {
+				limits["memory"] = ResourceLimit{"memory", amount, module.Path, d.Line()}
+			}
+		}
+		if d.Time != nil {
+			if amount, ok := ParseDuration(strings.Trim(*d.Time, `'"`)); ok {
+				limits["time"] = ResourceLimit{"time", amount, module.Path, d.Line()}
+			}
+		}
+	}
+	return limits
+}
+
+// dynamicDirective finds the closure of a directive like memory { 2.GB * task.attempt }
+// in the body of a process.
+func dynamicDirective(process nf.Process, name string) *parser.ClosureExpression {
+	if process.Closure == nil {
+		return nil
+	}
+	bs, ok := process.Closure.GetCode().(*parser.BlockStatement)
+	if !ok {
+		return nil
+	}
+	for _, stmt := range bs.GetStatements() {
+		exprStmt, ok := stmt.(*parser.ExpressionStatement)
+		if !ok {
+			continue
+		}
+		mce, ok := exprStmt.GetExpression().(*parser.MethodCallExpression)
+		if !ok || mce.GetMethod().GetText() != name {
+			continue
+		}
+		if args, ok := mce.GetArguments().(*parser.ArgumentListExpression); ok && len(args.GetExpressions()) == 1 {
+			if closure, ok := args.GetExpressions()[0].(*parser.ClosureExpression); ok {
+				return closure
+			}
+		}
+	}
+	return nil
+}
+
+// processRequests returns the highest amount of each resource the process
+// can request over its attempts.
+func processRequests(module *nf.Module, process nf.Process, configDirectives map[string]configDirective) []ResourceRequest {
+	// Nextflow only retries when maxRetries is set
+	maxRetries := 0
+	for _, directive := range process.Directives {
+		if d, ok := directive.(*directives.MaxRetriesDirective); ok {
+			maxRetries = d.Num
+		}
+	}
+	if d, ok := configDirectives["maxRetries"]; ok {
+		if value := d.Directive.Value.Value; value.Kind == NumberValue {
+			maxRetries = int(value.Num)
+		}
+	}
+
+	var requests []ResourceRequest
+	for _, resource := range limitedResources {
+		// the process definition overrides the process scope defaults,
+		// and is overridden by selectors
+		var expr parser.Expression
+		path, line := module.Path, 0
+		var static *float64
+		for _, directive := range process.Directives {
+			switch d := directive.(type) {
+			case *directives.CpusDirective:
+				if resource == "cpus" {
+					amount := float64(d.Num)
+					static, line = &amount, d.Line()
+				}
+			case *directives.MemoryDirective:
+				if resource == "memory" {
+					amount := d.MemoryGB * memoryUnits["GB"]
+					static, line = &amount, d.Line()
+				}
+			case *directives.TimeDirective:
+				if resource == "time" {
+					if amount, ok := ParseDuration(d.Duration); ok {
+						static, line = &amount, d.Line()
+					}
+				}
+			case *directives.DynamicDirective:
+				if d.Name == resource {
+					if closure := dynamicDirective(process, resource); closure != nil {
+						expr, line = closure, d.Line()
+					}
+				}
+			}
+		}
+		if d, ok := configDirectives[resource]; ok && (d.Selected || (static == nil && expr == nil)) {
+			expr, static = d.Directive.Value.Expression, nil
+			path, line = d.ConfigPath, d.Directive.LineNumber
+		}
+
+		var highest *ResourceRequest
+		for attempt := 1; attempt <= maxRetries+1; attempt++ {
+			var amount float64
+			if static != nil {
+				amount = *static
+			} else if expr != nil {
+				value, ok := evalResource(expr, attempt)
+				if !ok {
+					break
+				}
+				if amount, ok = resourceAmount(resource, value); !ok {
+					break
+				}
+			} else {
+				break
+			}
+			if highest == nil || amount > highest.Amount {
+				highest = &ResourceRequest{resource, amount, attempt, path, line}
+			}
+		}
+		if highest != nil {
+			requests = append(requests, *highest)
+		}
+	}
+	return requests
+}
+
+/*
+CheckResourceLimits compares the cpus, memory and time each process can
+request, including retries, with the limits set in the config and by the
+resourceLimits directive of the process.
+*/
+func CheckResourceLimits(modules []*nf.Module, configs []*ConfigFile) ([]ResourceViolation, []UnknownLimit) {
+	var violations []ResourceViolation
+	var unknown []UnknownLimit
+	for _, module := range modules {
+		for _, process := range module.Processes {
+			ref := ProcessRef{ModulePath: module.Path, Name: process.Name, Line: process.Line()}
+			configDirectives := effectiveDirectives(process, configs)
+			limits := configLimits(configDirectives, configs)
+
+			for _, directive := range process.Directives {
+				if d, ok := directive.(*directives.ResourceLimitsDirective); ok {
+					set := map[string]bool{"cpus": d.Cpus != nil, "memory": d.Memory != nil, "time": d.Time != nil, "disk": d.Disk != nil}
+					for _, resource := range []string{"cpus", "memory", "time", "disk"} {
+						if _, known := limits[resource]; set[resource] && !known {
+							unknown = append(unknown, UnknownLimit{ref, resource, d.Line()})
+						}
+					}
+				}
+			}
+
+			// the resourceLimits of the process definition override the
+			// process scope defaults, but not a selector
+			if d, ok := configDirectives["resourceLimits"]; !ok || !d.Selected {
+				for resource, limit := range processLimits(module, process) {
+					limits[resource] = limit
+				}
+			}
+
+			for _, request := range processRequests(module, process, configDirectives) {
+				if limit, ok := limits[request.Resource]; ok && request.Amount > limit.Amount {
+					violations = append(violations, ResourceViolation{ref, request, limit})
+				}
+			}
+		}
+	}
+	return violations, unknown
+}
+
+func formatResource(resource string, amount float64) string {
+	switch resource {
+	case "memory":
+		return strconv.FormatFloat(amount/memoryUnits["GB"], 'f', -1, 64) + " GB"
+	case "time":
+		return strconv.FormatFloat(amount/durationUnits["h"], 'f', -1, 64) + " h"
+	}
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
+
+func ruleResourceLimits(dir string, modules []*nf.Module) (map[string]nf.RuleModuleOutput, error) {
+	results := make(map[string]nf.RuleModuleOutput)
+
+	configs, err := ProcessConfigDirectory(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Without any config there is nothing to check against
+	if len(configs) == 0 {
+		return results, nil
+	}
+
+	violations, unknown := CheckResourceLimits(modules, configs)
+	for _, violation := range violations {
+		request, limit := violation.Request, violation.Limit
+		addDiagnostic(results, nf.Diagnostic{
+			Path:     violation.Process.ModulePath,
+			Line:     violation.Process.Line,
+			Severity: nf.SeverityError,
+			Code:     "resource-limit-exceeded",
+			Message: fmt.Sprintf("process '%s' can request %s %s on attempt %d (%s:%d), above the limit of %s (%s:%d)",
+				violation.Process.Name,
+				formatResource(request.Resource, request.Amount), request.Resource, request.Attempt,
+				filepath.Base(request.Path), request.Line,
+				formatResource(limit.Resource, limit.Amount), filepath.Base(limit.ConfigPath), limit.Line),
+		})
+	}
+	for _, limit := range unknown {
+		addDiagnostic(results, nf.Diagnostic{
+			Path:     limit.Process.ModulePath,
+			Line:     limit.Line,
+			Severity: nf.SeverityError,
+			Code:     "unknown-resource-limit",
+			Message:  fmt.Sprintf("process '%s' sets a %s limit that the config does not define", limit.Process.Name, limit.Resource),
+		})
+	}
+	return results, nil
+}
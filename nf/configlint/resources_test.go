@@ -0,0 +1,111 @@
+package configlint
+
+import (
+	"os"
+	"path/filepath"
+	"reft-go/nf"
+	"testing"
+)
+
+func TestCheckResourceLimits(t *testing.T) {
+	configContent := `
+params {
+    max_cpus = 16
+    max_time = '240.h'
+}
+
+process {
+    maxRetries = 2
+    resourceLimits = [
+        memory: 64.GB
+    ]
+    withLabel: 'process_high' {
+        cpus   = { 12 * task.attempt }
+        memory = { 36.GB * task.attempt }
+    }
+    withName: 'SMALL' {
+        memory = '4 GB'
+    }
+}
+`
+	moduleContent := `
+process BIG {
+    label 'process_high'
+    script:
+    """
+    echo "test"
+    """
+}
+
+process SMALL {
+    label 'process_high'
+    resourceLimits disk: 100.GB
+    script:
+    """
+    echo "test"
+    """
+}
+
+process OWN {
+    cpus 6
+    memory { 4.GB * task.attempt }
+    resourceLimits cpus: 4, memory: 8.GB
+    script:
+    """
+    echo "test"
+    """
+}
+`
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "nextflow.config"), []byte(configContent), 0644); err != nil {
+		t.Fatal("Failed to write config file:", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.nf"), []byte(moduleContent), 0644); err != nil {
+		t.Fatal("Failed to write module file:", err)
+	}
+
+	modules, err := nf.ProcessDirectory(tmpDir)
+	if err != nil {
+		t.Fatal("Failed to parse modules:", err)
+	}
+	configs, err := ProcessConfigDirectory(tmpDir)
+	if err != nil {
+		t.Fatal("Failed to parse configs:", err)
+	}
+
+	violations, unknown := CheckResourceLimits(modules, configs)
+
+	type key struct {
+		process, resource string
+		attempt           int
+	}
+	found := make(map[key]bool)
+	for _, v := range violations {
+		found[key{v.Process.Name, v.Request.Resource, v.Request.Attempt}] = true
+		if v.Process.Name == "OWN" && filepath.Base(v.Limit.ConfigPath) != "main.nf" {
+			t.Errorf("Expected the limit of OWN to come from its resourceLimits, got %s:%d", v.Limit.ConfigPath, v.Limit.Line)
+		}
+	}
+	expected := []key{
+		// 36 GB * 3 attempts > 64 GB
+		{"BIG", "memory", 3},
+		// 12 * 3 attempts > 16 cpus
+		{"BIG", "cpus", 3},
+		{"SMALL", "cpus", 3},
+		// the process's own resourceLimits: 6 > 4 cpus, 4 GB * 3 attempts > 8 GB
+		{"OWN", "cpus", 1},
+		{"OWN", "memory", 3},
+	}
+	for _, k := range expected {
+		if !found[k] {
+			t.Errorf("Expected a %s violation for %s on attempt %d", k.resource, k.process, k.attempt)
+		}
+	}
+	if len(violations) != len(expected) {
+		t.Errorf("Expected %d violations, got %d: %v", len(expected), len(violations), violations)
+	}
+
+	if len(unknown) != 1 || unknown[0].Process.Name != "SMALL" || unknown[0].Resource != "disk" {
+		t.Errorf("Expected an unknown disk limit for SMALL, got %v", unknown)
+	}
+}
@@ -0,0 +1,467 @@
+package configlint
+
+import (
+	"fmt"
+	"reft-go/nf"
+	"reft-go/nf/directives"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dlclark/regexp2"
+)
+
+func init() {
+	nf.RegisterBuiltinRule(nf.BuiltinRule{
+		Name: "config_selectors",
+		Run:  ruleConfigSelectors,
+		Info: nf.RuleInfo{
+			Category:    "config",
+			Description: "withName and withLabel selectors should match the processes",
+			Doc:         nf.RuleDoc(docs, "config_selectors"),
+		},
+	})
+}
+
+// ProcessRef identifies a process definition in a module.
+type ProcessRef struct {
+	ModulePath string
+	Name       string
+	Line       int
+}
+
+// SelectorMatch lists the processes a withName or withLabel selector applies to.
+type SelectorMatch struct {
+	ConfigPath string
+	Selector   NamedScope
+	Processes  []ProcessRef
+}
+
+// InvalidSelector is a selector whose pattern doesn't compile.
+type InvalidSelector struct {
+	ConfigPath string
+	Selector   NamedScope
+	Err        error
+}
+
+// UncoveredLabel is a label used by a process that no withLabel selector applies to.
+type UncoveredLabel struct {
+	Process ProcessRef
+	Label   string
+	Line    int
+}
+
+// Guards against catastrophic backtracking in user supplied patterns
+const selectorMatchTimeout = time.Second
+
+/*
+Selector is a compiled withName or withLabel pattern.
+
+It mirrors Nextflow's selector semantics: the pattern is a Java regular
+expression that must match the entire name, and a leading '!' negates
+the match. regexp2 is used since Go's regexp rejects Java constructs such
+as lookarounds. Its ECMAScript mode matches Java's ASCII-only \d, \w and
+\s. \Q...\E quotes and the POSIX classes, like \p{Alpha} and \p{Punct},
+are translated, but a negated POSIX class (\P{Alpha}) inside a character
+class isn't. Possessive quantifiers (a*+), class intersections
+([a-z&&[^b]]) and Java's other \p{...} properties, like \p{javaLowerCase},
+are not supported.
+*/
+type Selector struct {
+	Pattern string
+	negate  bool
+	re      *regexp2.Regexp
+}
+
+func CompileSelector(pattern string) (*Selector, error) {
+	negate := strings.HasPrefix(pattern, "!")
+	expr := pattern
+	if negate {
+		expr = pattern[1:]
+	}
+	expr = javaPOSIXClasses(javaQuotes(expr))
+	// compile the bare pattern first so errors refer to what the user wrote
+	if _, err := regexp2.Compile(expr, regexp2.ECMAScript); err != nil {
+		return nil, err
+	}
+	re, err := regexp2.Compile(`\A(?:`+expr+`)\z`, regexp2.ECMAScript)
+	if err != nil {
+		return nil, err
+	}
+	re.MatchTimeout = selectorMatchTimeout
+	return &Selector{Pattern: pattern, negate: negate, re: re}, nil
+}
+
+// javaQuotes replaces the \Q...\E quotes of a Java pattern with the
+// escaped text they quote. A quote without \E runs to the end.
+func javaQuotes(expr string) string {
+	var sb strings.Builder
+	for i := 0; i < len(expr); i++ {
+		if expr[i] != '\\' || i+1 == len(expr) {
+			sb.WriteByte(expr[i])
+			continue
+		}
+		if expr[i+1] != 'Q' {
+			sb.WriteString(expr[i : i+2])
+			i++
+			continue
+		}
+		quoted := expr[i+2:]
+		end := strings.Index(quoted, `\E`)
+		if end < 0 {
+			end = len(quoted)
+		}
+		sb.WriteString(regexp2.Escape(quoted[:end]))
+		i += 2 + end + 1
+	}
+	return sb.String()
+}
+
+// The ASCII ranges of Java's POSIX classes, as the content of a character
+// class
+var posixClasses = map[string]string{
+	"Lower":  `a-z`,
+	"Upper":  `A-Z`,
+	"ASCII":  `\x00-\x7F`,
+	"Alpha":  `a-zA-Z`,
+	"Digit":  `0-9`,
+	"Alnum":  `a-zA-Z0-9`,
+	"Punct":  `\x21-\x2F\x3A-\x40\x5B-\x60\x7B-\x7E`,
+	"Graph":  `\x21-\x7E`,
+	"Print":  `\x20-\x7E`,
+	"Blank":  `\x20\t`,
+	"Cntrl":  `\x00-\x1F\x7F`,
+	"XDigit": `0-9a-fA-F`,
+	"Space":  `\x20\t\n\x0B\f\r`,
+}
+
+// javaPOSIXClasses replaces the POSIX classes of a Java pattern, like
+// \p{Alpha}, which regexp2 doesn't know, with their ASCII ranges.
+func javaPOSIXClasses(expr string) string {
+	var sb strings.Builder
+	// nesting of character classes, Java allows [a[b]]
+	classDepth := 0
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case c == '[':
+			classDepth++
+		case c == ']' && classDepth > 0:
+			classDepth--
+		case c == '\\' && i+1 < len(expr):
+			if p := expr[i+1]; (p == 'p' || p == 'P') && strings.HasPrefix(expr[i+2:], "{") {
+				if end := strings.IndexByte(expr[i+2:], '}'); end >= 0 {
+					class, ok := posixClasses[expr[i+3:i+2+end]]
+					switch {
+					case ok && p == 'p' && classDepth > 0:
+						sb.WriteString(class)
+					case ok && p == 'p':
+						sb.WriteString("[" + class + "]")
+					case ok && classDepth == 0:
+						sb.WriteString("[^" + class + "]")
+					default:
+						sb.WriteString(expr[i : i+3+end])
+					}
+					i += 2 + end
+					continue
+				}
+			}
+			sb.WriteString(expr[i : i+2])
+			i++
+			continue
+		}
+		sb.WriteByte(expr[i])
+	}
+	return sb.String()
+}
+
+// Patterns that only list names, like 'FASTQC|MULTIQC'
+var nameListPattern = regexp.MustCompile(`^(?:\((?:\?:)?)?[\w:-]+(?:\|[\w:-]+)*\)?$`)
+
+// wildcardPattern reports whether a pattern can match names it doesn't
+// spell out, like '.*' or 'FASTQC_.*'
+func wildcardPattern(pattern string) bool {
+	return !nameListPattern.MatchString(strings.TrimPrefix(pattern, "!"))
+}
+
+// Match reports whether the selector applies to a name.
+// A match that times out is treated as no match.
+func (s *Selector) Match(name string) bool {
+	matched, err := s.re.MatchString(name)
+	if err != nil {
+		return false
+	}
+	return matched != s.negate
+}
+
+type compiledSelector struct {
+	selector *Selector
+	err      error
+}
+
+// pattern -> compiledSelector
+var selectorCache sync.Map
+
+func compileSelectorCached(pattern string) (*Selector, error) {
+	if cached, ok := selectorCache.Load(pattern); ok {
+		c := cached.(compiledSelector)
+		return c.selector, c.err
+	}
+	selector, err := CompileSelector(pattern)
+	selectorCache.Store(pattern, compiledSelector{selector, err})
+	return selector, err
+}
+
+func matchesSelector(pattern, name string) (bool, error) {
+	selector, err := compileSelectorCached(pattern)
+	if err != nil {
+		return false, err
+	}
+	return selector.Match(name), nil
+}
+
+/*
+matchesProcessName reports whether a withName pattern applies to a process.
+
+Nextflow also matches withName against the fully qualified name of a
+process (e.g. 'RNASEQ:ALIGN_STAR:STAR_ALIGN'), which depends on how the
+process is invoked. We don't resolve invocation paths, so a pattern that
+contains ':' outside of any group is also tried with everything up to the
+last such ':' removed. If that doesn't compile, the pattern doesn't match.
+*/
+func matchesProcessName(pattern, name string) (bool, error) {
+	matched, err := matchesSelector(pattern, name)
+	if err != nil || matched || strings.HasPrefix(pattern, "!") {
+		return matched, err
+	}
+	if idx := lastQualifierColon(pattern); idx != -1 {
+		matched, err := matchesSelector(pattern[idx+1:], name)
+		return matched && err == nil, nil
+	}
+	return false, nil
+}
+
+// lastQualifierColon returns the index of the last ':' of a pattern that
+// separates the names of a fully qualified process name, -1 if there is
+// none. The ':' of groups like (?:...) and (?i:...), of character classes
+// and escaped ones are skipped.
+func lastQualifierColon(pattern string) int {
+	last := -1
+	groupDepth, classDepth := 0, 0
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\':
+			i++
+		case c == '[':
+			classDepth++
+		case c == ']' && classDepth > 0:
+			classDepth--
+		case classDepth > 0:
+		case c == '(':
+			groupDepth++
+		case c == ')' && groupDepth > 0:
+			groupDepth--
+		case c == ':' && groupDepth == 0:
+			last = i
+		}
+	}
+	return last
+}
+
+func processLabels(process nf.Process) []*directives.LabelDirective {
+	var labels []*directives.LabelDirective
+	for _, directive := range process.Directives {
+		if label, ok := directive.(*directives.LabelDirective); ok {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+func selectorMatches(selector NamedScope, process nf.Process) (bool, error) {
+	switch selector.Kind {
+	case WithName:
+		return matchesProcessName(selector.Name, process.Name)
+	case WithLabel:
+		for _, label := range processLabels(process) {
+			matched, err := matchesSelector(selector.Name, label.Label)
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+	}
+	return false, nil
+}
+
+/*
+MatchSelectors returns, for every withName and withLabel selector in the
+config files, the processes it applies to.
+
+Selectors that are not valid regular expressions are skipped.
+*/
+func MatchSelectors(modules []*nf.Module, configs []*ConfigFile) []SelectorMatch {
+	var matches []SelectorMatch
+	for _, config := range configs {
+		for _, processScope := range config.ProcessScopes {
+			for _, selector := range processScope.NamedScopes {
+				match := SelectorMatch{
+					ConfigPath: config.Path,
+					Selector:   selector,
+					Processes:  []ProcessRef{},
+				}
+				valid := true
+				for _, module := range modules {
+					for _, process := range module.Processes {
+						matched, err := selectorMatches(selector, process)
+						if err != nil {
+							valid = false
+							break
+						}
+						if matched {
+							match.Processes = append(match.Processes, ProcessRef{
+								ModulePath: module.Path,
+								Name:       process.Name,
+								Line:       process.Line(),
+							})
+						}
+					}
+					if !valid {
+						break
+					}
+				}
+				if valid {
+					matches = append(matches, match)
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// ValidateSelectors returns the selectors that are not valid regular expressions.
+func ValidateSelectors(configs []*ConfigFile) []InvalidSelector {
+	var invalid []InvalidSelector
+	for _, config := range configs {
+		for _, processScope := range config.ProcessScopes {
+			for _, selector := range processScope.NamedScopes {
+				if _, err := compileSelectorCached(selector.Name); err != nil {
+					invalid = append(invalid, InvalidSelector{
+						ConfigPath: config.Path,
+						Selector:   selector,
+						Err:        err,
+					})
+				}
+			}
+		}
+	}
+	return invalid
+}
+
+// UncoveredLabels returns the process labels that no withLabel selector applies to.
+func UncoveredLabels(modules []*nf.Module, configs []*ConfigFile) []UncoveredLabel {
+	var patterns []string
+	for _, config := range configs {
+		for _, processScope := range config.ProcessScopes {
+			for _, selector := range processScope.NamedScopes {
+				if selector.Kind == WithLabel {
+					patterns = append(patterns, selector.Name)
+				}
+			}
+		}
+	}
+
+	var uncovered []UncoveredLabel
+	for _, module := range modules {
+		for _, process := range module.Processes {
+			for _, label := range processLabels(process) {
+				covered := false
+				for _, pattern := range patterns {
+					if matched, err := matchesSelector(pattern, label.Label); err == nil && matched {
+						covered = true
+						break
+					}
+				}
+				if !covered {
+					uncovered = append(uncovered, UncoveredLabel{
+						Process: ProcessRef{
+							ModulePath: module.Path,
+							Name:       process.Name,
+							Line:       process.Line(),
+						},
+						Label: label.Label,
+						Line:  label.Line(),
+					})
+				}
+			}
+		}
+	}
+	return uncovered
+}
+
+func addDiagnostic(results map[string]nf.RuleModuleOutput, d nf.Diagnostic) {
+	entry := results[d.Path]
+	entry.Diagnostics = append(entry.Diagnostics, d)
+	results[d.Path] = entry
+}
+
+func ruleConfigSelectors(dir string, modules []*nf.Module) (map[string]nf.RuleModuleOutput, error) {
+	results := make(map[string]nf.RuleModuleOutput)
+
+	configs, err := ProcessConfigDirectory(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Without any config there is nothing to check against
+	if len(configs) == 0 {
+		return results, nil
+	}
+
+	for _, invalid := range ValidateSelectors(configs) {
+		addDiagnostic(results, nf.Diagnostic{
+			Path:     invalid.ConfigPath,
+			Line:     invalid.Selector.LineNumber,
+			Severity: nf.SeverityError,
+			Code:     "invalid-selector",
+			Message:  fmt.Sprintf("invalid %s selector '%s': %v", invalid.Selector.Kind, invalid.Selector.Name, invalid.Err),
+		})
+	}
+
+	numProcesses := 0
+	for _, module := range modules {
+		numProcesses += len(module.Processes)
+	}
+
+	for _, match := range MatchSelectors(modules, configs) {
+		if len(match.Processes) == 0 {
+			addDiagnostic(results, nf.Diagnostic{
+				Path:     match.ConfigPath,
+				Line:     match.Selector.LineNumber,
+				Severity: nf.SeverityError,
+				Code:     "unmatched-selector",
+				Message:  fmt.Sprintf("%s selector '%s' matches no process", match.Selector.Kind, match.Selector.Name),
+			})
+		} else if numProcesses > 1 && len(match.Processes) == numProcesses && wildcardPattern(match.Selector.Name) {
+			// likely an over-broad pattern, a list of names selects what it
+			// means to
+			addDiagnostic(results, nf.Diagnostic{
+				Path:     match.ConfigPath,
+				Line:     match.Selector.LineNumber,
+				Severity: nf.SeverityWarning,
+				Code:     "match-all-selector",
+				Message:  fmt.Sprintf("%s selector '%s' matches every process", match.Selector.Kind, match.Selector.Name),
+			})
+		}
+	}
+
+	for _, label := range UncoveredLabels(modules, configs) {
+		addDiagnostic(results, nf.Diagnostic{
+			Path:     label.Process.ModulePath,
+			Line:     label.Line,
+			Severity: nf.SeverityError,
+			Code:     "uncovered-label",
+			Message:  fmt.Sprintf("label '%s' of process '%s' is not covered by any withLabel selector", label.Label, label.Process.Name),
+		})
+	}
+
+	return results, nil
+}
@@ -0,0 +1,231 @@
+package configlint
+
+import (
+	"os"
+	"path/filepath"
+	"reft-go/nf"
+	"strings"
+	"testing"
+)
+
+func TestMatchSelectors(t *testing.T) {
+	configContent := `
+process {
+    withLabel: 'process_low' {
+        cpus = 2
+    }
+    withLabel: 'process_gpu' {
+        accelerator = 1
+    }
+    withName: 'FASTQC|MULTIQC' {
+        ext.args = '--quiet'
+    }
+    withName: '.*:ALIGN:STAR_ALIGN' {
+        ext.args = '--twopassMode Basic'
+    }
+    withName: 'OLD_NAME' {
+        ext.args = '--foo'
+    }
+    withName: '(?:FASTQC|OLD_NAME)' {
+        ext.args = '--bar'
+    }
+    withName: '(?i:fastqc)' {
+        ext.args = '--baz'
+    }
+    withName: '(?:OLD|NEW)_NAME' {
+        ext.args = '--qux'
+    }
+}
+`
+	moduleContent := `
+process FASTQC {
+    label 'process_low'
+    script:
+    """
+    echo "test"
+    """
+}
+
+process STAR_ALIGN {
+    label 'process_high'
+    script:
+    """
+    echo "test"
+    """
+}
+`
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "modules.config"), []byte(configContent), 0644); err != nil {
+		t.Fatal("Failed to write config file:", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.nf"), []byte(moduleContent), 0644); err != nil {
+		t.Fatal("Failed to write module file:", err)
+	}
+
+	modules, err := nf.ProcessDirectory(tmpDir)
+	if err != nil {
+		t.Fatal("Failed to parse modules:", err)
+	}
+	configs, err := ProcessConfigDirectory(tmpDir)
+	if err != nil {
+		t.Fatal("Failed to parse configs:", err)
+	}
+
+	expected := map[string][]string{
+		"process_low":         {"FASTQC"},
+		"process_gpu":         {},
+		"FASTQC|MULTIQC":      {"FASTQC"},
+		".*:ALIGN:STAR_ALIGN": {"STAR_ALIGN"},
+		"OLD_NAME":            {},
+		// the ':' of groups doesn't separate qualified names
+		"(?:FASTQC|OLD_NAME)": {"FASTQC"},
+		"(?i:fastqc)":         {"FASTQC"},
+		"(?:OLD|NEW)_NAME":    {},
+	}
+
+	matches := MatchSelectors(modules, configs)
+	if len(matches) != len(expected) {
+		t.Fatalf("Expected %d selector matches, got %d", len(expected), len(matches))
+	}
+	for _, match := range matches {
+		want, ok := expected[match.Selector.Name]
+		if !ok {
+			t.Errorf("Unexpected selector %q", match.Selector.Name)
+			continue
+		}
+		if len(match.Processes) != len(want) {
+			t.Errorf("Selector %q: expected %d processes, got %d", match.Selector.Name, len(want), len(match.Processes))
+			continue
+		}
+		for i, process := range match.Processes {
+			if process.Name != want[i] {
+				t.Errorf("Selector %q: expected process %q, got %q", match.Selector.Name, want[i], process.Name)
+			}
+		}
+	}
+
+	uncovered := UncoveredLabels(modules, configs)
+	if len(uncovered) != 1 {
+		t.Fatalf("Expected 1 uncovered label, got %d", len(uncovered))
+	}
+	if uncovered[0].Label != "process_high" || uncovered[0].Process.Name != "STAR_ALIGN" {
+		t.Errorf("Expected label 'process_high' of STAR_ALIGN to be uncovered, got %q of %s", uncovered[0].Label, uncovered[0].Process.Name)
+	}
+}
+
+func TestMatchesSelector(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"FASTQC", "FASTQC", true},
+		{"FASTQC", "FASTQC_UMI", false},
+		{"GUNZIP_.*", "GUNZIP_GTF", true},
+		{"!FASTQC", "MULTIQC", true},
+		{"!FASTQC", "FASTQC", false},
+		// Java lookarounds, which Go's regexp doesn't support
+		{"(?!SAMTOOLS_).*_INDEX", "BWA_INDEX", true},
+		{"(?!SAMTOOLS_).*_INDEX", "SAMTOOLS_INDEX", false},
+		// must match the entire name, including before a trailing newline
+		{"FASTQC", "FASTQC\n", false},
+		// Java's \w and \d only match ASCII
+		{`\w+`, "FASTQC_2", true},
+		{`\w+`, "FASTQC_É", false},
+		{`SAMPLE_\d`, "SAMPLE_١", false},
+		// \Q...\E quotes
+		{`\QA.B\E.*`, "A.B_C", true},
+		{`\QA.B\E`, "AXB", false},
+		{`\QA.B`, "A.B", true},
+		// Java's POSIX classes, which regexp2 doesn't know
+		{`\p{Alpha}+_\p{Digit}`, "FASTQC_2", true},
+		{`[\p{Upper}\p{Punct}]+`, "FASTQC_UMI", true},
+		{`\p{Lower}+`, "FASTQC", false},
+		{`\P{Digit}+`, "FASTQC", true},
+		{`\Q\p{Alpha}\E`, `\p{Alpha}`, true},
+	}
+	for _, tt := range tests {
+		got, err := matchesSelector(tt.pattern, tt.name)
+		if err != nil {
+			t.Fatalf("matchesSelector(%q, %q) returned error: %v", tt.pattern, tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("matchesSelector(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateSelectors(t *testing.T) {
+	configContent := `
+process {
+    withName: 'FASTQC|MULTIQC' {
+        cpus = 2
+    }
+    withName: 'STAR_(ALIGN' {
+        cpus = 4
+    }
+    withLabel: '.*' {
+        cpus = 1
+    }
+}
+`
+	moduleContent := `
+process FASTQC {
+    label 'process_low'
+    script:
+    """
+    echo "test"
+    """
+}
+
+process MULTIQC {
+    label 'process_single'
+    script:
+    """
+    echo "test"
+    """
+}
+`
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "modules.config"), []byte(configContent), 0644); err != nil {
+		t.Fatal("Failed to write config file:", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.nf"), []byte(moduleContent), 0644); err != nil {
+		t.Fatal("Failed to write module file:", err)
+	}
+
+	modules, err := nf.ProcessDirectory(tmpDir)
+	if err != nil {
+		t.Fatal("Failed to parse modules:", err)
+	}
+	configs, err := ProcessConfigDirectory(tmpDir)
+	if err != nil {
+		t.Fatal("Failed to parse configs:", err)
+	}
+
+	invalid := ValidateSelectors(configs)
+	if len(invalid) != 1 || invalid[0].Selector.Name != "STAR_(ALIGN" || invalid[0].Selector.LineNumber != 6 {
+		t.Fatalf("Expected 'STAR_(ALIGN' on line 6 to be invalid, got %v", invalid)
+	}
+
+	results, err := ruleConfigSelectors(tmpDir, modules)
+	if err != nil {
+		t.Fatal("Failed to run rule:", err)
+	}
+	var errors, warnings []nf.Diagnostic
+	for _, d := range results[configs[0].Path].Diagnostics {
+		if d.Severity == nf.SeverityError {
+			errors = append(errors, d)
+		} else {
+			warnings = append(warnings, d)
+		}
+	}
+	if len(errors) != 1 || errors[0].Line != 6 || !strings.HasPrefix(errors[0].Message, "invalid withName selector 'STAR_(ALIGN'") {
+		t.Errorf("Expected an invalid selector error on line 6, got %v", errors)
+	}
+	// 'FASTQC|MULTIQC' lists the processes it selects, only '.*' is
+	// over-broad
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0].Message, "withLabel selector '.*'") {
+		t.Errorf("Expected a match-all warning for '.*', got %v", warnings)
+	}
+}
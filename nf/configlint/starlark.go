@@ -0,0 +1,351 @@
+package configlint
+
+import (
+	"fmt"
+	"reft-go/nf"
+
+	"go.starlark.net/starlark"
+)
+
+func init() {
+	nf.RegisterConfigLoader(loadStarlarkConfigs)
+}
+
+func loadStarlarkConfigs(dir string) ([]nf.StarlarkConfig, error) {
+	configs, err := ProcessConfigDirectory(dir)
+	if err != nil {
+		return nil, err
+	}
+	var starlarkConfigs []nf.StarlarkConfig
+	for _, config := range configs {
+		starlarkConfigs = append(starlarkConfigs, nf.StarlarkConfig{
+			Path:     config.Path,
+			Value:    &StarlarkConfigFile{config},
+			Comments: config.Comments,
+		})
+	}
+	return starlarkConfigs, nil
+}
+
+func starlarkList[T any](items []T, convert func(T) starlark.Value) *starlark.List {
+	elements := make([]starlark.Value, len(items))
+	for i, item := range items {
+		elements[i] = convert(item)
+	}
+	return starlark.NewList(elements)
+}
+
+func starlarkStrings(items []string) *starlark.List {
+	return starlarkList(items, func(s string) starlark.Value { return starlark.String(s) })
+}
+
+func starlarkDirectives(directives []Directive) *starlark.List {
+	return starlarkList(directives, func(d Directive) starlark.Value { return &StarlarkDirective{d} })
+}
+
+var _ starlark.Value = (*StarlarkConfigFile)(nil)
+var _ starlark.HasAttrs = (*StarlarkConfigFile)(nil)
+
+type StarlarkConfigFile struct {
+	*ConfigFile
+}
+
+func (c *StarlarkConfigFile) String() string {
+	return fmt.Sprintf("ConfigFile(%s)", c.Path)
+}
+
+func (c *StarlarkConfigFile) Type() string {
+	return "config_file"
+}
+
+func (c *StarlarkConfigFile) Freeze() {}
+
+func (c *StarlarkConfigFile) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (c *StarlarkConfigFile) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: config_file")
+}
+
+func (c *StarlarkConfigFile) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "path":
+		return starlark.String(c.Path), nil
+	case "process_scopes":
+		return starlarkList(c.ProcessScopes, func(p ProcessScope) starlark.Value { return &StarlarkProcessScope{p} }), nil
+	default:
+		return nil, fmt.Errorf("config_file has no attribute %q", name)
+	}
+}
+
+func (c *StarlarkConfigFile) AttrNames() []string {
+	return []string{"path", "process_scopes"}
+}
+
+var _ starlark.Value = (*StarlarkProcessScope)(nil)
+var _ starlark.HasAttrs = (*StarlarkProcessScope)(nil)
+
+type StarlarkProcessScope struct {
+	ProcessScope
+}
+
+func (p *StarlarkProcessScope) String() string {
+	return fmt.Sprintf("ProcessScope(line %d)", p.LineNumber)
+}
+
+func (p *StarlarkProcessScope) Type() string {
+	return "process_scope"
+}
+
+func (p *StarlarkProcessScope) Freeze() {}
+
+func (p *StarlarkProcessScope) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (p *StarlarkProcessScope) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: process_scope")
+}
+
+func (p *StarlarkProcessScope) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "line_number":
+		return starlark.MakeInt(p.LineNumber), nil
+	case "directives":
+		return starlarkDirectives(p.Directives), nil
+	case "named_scopes":
+		return starlarkList(p.NamedScopes, func(n NamedScope) starlark.Value { return &StarlarkNamedScope{n} }), nil
+	default:
+		return nil, fmt.Errorf("process_scope has no attribute %q", name)
+	}
+}
+
+func (p *StarlarkProcessScope) AttrNames() []string {
+	return []string{"line_number", "directives", "named_scopes"}
+}
+
+var _ starlark.Value = (*StarlarkNamedScope)(nil)
+var _ starlark.HasAttrs = (*StarlarkNamedScope)(nil)
+
+type StarlarkNamedScope struct {
+	NamedScope
+}
+
+func (n *StarlarkNamedScope) String() string {
+	return fmt.Sprintf("NamedScope(%s: %s)", n.Kind, n.Name)
+}
+
+func (n *StarlarkNamedScope) Type() string {
+	return "named_scope"
+}
+
+func (n *StarlarkNamedScope) Freeze() {}
+
+func (n *StarlarkNamedScope) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (n *StarlarkNamedScope) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: named_scope")
+}
+
+func (n *StarlarkNamedScope) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "line_number":
+		return starlark.MakeInt(n.LineNumber), nil
+	case "kind":
+		return starlark.String(n.Kind), nil
+	case "name":
+		return starlark.String(n.Name), nil
+	case "directives":
+		return starlarkDirectives(n.Directives), nil
+	case "pattern_error":
+		if _, err := compileSelectorCached(n.Name); err != nil {
+			return starlark.String(err.Error()), nil
+		}
+		return starlark.None, nil
+	case "matches":
+		return starlark.NewBuiltin("matches", n.matches), nil
+	default:
+		return nil, fmt.Errorf("named_scope has no attribute %q", name)
+	}
+}
+
+func (n *StarlarkNamedScope) AttrNames() []string {
+	return []string{"line_number", "kind", "name", "directives", "pattern_error", "matches"}
+}
+
+// matches(name) reports whether the selector applies to a process name or label
+func (n *StarlarkNamedScope) matches(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	if err := starlark.UnpackPositionalArgs("matches", args, kwargs, 1, &name); err != nil {
+		return nil, err
+	}
+	matched, err := matchesSelector(n.Name, name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector '%s': %v", n.Name, err)
+	}
+	return starlark.Bool(matched), nil
+}
+
+var _ starlark.Value = (*StarlarkDirective)(nil)
+var _ starlark.HasAttrs = (*StarlarkDirective)(nil)
+
+type StarlarkDirective struct {
+	Directive
+}
+
+func (d *StarlarkDirective) String() string {
+	return fmt.Sprintf("Directive(%s)", d.Name)
+}
+
+func (d *StarlarkDirective) Type() string {
+	return "directive_config"
+}
+
+func (d *StarlarkDirective) Freeze() {}
+
+func (d *StarlarkDirective) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (d *StarlarkDirective) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: directive_config")
+}
+
+func (d *StarlarkDirective) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "line_number":
+		return starlark.MakeInt(d.LineNumber), nil
+	case "name":
+		return starlark.String(d.Name), nil
+	case "options":
+		return starlarkList(d.Options, func(o NamedOption) starlark.Value { return &StarlarkNamedOption{o} }), nil
+	case "value":
+		return &StarlarkDirectiveValue{d.Value}, nil
+	default:
+		return nil, fmt.Errorf("directive_config has no attribute %q", name)
+	}
+}
+
+func (d *StarlarkDirective) AttrNames() []string {
+	return []string{"line_number", "name", "options", "value"}
+}
+
+var _ starlark.Value = (*StarlarkNamedOption)(nil)
+var _ starlark.HasAttrs = (*StarlarkNamedOption)(nil)
+
+type StarlarkNamedOption struct {
+	NamedOption
+}
+
+func (o *StarlarkNamedOption) String() string {
+	return fmt.Sprintf("NamedOption(%s)", o.Name)
+}
+
+func (o *StarlarkNamedOption) Type() string {
+	return "named_option"
+}
+
+func (o *StarlarkNamedOption) Freeze() {}
+
+func (o *StarlarkNamedOption) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (o *StarlarkNamedOption) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: named_option")
+}
+
+func (o *StarlarkNamedOption) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "line_number":
+		return starlark.MakeInt(o.LineNumber), nil
+	case "name":
+		return starlark.String(o.Name), nil
+	case "value":
+		return &StarlarkDirectiveValue{o.Value}, nil
+	default:
+		return nil, fmt.Errorf("named_option has no attribute %q", name)
+	}
+}
+
+func (o *StarlarkNamedOption) AttrNames() []string {
+	return []string{"line_number", "name", "value"}
+}
+
+var _ starlark.Value = (*StarlarkDirectiveValue)(nil)
+var _ starlark.HasAttrs = (*StarlarkDirectiveValue)(nil)
+
+/*
+StarlarkDirectiveValue exposes the params used by a value along with its
+typed form: 'kind' is one of the ValueKind names and 'value' is the
+value converted to Starlark. Memory amounts are in bytes, durations in
+milliseconds, and closures and expressions are their source text.
+*/
+type StarlarkDirectiveValue struct {
+	DirectiveValue
+}
+
+func (v *StarlarkDirectiveValue) String() string {
+	return fmt.Sprintf("DirectiveValue(%s)", v.Value.String())
+}
+
+func (v *StarlarkDirectiveValue) Type() string {
+	return "directive_value"
+}
+
+func (v *StarlarkDirectiveValue) Freeze() {}
+
+func (v *StarlarkDirectiveValue) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (v *StarlarkDirectiveValue) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: directive_value")
+}
+
+func (v *StarlarkDirectiveValue) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "params":
+		return starlarkStrings(v.Params), nil
+	case "in_closure":
+		return starlark.Bool(v.InClosure), nil
+	case "kind":
+		return starlark.String(v.Value.Kind.String()), nil
+	case "value":
+		return toStarlarkValue(v.Value), nil
+	case "text":
+		return starlark.String(v.Value.String()), nil
+	default:
+		return nil, fmt.Errorf("directive_value has no attribute %q", name)
+	}
+}
+
+func (v *StarlarkDirectiveValue) AttrNames() []string {
+	return []string{"params", "in_closure", "kind", "value", "text"}
+}
+
+func toStarlarkValue(v Value) starlark.Value {
+	switch v.Kind {
+	case NullValue:
+		return starlark.None
+	case StringValue, ParamValue:
+		return starlark.String(v.Str)
+	case NumberValue, MemoryValue, DurationValue:
+		return starlark.Float(v.Num)
+	case BoolValue:
+		return starlark.Bool(v.Bool)
+	case ListValue:
+		return starlarkList(v.Items, toStarlarkValue)
+	case MapValue:
+		dict := starlark.NewDict(len(v.Entries))
+		for _, entry := range v.Entries {
+			_ = dict.SetKey(starlark.String(entry.Key), toStarlarkValue(entry.Value))
+		}
+		return dict
+	default:
+		return starlark.String(v.Source)
+	}
+}
@@ -0,0 +1,59 @@
+package configlint
+
+import (
+	"os"
+	"path/filepath"
+	"reft-go/nf"
+	"strings"
+	"testing"
+)
+
+func TestConfigRules(t *testing.T) {
+	rulesContent := `
+def configrule_params_in_closures(config):
+    for scope in config.process_scopes:
+        for named_scope in scope.named_scopes:
+            for directive in named_scope.directives:
+                if directive.value.params and not directive.value.in_closure:
+                    error("%s uses params outside a closure" % directive.name, line=directive.line_number, code="params-in-closure")
+
+def configrule_max_memory(config):
+    for scope in config.process_scopes:
+        for directive in scope.directives:
+            if directive.value.kind == "memory" and directive.value.value > 64 * 1024 * 1024 * 1024:
+                warning("memory %s is above 64 GB" % directive.value.text, line=directive.line_number)
+`
+	configContent := `
+process {
+    memory = 72.GB
+    withName: 'FASTQC' {
+        ext.args = params.fastqc_args
+    }
+}
+`
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	if err := os.WriteFile(rulesFile, []byte(rulesContent), 0644); err != nil {
+		t.Fatal("Failed to write rules file:", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "nextflow.config"), []byte(configContent), 0644); err != nil {
+		t.Fatal("Failed to write config file:", err)
+	}
+
+	var output strings.Builder
+	err := nf.RunLintWithConfig(nf.LintConfig{RulesFile: rulesFile, Directory: tmpDir}, &output)
+	if err == nil {
+		t.Fatal("Expected linting to fail, but it succeeded")
+	}
+
+	for _, expected := range []string{
+		"Rule: params_in_closures",
+		"Error: " + filepath.Join(tmpDir, "nextflow.config") + ":5: ext.args uses params outside a closure [params-in-closure]",
+		"Rule: max_memory",
+		"Warning: " + filepath.Join(tmpDir, "nextflow.config") + ":3: memory 72.GB is above 64 GB",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Expected output to contain %q, but got:\n%s", expected, output.String())
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package configlint
+
+import (
+	"fmt"
+	"math/big"
+	"reft-go/parser"
+	"regexp"
+	"strconv"
+	"strings"
+
+	pb "reft-go/nf/proto"
+)
+
+type ValueKind int
+
+const (
+	// ExpressionValue is anything that can't be evaluated statically.
+	// Its source text is kept in Value.Source.
+	ExpressionValue ValueKind = iota
+	NullValue
+	StringValue
+	NumberValue
+	BoolValue
+	MemoryValue
+	DurationValue
+	ListValue
+	MapValue
+	ClosureValue
+	ParamValue
+)
+
+func (k ValueKind) String() string {
+	switch k {
+	case NullValue:
+		return "null"
+	case StringValue:
+		return "string"
+	case NumberValue:
+		return "number"
+	case BoolValue:
+		return "bool"
+	case MemoryValue:
+		return "memory"
+	case DurationValue:
+		return "duration"
+	case ListValue:
+		return "list"
+	case MapValue:
+		return "map"
+	case ClosureValue:
+		return "closure"
+	case ParamValue:
+		return "param"
+	default:
+		return "expression"
+	}
+}
+
+// Value is the typed form of a config value.
+type Value struct {
+	Kind ValueKind
+	// The string for StringValue, the param name for ParamValue
+	Str string
+	// The number for NumberValue, bytes for MemoryValue and
+	// milliseconds for DurationValue
+	Num     float64
+	Bool    bool
+	Items   []Value
+	Entries []MapEntry
+	// Source text for closures, memory and duration values, and expressions
+	Source string
+}
+
+type MapEntry struct {
+	Key   string
+	Value Value
+}
+
+var memoryUnits = map[string]float64{
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+	"TB": 1 << 40,
+	"PB": 1 << 50,
+	"EB": 1 << 60,
+	"ZB": 1 << 70,
+}
+
+var durationUnits = map[string]float64{
+	"ms":      1,
+	"milli":   1,
+	"millis":  1,
+	"s":       1000,
+	"sec":     1000,
+	"second":  1000,
+	"seconds": 1000,
+	"m":       60 * 1000,
+	"min":     60 * 1000,
+	"minute":  60 * 1000,
+	"minutes": 60 * 1000,
+	"h":       60 * 60 * 1000,
+	"hour":    60 * 60 * 1000,
+	"hours":   60 * 60 * 1000,
+	"d":       24 * 60 * 60 * 1000,
+	"day":     24 * 60 * 60 * 1000,
+	"days":    24 * 60 * 60 * 1000,
+}
+
+// directives whose string values are memory amounts or durations
+var memoryDirectives = map[string]bool{"memory": true, "disk": true}
+var durationDirectives = map[string]bool{"time": true, "maxSubmitAwait": true}
+
+var memoryRegex = regexp.MustCompile(`^\s*([0-9]+(?:\.[0-9]+)?)\s*\.?\s*([KMGTPEZ]?B)\s*$`)
+var durationRegex = regexp.MustCompile(`([0-9]+(?:\.[0-9]+)?)\s*\.?\s*([a-z]+)`)
+
+// ParseMemory converts a Nextflow memory string like '8 GB' or '8.GB' to bytes.
+func ParseMemory(text string) (float64, bool) {
+	match := memoryRegex.FindStringSubmatch(text)
+	if match == nil {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return value * memoryUnits[match[2]], true
+}
+
+// ParseDuration converts a Nextflow duration string like '2h' or '1d 6h' to milliseconds.
+func ParseDuration(text string) (float64, bool) {
+	text = strings.TrimSpace(text)
+	matches := durationRegex.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return 0, false
+	}
+	total := 0.0
+	end := 0
+	for _, match := range matches {
+		// only whitespace may separate the components
+		if strings.TrimSpace(text[end:match[0]]) != "" {
+			return 0, false
+		}
+		value, err := strconv.ParseFloat(text[match[2]:match[3]], 64)
+		if err != nil {
+			return 0, false
+		}
+		unit, ok := durationUnits[text[match[4]:match[5]]]
+		if !ok {
+			return 0, false
+		}
+		total += value * unit
+		end = match[1]
+	}
+	if strings.TrimSpace(text[end:]) != "" {
+		return 0, false
+	}
+	return total, true
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return f, true
+	case *big.Float:
+		f, _ := n.Float64()
+		return f, true
+	}
+	return 0, false
+}
+
+func sourceText(module *parser.ModuleNode, node parser.ASTNodeNoVisit) string {
+	if module != nil && module.Source != "" {
+		if text := module.SourceText(node); text != "" {
+			return text
+		}
+	}
+	return node.GetText()
+}
+
+// makeValue builds the typed value of a config expression.
+// module is used to recover source text and may be nil.
+func makeValue(expr parser.Expression, module *parser.ModuleNode) Value {
+	switch e := expr.(type) {
+	case *parser.ConstantExpression:
+		v := e.GetValue()
+		if v == nil {
+			return Value{Kind: NullValue}
+		}
+		if s, ok := v.(string); ok {
+			return Value{Kind: StringValue, Str: s}
+		}
+		if b, ok := v.(bool); ok {
+			return Value{Kind: BoolValue, Bool: b}
+		}
+		if f, ok := toFloat(v); ok {
+			return Value{Kind: NumberValue, Num: f}
+		}
+	case *parser.GStringExpression:
+		if e.IsConstantString() {
+			return Value{Kind: StringValue, Str: e.GetText()}
+		}
+	case *parser.UnaryMinusExpression:
+		inner := makeValue(e.GetExpression(), module)
+		if inner.Kind == NumberValue || inner.Kind == MemoryValue || inner.Kind == DurationValue {
+			inner.Num = -inner.Num
+			if inner.Kind != NumberValue {
+				inner.Source = sourceText(module, e)
+			}
+			return inner
+		}
+	case *parser.PropertyExpression:
+		object := e.GetObjectExpression()
+		property := e.GetPropertyAsString()
+		if object.GetText() == "params" {
+			return Value{Kind: ParamValue, Str: property}
+		}
+		if constExpr, ok := object.(*parser.ConstantExpression); ok {
+			if f, ok := toFloat(constExpr.GetValue()); ok {
+				if unit, ok := memoryUnits[property]; ok {
+					return Value{Kind: MemoryValue, Num: f * unit, Source: sourceText(module, e)}
+				}
+				if unit, ok := durationUnits[property]; ok {
+					return Value{Kind: DurationValue, Num: f * unit, Source: sourceText(module, e)}
+				}
+			}
+		}
+	case *parser.ListExpression:
+		items := []Value{}
+		for _, item := range e.GetExpressions() {
+			items = append(items, makeValue(item, module))
+		}
+		return Value{Kind: ListValue, Items: items}
+	case *parser.MapExpression:
+		entries := []MapEntry{}
+		for _, entry := range e.GetMapEntryExpressions() {
+			key := entry.GetKeyExpression().GetText()
+			if constExpr, ok := entry.GetKeyExpression().(*parser.ConstantExpression); ok {
+				if s, ok := constExpr.GetValue().(string); ok {
+					key = s
+				}
+			}
+			entries = append(entries, MapEntry{
+				Key:   key,
+				Value: coerceValue(key, makeValue(entry.GetValueExpression(), module)),
+			})
+		}
+		return Value{Kind: MapValue, Entries: entries}
+	case *parser.ClosureExpression:
+		return Value{Kind: ClosureValue, Source: sourceText(module, e)}
+	}
+	return Value{Kind: ExpressionValue, Source: sourceText(module, expr)}
+}
+
+// coerceValue interprets string values of memory and time directives,
+// e.g. memory = '8 GB', as memory amounts and durations.
+func coerceValue(name string, value Value) Value {
+	if value.Kind != StringValue {
+		return value
+	}
+	if memoryDirectives[name] {
+		if bytes, ok := ParseMemory(value.Str); ok {
+			return Value{Kind: MemoryValue, Num: bytes, Source: value.Str}
+		}
+	}
+	if durationDirectives[name] {
+		if millis, ok := ParseDuration(value.Str); ok {
+			return Value{Kind: DurationValue, Num: millis, Source: value.Str}
+		}
+	}
+	return value
+}
+
+func (v *Value) String() string {
+	switch v.Kind {
+	case NullValue:
+		return "null"
+	case StringValue:
+		return strconv.Quote(v.Str)
+	case NumberValue:
+		return strconv.FormatFloat(v.Num, 'g', -1, 64)
+	case BoolValue:
+		return strconv.FormatBool(v.Bool)
+	case ParamValue:
+		return "params." + v.Str
+	case ListValue:
+		items := make([]string, len(v.Items))
+		for i, item := range v.Items {
+			items[i] = item.String()
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case MapValue:
+		entries := make([]string, len(v.Entries))
+		for i, entry := range v.Entries {
+			entries[i] = fmt.Sprintf("%s: %s", entry.Key, entry.Value.String())
+		}
+		return "[" + strings.Join(entries, ", ") + "]"
+	default:
+		return v.Source
+	}
+}
+
+func (v *Value) ToProto() *pb.ConfigValue {
+	protoValue := &pb.ConfigValue{}
+	switch v.Kind {
+	case NullValue:
+		protoValue.Value = &pb.ConfigValue_NullValue{NullValue: true}
+	case StringValue:
+		protoValue.Value = &pb.ConfigValue_StringValue{StringValue: v.Str}
+	case NumberValue:
+		protoValue.Value = &pb.ConfigValue_NumberValue{NumberValue: v.Num}
+	case BoolValue:
+		protoValue.Value = &pb.ConfigValue_BoolValue{BoolValue: v.Bool}
+	case MemoryValue:
+		protoValue.Value = &pb.ConfigValue_Memory{Memory: &pb.MemoryValue{Bytes: v.Num, Text: v.Source}}
+	case DurationValue:
+		protoValue.Value = &pb.ConfigValue_Duration{Duration: &pb.DurationValue{Millis: v.Num, Text: v.Source}}
+	case ListValue:
+		list := &pb.ListValue{}
+		for _, item := range v.Items {
+			list.Items = append(list.Items, item.ToProto())
+		}
+		protoValue.Value = &pb.ConfigValue_List{List: list}
+	case MapValue:
+		m := &pb.MapValue{}
+		for _, entry := range v.Entries {
+			m.Entries = append(m.Entries, &pb.MapEntry{Key: entry.Key, Value: entry.Value.ToProto()})
+		}
+		protoValue.Value = &pb.ConfigValue_Map{Map: m}
+	case ClosureValue:
+		protoValue.Value = &pb.ConfigValue_Closure{Closure: &pb.ClosureValue{Source: v.Source}}
+	case ParamValue:
+		protoValue.Value = &pb.ConfigValue_Param{Param: v.Str}
+	default:
+		protoValue.Value = &pb.ConfigValue_Expression{Expression: v.Source}
+	}
+	return protoValue
+}
@@ -9,6 +9,16 @@ import (
 )
 
 func ParseConfig(block *parser.BlockStatement) []ProcessScope {
+	return parseConfig(block, nil)
+}
+
+// ParseConfigModule is like ParseConfig but keeps the source text of
+// closures and expressions in directive values.
+func ParseConfigModule(ast *parser.ModuleNode) []ProcessScope {
+	return parseConfig(ast.StatementBlock, ast)
+}
+
+func parseConfig(block *parser.BlockStatement, module *parser.ModuleNode) []ProcessScope {
 	// Create and use ProcessVisitor
 	processVisitor := NewProcessScopeVisitor()
 	processVisitor.VisitBlockStatement(block)
@@ -18,14 +28,14 @@ func ParseConfig(block *parser.BlockStatement) []ProcessScope {
 
 	var scopes []ProcessScope
 	for _, processScope := range processScopes {
-		scopes = append(scopes, makeProcessScope(processScope.First, processScope.Second))
+		scopes = append(scopes, makeProcessScope(processScope.First, processScope.Second, module))
 	}
 	return scopes
 }
 
-func makeProcessScope(lineNumber int, closure *parser.ClosureExpression) ProcessScope {
-	directives := getDirectives(closure)
-	namedScopes := getNamedScopes(closure)
+func makeProcessScope(lineNumber int, closure *parser.ClosureExpression, module *parser.ModuleNode) ProcessScope {
+	directives := getDirectives(closure, module)
+	namedScopes := getNamedScopes(closure, module)
 	return ProcessScope{
 		LineNumber:  lineNumber,
 		Directives:  directives,
@@ -33,7 +43,7 @@ func makeProcessScope(lineNumber int, closure *parser.ClosureExpression) Process
 	}
 }
 
-func getDirectives(closure *parser.ClosureExpression) []Directive {
+func getDirectives(closure *parser.ClosureExpression, module *parser.ModuleNode) []Directive {
 	directiveVisitor := NewDirectiveVisitor()
 	directiveVisitor.VisitClosureExpression(closure)
 	var directives []Directive
@@ -43,23 +53,26 @@ func getDirectives(closure *parser.ClosureExpression) []Directive {
 			Name:       name,
 		}
 		directiveBodyVisitor := NewDirectiveBodyVisitor()
+		directiveBodyVisitor.module = module
 		directiveBodyVisitor.VisitExpression(pair.Second)
 		directive.Options = directiveBodyVisitor.namedOptions
 		directive.Value = directiveBodyVisitor.value
+		directive.Value.Value = coerceValue(name, directive.Value.Value)
 		directives = append(directives, directive)
 	}
 	return directives
 }
 
-func getNamedScopes(closure *parser.ClosureExpression) []NamedScope {
+func getNamedScopes(closure *parser.ClosureExpression, module *parser.ModuleNode) []NamedScope {
 	namedScopeVisitor := NewNamedScopeVisitor()
 	namedScopeVisitor.VisitClosureExpression(closure)
 	var namedScopes []NamedScope
 	for _, triple := range namedScopeVisitor.namedScopes {
 		namedScopes = append(namedScopes, NamedScope{
 			LineNumber: triple.First,
-			Name:       triple.Second,
-			Directives: getDirectives(triple.Third),
+			Kind:       triple.Second.First,
+			Name:       triple.Second.Second,
+			Directives: getDirectives(triple.Third, module),
 		})
 	}
 	return namedScopes
@@ -69,6 +82,8 @@ type DirectiveValue struct {
 	Params     []string
 	InClosure  bool
 	Expression parser.Expression
+	// Value is the typed form of Expression
+	Value Value
 }
 
 type NamedOption struct {
@@ -84,8 +99,16 @@ type Directive struct {
 	Value      DirectiveValue
 }
 
+// Selector kinds of a NamedScope
+const (
+	WithName  = "withName"
+	WithLabel = "withLabel"
+)
+
 type NamedScope struct {
 	LineNumber int
+	// Kind is WithName or WithLabel
+	Kind       string
 	Name       string
 	Directives []Directive
 }
@@ -130,6 +153,10 @@ func (n *NamedScope) ToProto() *pb.NamedScope {
 	protoNamedScope := &pb.NamedScope{
 		LineNumber: int32(n.LineNumber),
 		Name:       n.Name,
+		Kind:       n.Kind,
+	}
+	if _, err := compileSelectorCached(n.Name); err != nil {
+		protoNamedScope.PatternError = err.Error()
 	}
 
 	for _, directive := range n.Directives {
@@ -151,9 +178,34 @@ func (d *DirectiveValue) ToProto() *pb.DirectiveValue {
 	return &pb.DirectiveValue{
 		Params:    d.Params,
 		InClosure: d.InClosure,
+		Value:     d.Value.ToProto(),
 	}
 }
 
+// ConfigParam is a params assignment, e.g. params.max_cpus = 16
+// or max_cpus = 16 inside a params block.
+type ConfigParam struct {
+	LineNumber int
+	Name       string
+	Value      Value
+}
+
+// ParseConfigParams returns the params assigned in a config file.
+// module is used to recover source text and may be nil.
+func ParseConfigParams(block *parser.BlockStatement, module *parser.ModuleNode) []ConfigParam {
+	visitor := NewParamsVisitor()
+	visitor.VisitBlockStatement(block)
+	var params []ConfigParam
+	for _, triple := range visitor.params {
+		params = append(params, ConfigParam{
+			LineNumber: triple.First,
+			Name:       triple.Second,
+			Value:      makeValue(triple.Third, module),
+		})
+	}
+	return params
+}
+
 type Pair[F any, S any] struct {
 	First  F
 	Second S
@@ -236,6 +288,8 @@ type DirectiveBodyVisitor struct {
 	*nf.BaseVisitor
 	namedOptions []NamedOption
 	value        DirectiveValue
+	// used for source text, may be nil
+	module *parser.ModuleNode
 }
 
 func NewDirectiveBodyVisitor() *DirectiveBodyVisitor {
@@ -246,7 +300,8 @@ func NewDirectiveBodyVisitor() *DirectiveBodyVisitor {
 			for _, entry := range exprs {
 				name := entry.GetKeyExpression().GetText()
 				value := entry.GetValueExpression()
-				directiveValue := getDirectiveValue(value)
+				directiveValue := getDirectiveValue(value, v.module)
+				directiveValue.Value = coerceValue(name, directiveValue.Value)
 				v.namedOptions = append(v.namedOptions, NamedOption{
 					LineNumber: entry.GetLineNumber(),
 					Name:       name,
@@ -254,13 +309,13 @@ func NewDirectiveBodyVisitor() *DirectiveBodyVisitor {
 				})
 			}
 		} else {
-			v.value = getDirectiveValue(expr)
+			v.value = getDirectiveValue(expr, v.module)
 		}
 	}
 	return v
 }
 
-func getDirectiveValue(expr parser.Expression) DirectiveValue {
+func getDirectiveValue(expr parser.Expression, module *parser.ModuleNode) DirectiveValue {
 	visitor := NewDirectiveValueVisitor()
 	visitor.VisitExpression(expr)
 	inClosure := true
@@ -284,6 +339,7 @@ func getDirectiveValue(expr parser.Expression) DirectiveValue {
 		Params:     params,
 		InClosure:  inClosure,
 		Expression: expr,
+		Value:      makeValue(expr, module),
 	}
 	return directiveValue
 }
@@ -311,14 +367,15 @@ func NewDirectiveValueVisitor() *DirectiveValueVisitor {
 
 type NamedScopeVisitor struct {
 	*nf.BaseVisitor
-	namedScopes []Triple[int, string, *parser.ClosureExpression]
+	// line -> (kind, name) -> closure
+	namedScopes []Triple[int, Pair[string, string], *parser.ClosureExpression]
 }
 
 func NewNamedScopeVisitor() *NamedScopeVisitor {
 	v := &NamedScopeVisitor{BaseVisitor: nf.NewBaseVisitor()}
 	v.VisitExpressionStatementHook = func(expr *parser.ExpressionStatement) {
 		label := expr.GetStatementLabel()
-		if label == "withName" {
+		if label == WithName || label == WithLabel {
 			if mce, ok := expr.GetExpression().(*parser.MethodCallExpression); ok {
 				name := mce.GetMethod().GetText()
 				if argList, ok := mce.GetArguments().(*parser.ArgumentListExpression); ok {
@@ -326,9 +383,9 @@ func NewNamedScopeVisitor() *NamedScopeVisitor {
 					if len(args) == 1 {
 						arg := args[0]
 						if closure, ok := arg.(*parser.ClosureExpression); ok {
-							v.namedScopes = append(v.namedScopes, Triple[int, string, *parser.ClosureExpression]{
+							v.namedScopes = append(v.namedScopes, Triple[int, Pair[string, string], *parser.ClosureExpression]{
 								First:  expr.GetLineNumber(),
-								Second: name,
+								Second: Pair[string, string]{label, name},
 								Third:  closure,
 							})
 						}
@@ -339,3 +396,58 @@ func NewNamedScopeVisitor() *NamedScopeVisitor {
 	}
 	return v
 }
+
+type ParamsVisitor struct {
+	*nf.BaseVisitor
+	// line -> name -> value
+	params []Triple[int, string, parser.Expression]
+}
+
+func NewParamsVisitor() *ParamsVisitor {
+	v := &ParamsVisitor{BaseVisitor: nf.NewBaseVisitor()}
+	v.VisitBinaryExpressionHook = func(expr *parser.BinaryExpression) {
+		// params.name = value
+		if expr.GetOperation().GetText() == "=" {
+			if prop, ok := expr.GetLeftExpression().(*parser.PropertyExpression); ok {
+				if prop.GetObjectExpression().GetText() == "params" {
+					v.params = append(v.params, Triple[int, string, parser.Expression]{
+						First:  expr.GetLineNumber(),
+						Second: prop.GetPropertyAsString(),
+						Third:  expr.GetRightExpression(),
+					})
+				}
+			}
+		}
+	}
+	v.VisitMethodCallExpressionHook = func(call *parser.MethodCallExpression) {
+		// params { name = value }
+		if call.GetMethod().GetText() == "params" {
+			if args, ok := call.GetArguments().(*parser.ArgumentListExpression); ok && len(args.GetExpressions()) == 1 {
+				if closure, ok := args.GetExpressions()[0].(*parser.ClosureExpression); ok {
+					if bs, ok := closure.GetCode().(*parser.BlockStatement); ok {
+						for _, stmt := range bs.GetStatements() {
+							exprStmt, ok := stmt.(*parser.ExpressionStatement)
+							if !ok {
+								continue
+							}
+							binaryExpr, ok := exprStmt.GetExpression().(*parser.BinaryExpression)
+							if !ok || binaryExpr.GetOperation().GetText() != "=" {
+								continue
+							}
+							v.params = append(v.params, Triple[int, string, parser.Expression]{
+								First:  binaryExpr.GetLineNumber(),
+								Second: binaryExpr.GetLeftExpression().GetText(),
+								Third:  binaryExpr.GetRightExpression(),
+							})
+						}
+					}
+					return
+				}
+			}
+		}
+		v.VisitExpression(call.GetObjectExpression())
+		v.VisitExpression(call.GetMethod())
+		v.VisitExpression(call.GetArguments())
+	}
+	return v
+}
@@ -0,0 +1,8 @@
+package corelint
+
+import "embed"
+
+// The long-form documentation of the rules, shown by `reft rules explain`
+//
+//go:embed docs/*.md
+var docs embed.FS
@@ -0,0 +1,37 @@
+package corelint
+
+import (
+	"fmt"
+	"reft-go/nf"
+	"reft-go/nf/directives"
+)
+
+// The echo directive was renamed to debug in Nextflow 22.04
+func ruleEchoDirective(module *nf.Module) LintResults {
+	results := LintResults{
+		ModulePath: module.Path,
+		Errors:     []ModuleError{},
+		Warnings:   []ModuleWarning{},
+	}
+
+	for _, process := range module.Processes {
+		for _, directive := range process.Directives {
+			if echo, ok := directive.(*directives.EchoDirective); ok {
+				var fix *nf.Fix
+				if node := process.DirectiveNode(echo); node != nil {
+					fix = &nf.Fix{
+						Description: "replace echo with debug",
+						Edits:       []nf.TextEdit{{Span: nf.NameSpan(node), NewText: "debug"}},
+					}
+				}
+				results.Warnings = append(results.Warnings, ModuleWarning{
+					Warning: fmt.Sprintf("process '%s' uses the deprecated echo directive, use debug instead", process.Name),
+					Line:    echo.Line(),
+					Fix:     fix,
+				})
+			}
+		}
+	}
+
+	return results
+}
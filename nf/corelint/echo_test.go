@@ -0,0 +1,40 @@
+package corelint
+
+import (
+	"os"
+	"path/filepath"
+	"reft-go/nf"
+	"strings"
+	"testing"
+)
+
+func TestRuleEchoDirective(t *testing.T) {
+	processContent := `
+process FOO {
+    echo true
+    script:
+    """
+    echo "test"
+    """
+}
+`
+	tmpDir := t.TempDir()
+	processFile := filepath.Join(tmpDir, "process.nf")
+	if err := os.WriteFile(processFile, []byte(processContent), 0644); err != nil {
+		t.Fatal("Failed to write process file:", err)
+	}
+
+	module, err, _ := nf.BuildModule(processFile)
+	if err != nil {
+		t.Fatal("Failed to parse process file:", err)
+	}
+
+	results := ruleEchoDirective(module)
+	if len(results.Warnings) != 1 || results.Warnings[0].Fix == nil {
+		t.Fatalf("Expected one fixable warning, got %+v", results.Warnings)
+	}
+	fixed, _, _ := nf.ApplyFixes(processContent, []*nf.Fix{results.Warnings[0].Fix})
+	if !strings.Contains(fixed, "    debug true\n") {
+		t.Errorf("Expected echo to be replaced with debug, got:\n%s", fixed)
+	}
+}
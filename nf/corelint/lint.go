@@ -1,29 +1,57 @@
 package corelint
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
 	"reft-go/nf"
 )
 
 /*
 NFCoreLint runs the nf-core linting rules on the given directory.
 
-ProcessDirectory() parses the Nextflow DSL. The errors returned by
-this function are not linting errors, but errors parsing the DSL.
+ParseDirectory() parses the Nextflow DSL. The errors it returns for a
+module are not linting errors, but errors parsing the DSL. They are
+reported under nf.ParseErrorRule and the rules run on the other modules.
 For instance, a container named "ubuntu " would be rejected as a matter
 of policy. But a container named "ubuntu"latest"" is always wrong at the DSL level.
 
 Some of these linting rules could be implemented in user-space (rules.py).
 But they are implemented here to avoid forcing users to define a rules.py
 for common cases.
+
+The rules are configured by reftrace.yml and the lint section of
+.nf-core.yml, see LoadSettings.
 */
 func NFCoreLint(directory string) ([]LintResults, error) {
 	results := []LintResults{}
 
-	modules, err := nf.ProcessDirectory(directory)
+	settings, warnings, err := LoadSettings(directory)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	modules, parseErrors, err := nf.ParseDirectory(directory)
 	if err != nil {
 		return nil, err
 	}
 
+	for _, parseErr := range parseErrors {
+		if !settings.RuleEnabled(nf.ParseErrorRule) || !settings.Applies(nf.ParseErrorRule, parseErr.Path) {
+			continue
+		}
+		d := parseErr.Diagnostic()
+		results = append(results, LintResults{
+			ModulePath: parseErr.Path,
+			Errors:     []ModuleError{{Error: errors.New(d.Message), Line: d.Line, Rule: nf.ParseErrorRule}},
+			Warnings:   make([]ModuleWarning, 0),
+		})
+	}
+
 	// Run all rules and merge their results
 	for _, module := range modules {
 		// Create a moduleResults to collect all rule results for this module
@@ -33,11 +61,45 @@ func NFCoreLint(directory string) ([]LintResults, error) {
 			Warnings:   make([]ModuleWarning, 0),
 		}
 
+		suppressions := nf.ParseSuppressions(module.Path, module.Comments)
+
 		// Run all rules and merge their results
-		for _, rule := range moduleRules {
-			ruleResult := rule(module)
-			moduleResults.Errors = append(moduleResults.Errors, ruleResult.Errors...)
-			moduleResults.Warnings = append(moduleResults.Warnings, ruleResult.Warnings...)
+		for _, rule := range nfcoreRules() {
+			if !settings.RuleEnabled(rule.ID) || !settings.Applies(rule.ID, module.Path) {
+				continue
+			}
+			severity := settings.Rule(rule.ID).Severity
+			if severity == "" {
+				severity = string(rule.Severity)
+			}
+			ruleResult := overrideSeverity(rule.Check(module), severity)
+			for _, moduleError := range ruleResult.Errors {
+				moduleError.Rule = rule.ID
+				if !suppressions.Suppressed(rule.ID, nf.Diagnostic{Line: moduleError.Line}) {
+					moduleResults.Errors = append(moduleResults.Errors, moduleError)
+				}
+			}
+			for _, warning := range ruleResult.Warnings {
+				warning.Rule = rule.ID
+				if !suppressions.Suppressed(rule.ID, nf.Diagnostic{Line: warning.Line}) {
+					moduleResults.Warnings = append(moduleResults.Warnings, warning)
+				}
+			}
+		}
+
+		ran := func(name string) bool {
+			return isModuleRule(name) && (name == "" || settings.RuleEnabled(name) && settings.Applies(name, module.Path))
+		}
+		var unusedSuppressions []nf.Diagnostic
+		if settings.RuleEnabled(nf.UnusedSuppressionRule) && settings.Applies(nf.UnusedSuppressionRule, module.Path) {
+			unusedSuppressions = suppressions.Unused(ran)
+		}
+		for _, unused := range unusedSuppressions {
+			moduleResults.Warnings = append(moduleResults.Warnings, ModuleWarning{
+				Warning: unused.Message,
+				Line:    unused.Line,
+				Rule:    nf.UnusedSuppressionRule,
+			})
 		}
 
 		results = append(results, moduleResults)
@@ -46,16 +108,130 @@ func NFCoreLint(directory string) ([]LintResults, error) {
 	return results, nil
 }
 
+// overrideSeverity moves the problems of a rule to the errors or the
+// warnings as the settings say. corelint has no infos, they are warnings.
+func overrideSeverity(results LintResults, severity string) LintResults {
+	switch severity {
+	case string(nf.SeverityError):
+		for _, warning := range results.Warnings {
+			results.Errors = append(results.Errors, ModuleError{
+				Error: errors.New(warning.Warning),
+				Line:  warning.Line,
+				Fix:   warning.Fix,
+			})
+		}
+		results.Warnings = []ModuleWarning{}
+	case string(nf.SeverityWarning), string(nf.SeverityInfo):
+		for _, err := range results.Errors {
+			results.Warnings = append(results.Warnings, ModuleWarning{
+				Warning: err.Error.Error(),
+				Line:    err.Line,
+				Fix:     err.Fix,
+			})
+		}
+		results.Errors = []ModuleError{}
+	}
+	return results
+}
+
+// RuleName is the rule the nf-core diagnostics are reported under
+const RuleName = "nfcore"
+
+// ToGroupedOutput converts the results to the diagnostics model shared
+// with `reft lint`, so they can be written in any output format.
+func ToGroupedOutput(results []LintResults) nf.GroupedOutput {
+	modules := make(map[string]nf.RuleModuleOutput)
+	for _, result := range results {
+		entry := modules[result.ModulePath]
+		for _, err := range result.Errors {
+			entry.Diagnostics = append(entry.Diagnostics, nf.Diagnostic{
+				Path:     result.ModulePath,
+				Line:     err.Line,
+				Severity: nf.SeverityError,
+				Code:     err.Rule,
+				Message:  err.Error.Error(),
+				Fix:      err.Fix,
+			})
+		}
+		for _, warning := range result.Warnings {
+			entry.Diagnostics = append(entry.Diagnostics, nf.Diagnostic{
+				Path:     result.ModulePath,
+				Line:     warning.Line,
+				Severity: nf.SeverityWarning,
+				Code:     warning.Rule,
+				Message:  warning.Warning,
+				Fix:      warning.Fix,
+			})
+		}
+		modules[result.ModulePath] = entry
+	}
+	return nf.GroupedOutput{RuleName: modules}
+}
+
+/*
+FixResults applies the fixes of the results and drops the problems they
+fix. With dryRun the files are left untouched and the diff is written to
+diff instead, the results are what would be left after the fixes.
+*/
+func FixResults(results []LintResults, dryRun bool, diff io.Writer) (*nf.FixResult, error) {
+	fixed, err := nf.FixFiles(ToGroupedOutput(results), dryRun, diff)
+	if err != nil {
+		return nil, err
+	}
+	RemoveFixed(results, fixed)
+	return fixed, nil
+}
+
+// HasErrors reports whether any of the results has an error.
+func HasErrors(results []LintResults) bool {
+	for _, result := range results {
+		if len(result.Errors) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+RemoveFixed drops the errors and warnings whose fix was applied.
+
+The results are the ones passed to nf.FixFiles via ToGroupedOutput.
+*/
+func RemoveFixed(results []LintResults, fixed *nf.FixResult) {
+	for i := range results {
+		errors := results[i].Errors[:0]
+		for _, err := range results[i].Errors {
+			if err.Fix == nil || !fixed.Fixed(err.Fix) {
+				errors = append(errors, err)
+			}
+		}
+		results[i].Errors = errors
+		warnings := results[i].Warnings[:0]
+		for _, warning := range results[i].Warnings {
+			if warning.Fix == nil || !fixed.Fixed(warning.Fix) {
+				warnings = append(warnings, warning)
+			}
+		}
+		results[i].Warnings = warnings
+	}
+}
+
 // Boilerplate
 
 type ModuleError struct {
 	Error error
 	Line  int
+	// Rule that reported the error, set by NFCoreLint
+	Rule string
+	Fix  *nf.Fix
 }
 
 type ModuleWarning struct {
 	Warning string
 	Line    int
+	// Rule that reported the warning, set by NFCoreLint
+	Rule string
+	Fix  *nf.Fix
 }
 
 type LintResults struct {
@@ -65,19 +241,3 @@ type LintResults struct {
 }
 
 type ModuleRule func(*nf.Module) LintResults
-
-var moduleRules []ModuleRule
-
-func init() {
-	moduleRules = []ModuleRule{
-		ruleContainerWithSpace,
-		ruleMultipleContainers,
-		ruleMustBeTagged,
-		ruleAlphanumerics,
-		ruleConflictingLabels,
-		ruleNoStandardLabel,
-		ruleNonStandardLabel,
-		ruleDuplicateLabels,
-		ruleNoLabels,
-	}
-}
@@ -0,0 +1,69 @@
+package corelint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFixResultsDryRun(t *testing.T) {
+	processContent := `
+process FOO {
+    label 'process_low'
+    echo true
+    script:
+    """
+    echo "test"
+    """
+}
+`
+	tmpDir := t.TempDir()
+	processFile := filepath.Join(tmpDir, "main.nf")
+	files := map[string]string{
+		processFile: processContent,
+		// a fixable error
+		filepath.Join(tmpDir, "reftrace.yml"): "rules:\n  echo_directive:\n    severity: error\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal("Failed to write file:", err)
+		}
+	}
+
+	results, err := NFCoreLint(tmpDir)
+	if err != nil {
+		t.Fatal("Failed to lint:", err)
+	}
+	if !HasErrors(results) {
+		t.Fatalf("Expected the echo directive to be an error, got %+v", results)
+	}
+	var diff strings.Builder
+	if _, err := FixResults(results, true, &diff); err != nil {
+		t.Fatal("Dry run failed:", err)
+	}
+	if HasErrors(results) {
+		t.Errorf("Expected no error left after the dry run, got %+v", results)
+	}
+	if !strings.Contains(diff.String(), "+    debug true\n") {
+		t.Errorf("Expected the dry run to print a diff, got:\n%s", diff.String())
+	}
+	if content, _ := os.ReadFile(processFile); string(content) != processContent {
+		t.Errorf("Expected the dry run to leave the file untouched, got:\n%s", content)
+	}
+
+	// an error without a fix is left
+	processContent = strings.Replace(processContent, "echo true", "echo true\n    container 'ubuntu latest'", 1)
+	if err := os.WriteFile(processFile, []byte(processContent), 0644); err != nil {
+		t.Fatal("Failed to write file:", err)
+	}
+	if results, err = NFCoreLint(tmpDir); err != nil {
+		t.Fatal("Failed to lint:", err)
+	}
+	if _, err := FixResults(results, true, &diff); err != nil {
+		t.Fatal("Dry run failed:", err)
+	}
+	if !HasErrors(results) {
+		t.Errorf("Expected the container error to be left after the dry run, got %+v", results)
+	}
+}
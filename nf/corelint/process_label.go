@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"reft-go/nf"
 	"reft-go/nf/directives"
+	"reft-go/parser"
 	"slices"
+	"strings"
 	"unicode"
 )
 
@@ -27,6 +29,16 @@ func getLabels(process nf.Process) []*directives.LabelDirective {
 	return labels
 }
 
+// standardLabel returns the standard label a label is a misspelling of,
+// such as 'PROCESS_HIGH' or 'process-high' for 'process_high'.
+func standardLabel(label string) (string, bool) {
+	normalized := strings.ToLower(strings.ReplaceAll(label, "-", "_"))
+	if slices.Contains(correctProcessLabels, normalized) {
+		return normalized, true
+	}
+	return "", false
+}
+
 func ruleConflictingLabels(module *nf.Module) LintResults {
 	results := LintResults{
 		ModulePath: module.Path,
@@ -106,6 +118,7 @@ func ruleNonStandardLabel(module *nf.Module) LintResults {
 			results.Warnings = append(results.Warnings, ModuleWarning{
 				Warning: fmt.Sprintf("process '%s' has non-standard labels: %v", process.Name, labelNames),
 				Line:    badLabels[0].Line(),
+				Fix:     fixNonStandardLabels(process, badLabels),
 			})
 		}
 	}
@@ -113,6 +126,96 @@ func ruleNonStandardLabel(module *nf.Module) LintResults {
 	return results
 }
 
+// The labels are only fixed if all of them are misspelled standard labels
+func fixNonStandardLabels(process nf.Process, badLabels []*directives.LabelDirective) *nf.Fix {
+	fix := &nf.Fix{Description: "use the standard labels"}
+	for _, label := range badLabels {
+		standard, ok := standardLabel(label.Label)
+		node := process.DirectiveNode(label)
+		if !ok || node == nil {
+			return nil
+		}
+		fix.Edits = append(fix.Edits, nf.TextEdit{
+			Span:    nf.SpanOf(node),
+			NewText: fmt.Sprintf("label '%s'", standard),
+		})
+	}
+	return fix
+}
+
+// fixDuplicateLabel removes every occurrence of a label but the first
+func fixDuplicateLabel(process nf.Process, labels []*directives.LabelDirective, name string) *nf.Fix {
+	fix := &nf.Fix{Description: fmt.Sprintf("remove the duplicate label '%s'", name)}
+	seen := false
+	for _, label := range labels {
+		if label.Label != name {
+			continue
+		}
+		if !seen {
+			seen = true
+			continue
+		}
+		node := process.DirectiveNode(label)
+		if node == nil {
+			return nil
+		}
+		fix.Edits = append(fix.Edits, deleteDirective(process, node))
+	}
+	return fix
+}
+
+func statementSpan(stmt parser.Statement) nf.Span {
+	if exprStmt, ok := stmt.(*parser.ExpressionStatement); ok {
+		return nf.SpanOf(exprStmt.GetExpression())
+	}
+	return nf.SpanOf(stmt)
+}
+
+/*
+deleteDirective returns the edit that removes a directive. Its lines are
+removed if nothing else is on them. Otherwise only the directive is, with
+what separates it from the next statement on its line, like '; ', or else
+from the previous one.
+*/
+func deleteDirective(process nf.Process, node *parser.MethodCallExpression) nf.TextEdit {
+	span := nf.SpanOf(node)
+	if process.Closure == nil {
+		return nf.TextEdit{Span: span}
+	}
+	var statements []parser.Statement
+	if block, ok := process.Closure.GetCode().(*parser.BlockStatement); ok {
+		statements = block.GetStatements()
+	}
+	// the process header and the closing brace
+	closure := nf.SpanOf(process.Closure)
+	alone := span.Line > closure.Line && span.LastLine < closure.LastLine
+	var previous, next *nf.Span
+	for _, stmt := range statements {
+		other := statementSpan(stmt)
+		if other == span {
+			continue
+		}
+		if other.LastLine >= span.Line && other.Line <= span.LastLine {
+			alone = false
+		}
+		if other.LastLine == span.Line && other.LastColumn <= span.Column {
+			previous = &other
+		}
+		if other.Line == span.LastLine && other.Column >= span.LastColumn && next == nil {
+			next = &other
+		}
+	}
+	switch {
+	case alone:
+		return nf.TextEdit{Span: span.Lines()}
+	case next != nil:
+		return nf.TextEdit{Span: nf.Span{Line: span.Line, Column: span.Column, LastLine: next.Line, LastColumn: next.Column}}
+	case previous != nil:
+		return nf.TextEdit{Span: nf.Span{Line: previous.LastLine, Column: previous.LastColumn, LastLine: span.LastLine, LastColumn: span.LastColumn}}
+	}
+	return nf.TextEdit{Span: span}
+}
+
 func ruleDuplicateLabels(module *nf.Module) LintResults {
 	results := LintResults{
 		ModulePath: module.Path,
@@ -137,6 +240,7 @@ func ruleDuplicateLabels(module *nf.Module) LintResults {
 					Warning: fmt.Sprintf("process '%s' has duplicate label '%s' (%d times)",
 						process.Name, labelName, count),
 					Line: process.Line(),
+					Fix:  fixDuplicateLabel(process, labels, labelName),
 				})
 			}
 		}
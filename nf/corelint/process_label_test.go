@@ -356,3 +356,119 @@ process FOO {
 		})
 	}
 }
+
+func TestLabelFixes(t *testing.T) {
+	processContent := `
+process FOO {
+    label 'PROCESS-HIGH'
+    label 'custom'
+    label 'custom'
+    script:
+    """
+    echo "test"
+    """
+}
+`
+	expected := `
+process FOO {
+    label 'process_high'
+    label 'custom'
+    script:
+    """
+    echo "test"
+    """
+}
+`
+	tmpDir := t.TempDir()
+	processFile := filepath.Join(tmpDir, "process.nf")
+	if err := os.WriteFile(processFile, []byte(processContent), 0644); err != nil {
+		t.Fatal("Failed to write process file:", err)
+	}
+
+	module, err, _ := nf.BuildModule(processFile)
+	if err != nil {
+		t.Fatal("Failed to parse process file:", err)
+	}
+
+	// 'custom' isn't a misspelled standard label, so only the duplicate is fixable
+	nonStandard := ruleNonStandardLabel(module)
+	if len(nonStandard.Warnings) != 1 || nonStandard.Warnings[0].Fix != nil {
+		t.Fatalf("Expected one unfixable non-standard label warning, got %+v", nonStandard.Warnings)
+	}
+	duplicates := ruleDuplicateLabels(module)
+	if len(duplicates.Warnings) != 1 || duplicates.Warnings[0].Fix == nil {
+		t.Fatalf("Expected one fixable duplicate label warning, got %+v", duplicates.Warnings)
+	}
+
+	process := module.Processes[0]
+	labels := getLabels(process)
+	fix := fixNonStandardLabels(process, labels[:1])
+	if fix == nil {
+		t.Fatal("Expected 'PROCESS-HIGH' to be fixable")
+	}
+
+	fixed, applied, conflicts := nf.ApplyFixes(processContent, []*nf.Fix{fix, duplicates.Warnings[0].Fix})
+	if len(applied) != 2 || len(conflicts) != 0 {
+		t.Fatalf("Expected both fixes to apply, got %d applied and %d conflicts", len(applied), len(conflicts))
+	}
+	if fixed != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, fixed)
+	}
+}
+
+func TestDuplicateLabelFixOnOneLine(t *testing.T) {
+	processContent := `
+process FOO {
+    label 'process_low'; label 'process_low'
+    script:
+    """
+    echo "test"
+    """
+}
+
+process BAR { label 'process_single'; label 'process_single'; cpus 2 }
+
+process BAZ { label 'process_high'; label 'process_high' }
+`
+	expected := `
+process FOO {
+    label 'process_low'
+    script:
+    """
+    echo "test"
+    """
+}
+
+process BAR { label 'process_single'; cpus 2 }
+
+process BAZ { label 'process_high' }
+`
+	tmpDir := t.TempDir()
+	processFile := filepath.Join(tmpDir, "process.nf")
+	if err := os.WriteFile(processFile, []byte(processContent), 0644); err != nil {
+		t.Fatal("Failed to write process file:", err)
+	}
+
+	module, err, _ := nf.BuildModule(processFile)
+	if err != nil {
+		t.Fatal("Failed to parse process file:", err)
+	}
+
+	var fixes []*nf.Fix
+	for _, warning := range ruleDuplicateLabels(module).Warnings {
+		if warning.Fix == nil {
+			t.Fatalf("Expected the duplicate label to be fixable: %s", warning.Warning)
+		}
+		fixes = append(fixes, warning.Fix)
+	}
+	if len(fixes) != 3 {
+		t.Fatalf("Expected 3 duplicate label warnings, got %d", len(fixes))
+	}
+	fixed, applied, conflicts := nf.ApplyFixes(processContent, fixes)
+	if len(applied) != 3 || len(conflicts) != 0 {
+		t.Fatalf("Expected the fixes to apply, got %d applied and %d conflicts", len(applied), len(conflicts))
+	}
+	if fixed != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, fixed)
+	}
+}
@@ -0,0 +1,273 @@
+package corelint
+
+import (
+	"fmt"
+	"reft-go/nf"
+	"slices"
+)
+
+// NFCoreTag is the tag of the rules `reft lint nfcore` runs
+const NFCoreTag = "nf-core"
+
+/*
+Rule is a check written in Go that runs on each module, along with what
+users need to know about it.
+
+Packages register their rules from init with Register, like the nf-core
+rules below, and `reft lint --go-rules` runs them by ID, category or tag
+next to the rules of the rules file.
+*/
+type Rule struct {
+	// ID is what settings, suppression comments and --go-rules refer to
+	ID string
+	// Category groups related rules, like "containers" or "labels"
+	Category string
+	Tags     []string
+	// Severity of the problems the rule reports, unless the settings
+	// override it
+	Severity    nf.Severity
+	Description string
+	// Doc is the long-form documentation `reft rules explain` shows, the
+	// rule's file in docs/ if empty
+	Doc     string
+	DocsURL string
+	// Fixable is set if the rule suggests fixes for --fix
+	Fixable bool
+	Check   ModuleRule
+}
+
+var registry []Rule
+
+/*
+Register adds rules to the registry. It panics if a rule has no ID, Check
+or valid severity, or the ID of a rule registered before, since that is a
+mistake in the program.
+*/
+func Register(rules ...Rule) {
+	for _, rule := range rules {
+		if rule.ID == "" || rule.Check == nil {
+			panic(fmt.Sprintf("corelint: rule %q needs an ID and a Check", rule.ID))
+		}
+		if _, err := nf.ParseSeverity(string(rule.Severity)); err != nil {
+			panic(fmt.Sprintf("corelint: rule %s: %v", rule.ID, err))
+		}
+		if _, ok := Lookup(rule.ID); ok {
+			panic(fmt.Sprintf("corelint: rule %s is registered twice", rule.ID))
+		}
+		if rule.Doc == "" {
+			rule.Doc = nf.RuleDoc(docs, rule.ID)
+		}
+		registry = append(registry, rule)
+	}
+}
+
+// Rules returns the registered rules in the order they were registered.
+func Rules() []Rule {
+	return slices.Clone(registry)
+}
+
+// Lookup returns the rule with the ID.
+func Lookup(id string) (Rule, bool) {
+	for _, rule := range registry {
+		if rule.ID == id {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (r Rule) matches(selector string) bool {
+	return selector == "all" || r.ID == selector || r.Category == selector || slices.Contains(r.Tags, selector)
+}
+
+// Select returns the rules with an ID, a category or a tag in selectors,
+// or every rule for "all". Each selector must select a rule.
+func Select(selectors []string) ([]Rule, error) {
+	for _, selector := range selectors {
+		if !slices.ContainsFunc(registry, func(r Rule) bool { return r.matches(selector) }) {
+			return nil, fmt.Errorf("no Go rule has the ID, category or tag %q", selector)
+		}
+	}
+	var selected []Rule
+	for _, rule := range registry {
+		if slices.ContainsFunc(selectors, rule.matches) {
+			selected = append(selected, rule)
+		}
+	}
+	return selected, nil
+}
+
+/*
+Builtin returns the rule as a built-in rule of `reft lint`. It runs the
+rule on every module, with the severity of the rule. The settings and
+suppression comments apply to it like to the rules of the rules file.
+*/
+func (r Rule) Builtin() nf.BuiltinRule {
+	return nf.BuiltinRule{
+		Name: r.ID,
+		Info: r.Info(),
+		Run: func(dir string, modules []*nf.Module) (map[string]nf.RuleModuleOutput, error) {
+			outputs := make(map[string]nf.RuleModuleOutput)
+			for _, module := range modules {
+				results := r.Check(module)
+				var diagnostics []nf.Diagnostic
+				for _, err := range results.Errors {
+					diagnostics = append(diagnostics, nf.Diagnostic{
+						Path:     module.Path,
+						Line:     err.Line,
+						Severity: r.Severity,
+						Message:  err.Error.Error(),
+						Fix:      err.Fix,
+					})
+				}
+				for _, warning := range results.Warnings {
+					diagnostics = append(diagnostics, nf.Diagnostic{
+						Path:     module.Path,
+						Line:     warning.Line,
+						Severity: r.Severity,
+						Message:  warning.Warning,
+						Fix:      warning.Fix,
+					})
+				}
+				if len(diagnostics) > 0 {
+					outputs[module.Path] = nf.RuleModuleOutput{Diagnostics: diagnostics}
+				}
+			}
+			return outputs, nil
+		},
+	}
+}
+
+// Info returns what `reft rules` shows about the rule.
+func (r Rule) Info() nf.RuleInfo {
+	return nf.RuleInfo{
+		ID:          r.ID,
+		Kind:        nf.RuleKindGo,
+		Severity:    r.Severity,
+		Category:    r.Category,
+		Tags:        r.Tags,
+		Fixable:     r.Fixable,
+		Description: r.Description,
+		Doc:         r.Doc,
+		DocsURL:     r.DocsURL,
+		Source:      nf.FuncSource(r.Check),
+	}
+}
+
+// Builtins returns the rules as built-in rules of `reft lint`.
+func Builtins(rules []Rule) []nf.BuiltinRule {
+	builtins := make([]nf.BuiltinRule, len(rules))
+	for i, rule := range rules {
+		builtins[i] = rule.Builtin()
+	}
+	return builtins
+}
+
+func init() {
+	nfcore := []string{NFCoreTag}
+	Register(
+		Rule{
+			ID:          "container_with_space",
+			Category:    "containers",
+			Tags:        nfcore,
+			Severity:    nf.SeverityError,
+			Description: "Container names must not contain spaces",
+			Check:       ruleContainerWithSpace,
+		},
+		Rule{
+			ID:          "multiple_containers",
+			Category:    "containers",
+			Tags:        nfcore,
+			Severity:    nf.SeverityWarning,
+			Description: "Docker and Singularity containers should not be on the same line",
+			Check:       ruleMultipleContainers,
+		},
+		Rule{
+			ID:          "must_be_tagged",
+			Category:    "containers",
+			Tags:        nfcore,
+			Severity:    nf.SeverityError,
+			Description: "Containers must have a tag and be named organization/container:tag",
+			Check:       ruleMustBeTagged,
+		},
+		Rule{
+			ID:          "alphanumerics",
+			Category:    "labels",
+			Tags:        nfcore,
+			Severity:    nf.SeverityWarning,
+			Description: "Process labels should only contain letters, numbers and underscores",
+			Check:       ruleAlphanumerics,
+		},
+		Rule{
+			ID:          "conflicting_labels",
+			Category:    "labels",
+			Tags:        nfcore,
+			Severity:    nf.SeverityWarning,
+			Description: "A process should have a single standard resource label",
+			Check:       ruleConflictingLabels,
+		},
+		Rule{
+			ID:          "no_standard_label",
+			Category:    "labels",
+			Tags:        nfcore,
+			Severity:    nf.SeverityWarning,
+			Description: "A process should have one of the standard nf-core resource labels",
+			Check:       ruleNoStandardLabel,
+		},
+		Rule{
+			ID:          "non_standard_label",
+			Category:    "labels",
+			Tags:        nfcore,
+			Severity:    nf.SeverityWarning,
+			Description: "Process labels should not be misspelled standard labels",
+			Fixable:     true,
+			Check:       ruleNonStandardLabel,
+		},
+		Rule{
+			ID:          "duplicate_labels",
+			Category:    "labels",
+			Tags:        nfcore,
+			Severity:    nf.SeverityWarning,
+			Description: "A process should not have the same label twice",
+			Fixable:     true,
+			Check:       ruleDuplicateLabels,
+		},
+		Rule{
+			ID:          "no_labels",
+			Category:    "labels",
+			Tags:        nfcore,
+			Severity:    nf.SeverityWarning,
+			Description: "A process should have a label",
+			Check:       ruleNoLabels,
+		},
+		Rule{
+			ID:          "echo_directive",
+			Category:    "directives",
+			Tags:        nfcore,
+			Severity:    nf.SeverityWarning,
+			Description: "The echo directive is deprecated, use debug instead",
+			Fixable:     true,
+			Check:       ruleEchoDirective,
+		},
+	)
+}
+
+// isModuleRule reports whether a rule is registered, "" is any rule
+func isModuleRule(name string) bool {
+	if name == "" {
+		return true
+	}
+	_, ok := Lookup(name)
+	return ok
+}
+
+// nfcoreRules returns the rules `reft lint nfcore` runs
+func nfcoreRules() []Rule {
+	var rules []Rule
+	for _, rule := range registry {
+		if slices.Contains(rule.Tags, NFCoreTag) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
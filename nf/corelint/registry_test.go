@@ -0,0 +1,115 @@
+package corelint
+
+import (
+	"os"
+	"path/filepath"
+	"reft-go/nf"
+	"strings"
+	"testing"
+)
+
+func ruleIDs(rules []Rule) string {
+	ids := make([]string, len(rules))
+	for i, rule := range rules {
+		ids[i] = rule.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSelect(t *testing.T) {
+	tests := map[string]string{
+		"no_labels":                      "no_labels",
+		"containers":                     "container_with_space,multiple_containers,must_be_tagged",
+		"echo_directive,directives":      "echo_directive",
+		"no_labels,container_with_space": "container_with_space,no_labels",
+	}
+	for selectors, expected := range tests {
+		rules, err := Select(strings.Split(selectors, ","))
+		if err != nil {
+			t.Errorf("Select(%s) failed: %v", selectors, err)
+			continue
+		}
+		if ids := ruleIDs(rules); ids != expected {
+			t.Errorf("Select(%s): expected %s, got %s", selectors, expected, ids)
+		}
+	}
+	for _, selector := range []string{"all", NFCoreTag} {
+		if rules, _ := Select([]string{selector}); len(rules) != len(nfcoreRules()) {
+			t.Errorf("Expected %s to select every rule, got %s", selector, ruleIDs(rules))
+		}
+	}
+	if _, err := Select([]string{"no_labels", "unknown"}); err == nil || !strings.Contains(err.Error(), `"unknown"`) {
+		t.Errorf("Expected an error for an unknown selector, got %v", err)
+	}
+}
+
+func TestRegisterInvalid(t *testing.T) {
+	check := func(*nf.Module) LintResults { return LintResults{} }
+	for name, rule := range map[string]Rule{
+		"duplicate": {ID: "no_labels", Severity: nf.SeverityWarning, Check: check},
+		"no check":  {ID: "new_rule", Severity: nf.SeverityWarning},
+		"severity":  {ID: "new_rule", Severity: "fatal", Check: check},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected Register to panic", name)
+				}
+			}()
+			Register(rule)
+		}()
+	}
+}
+
+func TestGoRulesInLint(t *testing.T) {
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	files := map[string]string{
+		rulesFile:                        "def rule_nothing(module):\n    pass\n",
+		filepath.Join(tmpDir, "main.nf"): "process FOO {\n    script:\n    \"\"\"\n    echo test\n    \"\"\"\n}\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal("Failed to write file:", err)
+		}
+	}
+
+	rules, err := Select([]string{"labels"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output strings.Builder
+	config := nf.LintConfig{RulesFile: rulesFile, Directory: tmpDir, Format: nf.FormatJSON, BuiltinRules: Builtins(rules)}
+	if err := nf.RunLintWithConfig(config, &output); err != nil {
+		t.Fatalf("Expected only warnings, got %v:\n%s", err, output.String())
+	}
+	for _, expected := range []string{`"rule": "no_labels"`, `"severity": "warning"`, "process 'FOO' has no labels"} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Expected output to contain %q, but got:\n%s", expected, output.String())
+		}
+	}
+
+	// .nf-core.yml applies like in reft lint nfcore
+	if err := os.WriteFile(filepath.Join(tmpDir, ".nf-core.yml"), []byte("lint:\n  no_labels: false\n"), 0644); err != nil {
+		t.Fatal("Failed to write file:", err)
+	}
+	output.Reset()
+	config.LoadSettings = LoadSettings
+	if err := nf.RunLintWithConfig(config, &output); err != nil {
+		t.Fatalf("Expected no problem, got %v:\n%s", err, output.String())
+	}
+	if strings.Contains(output.String(), "no_labels") {
+		t.Errorf("Expected no_labels to be disabled by .nf-core.yml, got:\n%s", output.String())
+	}
+}
+
+func TestRuleDocs(t *testing.T) {
+	for _, rule := range Rules() {
+		if !strings.Contains(rule.Doc, "Bad:") || !strings.Contains(rule.Doc, "Good:") {
+			t.Errorf("Expected the documentation of %s to have bad and good examples, got %q", rule.ID, rule.Doc)
+		}
+		if info := rule.Info(); !strings.HasPrefix(info.Source, "reft-go/nf/corelint/") {
+			t.Errorf("Expected %s to be defined in corelint, got %s", rule.ID, info.Source)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package corelint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reft-go/nf"
+	"slices"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NFCoreFiles are the names of the nf-core config files LoadSettings reads
+var NFCoreFiles = []string{".nf-core.yml", ".nf-core.yaml"}
+
+type nfcoreConfig struct {
+	Lint map[string]yaml.Node `yaml:"lint"`
+}
+
+// findNFCoreConfig returns the path of the .nf-core.yml in dir or one of
+// its parents, or "" if there is none.
+func findNFCoreConfig(dir string) string {
+	for {
+		for _, name := range NFCoreFiles {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// The check of nf-core tools the corelint rules come from, it checks the
+// labels, containers and directives of the main.nf of modules
+const nfcoreMainNF = "main_nf"
+
+// The other checks of nf-core tools. corelint has nothing like them, so
+// they are ignored.
+var nfcoreToolsChecks = []string{
+	// pipeline checks
+	"actions_awsfulltest", "actions_awstest", "actions_ci", "actions_nf_test",
+	"actions_schema_validation", "base_config", "files_exist", "files_unchanged",
+	"included_configs", "local_component_structure", "merge_markers",
+	"modules_config", "modules_json", "modules_structure", "multiqc_config",
+	"nextflow_config", "nfcore_yml", "pipeline_if_empty_null",
+	"pipeline_name_conventions", "pipeline_todos", "plugin_includes", "readme",
+	"rocrate_readme_sync", "schema_description", "schema_lint", "schema_params",
+	"system_exit", "template_strings", "version_consistency",
+	// module and subworkflow checks
+	"environment_yml", "meta_yml", "module_changes", "module_deprecations",
+	"module_patch", "module_tests", "module_todos", "module_version",
+	"subworkflow_changes", "subworkflow_tests", "subworkflow_todos",
+	"subworkflow_version",
+}
+
+/*
+LoadSettings reads the reftrace.yml settings of the pipeline and merges
+the lint section of .nf-core.yml into them:
+
+	lint:
+	  no_labels: false
+	  must_be_tagged:
+	    - modules/local/foo/main.nf
+	  main_nf:
+	    - modules/local/bar/main.nf
+
+false disables a rule and a list of files excludes them from it, like
+nf-core tools do. main_nf, the nf-core tools check the corelint rules come
+from, applies to all of them, and a rule of its own takes precedence. The
+settings in reftrace.yml take precedence over both.
+
+The other checks of nf-core tools are ignored. Keys that are neither are
+returned as warnings, they are likely typos.
+
+reft lint uses it when it runs the corelint rules with --go-rules, so they
+report the same problems as reft lint nfcore.
+*/
+func LoadSettings(dir string) (*nf.Settings, []string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	settings, err := nf.FindSettings(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	path := findNFCoreConfig(dir)
+	if path == "" {
+		return settings, nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var config nfcoreConfig
+	if err := yaml.NewDecoder(bytes.NewReader(content)).Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, fmt.Errorf("invalid nf-core config %s: %v", path, err)
+	}
+	if settings.Rules == nil {
+		settings.Rules = make(map[string]*nf.RuleSettings)
+	}
+
+	names := make([]string, 0, len(config.Lint))
+	for name := range config.Lint {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var warnings []string
+	for _, name := range names {
+		if isModuleRule(name) {
+			if err := applyNFCoreCheck(settings, name, config.Lint[name], name, path); err != nil {
+				return nil, nil, err
+			}
+		} else if name != nfcoreMainNF && !slices.Contains(nfcoreToolsChecks, name) {
+			warnings = append(warnings, fmt.Sprintf("%s: line %d: lint.%s is not a check of nf-core tools or a corelint rule, it is ignored",
+				path, config.Lint[name].Line, name))
+		}
+	}
+	// after the rules of their own, which take precedence
+	if node, ok := config.Lint[nfcoreMainNF]; ok {
+		for _, rule := range nfcoreRules() {
+			if err := applyNFCoreCheck(settings, rule.ID, node, nfcoreMainNF, path); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return settings, warnings, nil
+}
+
+// applyNFCoreCheck applies the value of a check of the lint section of
+// .nf-core.yml to a rule, unless the rule already sets it
+func applyNFCoreCheck(settings *nf.Settings, ruleName string, node yaml.Node, check, path string) error {
+	rule := settings.Rule(ruleName)
+	switch node.Kind {
+	case yaml.ScalarNode:
+		var enabled bool
+		if err := node.Decode(&enabled); err != nil {
+			return fmt.Errorf("invalid nf-core config %s: line %d: lint.%s must be a boolean or a list of files", path, node.Line, check)
+		}
+		if rule.Enabled == nil {
+			rule.Enabled = &enabled
+		}
+	case yaml.SequenceNode:
+		var files []string
+		if err := node.Decode(&files); err != nil {
+			return fmt.Errorf("invalid nf-core config %s: line %d: lint.%s must be a boolean or a list of files", path, node.Line, check)
+		}
+		for _, file := range files {
+			rule.Exclude = append(rule.Exclude, filepath.ToSlash(filepath.Join(filepath.Dir(path), file)))
+		}
+	}
+	settings.Rules[ruleName] = rule
+	return nil
+}
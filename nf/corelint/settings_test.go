@@ -0,0 +1,51 @@
+package corelint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadSettingsNFCoreConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		filepath.Join(tmpDir, ".nf-core.yml"): `lint:
+  actions_ci: false
+  files_exist:
+    - CODE_OF_CONDUCT.md
+  main_nf:
+    - modules/local/foo/main.nf
+  no_labels: true
+  echo_directive: false
+  must_be_taged: false
+`,
+		filepath.Join(tmpDir, "reftrace.yml"): "rules:\n  echo_directive: true\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal("Failed to write file:", err)
+		}
+	}
+
+	settings, warnings, err := LoadSettings(tmpDir)
+	if err != nil {
+		t.Fatal("Failed to load settings:", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "line 9: lint.must_be_taged") {
+		t.Errorf("Expected a warning for the misspelled must_be_taged only, got %v", warnings)
+	}
+
+	foo := filepath.Join(tmpDir, "modules", "local", "foo", "main.nf")
+	bar := filepath.Join(tmpDir, "modules", "local", "bar", "main.nf")
+	// main_nf excludes foo from every rule
+	for _, rule := range nfcoreRules() {
+		if settings.Applies(rule.ID, foo) || !settings.Applies(rule.ID, bar) {
+			t.Errorf("Expected %s to run on bar but not on foo", rule.ID)
+		}
+	}
+	// reftrace.yml takes precedence over .nf-core.yml
+	if !settings.RuleEnabled("echo_directive") || !settings.RuleEnabled("no_labels") {
+		t.Error("Expected echo_directive and no_labels to be enabled")
+	}
+}
@@ -0,0 +1,44 @@
+package nf
+
+import "fmt"
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+func ParseSeverity(s string) (Severity, error) {
+	switch Severity(s) {
+	case SeverityError, SeverityWarning, SeverityInfo:
+		return Severity(s), nil
+	}
+	return "", fmt.Errorf("unknown severity %q, expected one of error, warning, info", s)
+}
+
+// Diagnostic is a problem reported by a rule.
+// Line and Column are 1-based, 0 if unknown.
+type Diagnostic struct {
+	Path     string
+	Line     int
+	Column   int
+	Severity Severity
+	Code     string
+	Message  string
+	// Fix is applied by --fix, nil if the rule can't fix the problem
+	Fix *Fix
+}
+
+// Location formats the position as path:line:col, leaving out unknown parts.
+func (d Diagnostic) Location() string {
+	switch {
+	case d.Line == 0:
+		return d.Path
+	case d.Column == 0:
+		return fmt.Sprintf("%s:%d", d.Path, d.Line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", d.Path, d.Line, d.Column)
+	}
+}
@@ -0,0 +1,290 @@
+package nf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reft-go/parser"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Span is a range in a source file.
+// Lines and columns are 1-based and the last column is exclusive,
+// like the positions of AST nodes.
+type Span struct {
+	Line       int
+	Column     int
+	LastLine   int
+	LastColumn int
+}
+
+func SpanOf(node parser.ASTNodeNoVisit) Span {
+	return Span{
+		Line:       node.GetLineNumber(),
+		Column:     node.GetColumnNumber(),
+		LastLine:   node.GetLastLineNumber(),
+		LastColumn: node.GetLastColumnNumber(),
+	}
+}
+
+// NameSpan returns the span of the method name of a call without a
+// receiver, such as a directive.
+func NameSpan(mce *parser.MethodCallExpression) Span {
+	line, column := mce.GetLineNumber(), mce.GetColumnNumber()
+	return Span{
+		Line:       line,
+		Column:     column,
+		LastLine:   line,
+		LastColumn: column + utf8.RuneCountInString(mce.GetMethodAsString()),
+	}
+}
+
+// Lines extends the span to the whole lines it is on, including the
+// trailing newline, so that replacing it with "" removes the lines.
+func (s Span) Lines() Span {
+	return Span{Line: s.Line, Column: 1, LastLine: s.LastLine + 1, LastColumn: 1}
+}
+
+func (s Span) String() string {
+	return fmt.Sprintf("%d:%d-%d:%d", s.Line, s.Column, s.LastLine, s.LastColumn)
+}
+
+// TextEdit replaces the text of a span.
+type TextEdit struct {
+	Span    Span
+	NewText string
+}
+
+// Fix is a set of edits that resolves a diagnostic.
+// The edits of a fix are applied together or not at all.
+type Fix struct {
+	Description string
+	Edits       []TextEdit
+}
+
+type offsetEdit struct {
+	start, end int
+	text       string
+}
+
+// lineOffsets returns the byte offset of the start of every line
+func lineOffsets(source string) []int {
+	offsets := []int{0}
+	for i := 0; i < len(source); i++ {
+		if source[i] == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+// offset converts a position to a byte offset. Columns count runes.
+// Positions past the end of a line or of the file are clamped.
+func offset(source string, lines []int, line, column int) (int, error) {
+	if line < 1 || column < 1 {
+		return 0, fmt.Errorf("invalid position %d:%d", line, column)
+	}
+	if line > len(lines) {
+		return len(source), nil
+	}
+	pos := lines[line-1]
+	for col := 1; col < column && pos < len(source) && source[pos] != '\n'; col++ {
+		_, size := utf8.DecodeRuneInString(source[pos:])
+		pos += size
+	}
+	return pos, nil
+}
+
+func (f *Fix) offsetEdits(source string, lines []int) ([]offsetEdit, error) {
+	edits := make([]offsetEdit, 0, len(f.Edits))
+	for _, edit := range f.Edits {
+		start, err := offset(source, lines, edit.Span.Line, edit.Span.Column)
+		if err != nil {
+			return nil, err
+		}
+		end, err := offset(source, lines, edit.Span.LastLine, edit.Span.LastColumn)
+		if err != nil {
+			return nil, err
+		}
+		if end < start {
+			return nil, fmt.Errorf("invalid span %s", edit.Span)
+		}
+		edits = append(edits, offsetEdit{start, end, edit.NewText})
+	}
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	for i := 1; i < len(edits); i++ {
+		if overlaps(edits[i-1], edits[i]) {
+			return nil, fmt.Errorf("edits of fix %q overlap", f.Description)
+		}
+	}
+	return edits, nil
+}
+
+// Two insertions at the same position conflict since their order is ambiguous
+func overlaps(a, b offsetEdit) bool {
+	if a.start == a.end && b.start == b.end {
+		return a.start == b.start
+	}
+	return a.start < b.end && b.start < a.end
+}
+
+func sameEdits(a, b []offsetEdit) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ApplyFixes applies fixes to source, in order.
+
+A fix that overlaps a fix applied before it is not applied and is
+returned as a conflict. A fix identical to an applied one is applied
+once and counts as applied.
+*/
+func ApplyFixes(source string, fixes []*Fix) (string, []*Fix, []*Fix) {
+	lines := lineOffsets(source)
+	var applied, conflicts []*Fix
+	var accepted [][]offsetEdit
+	var all []offsetEdit
+
+	for _, fix := range fixes {
+		edits, err := fix.offsetEdits(source, lines)
+		if err != nil {
+			conflicts = append(conflicts, fix)
+			continue
+		}
+		duplicate, conflict := false, false
+		for _, prev := range accepted {
+			if sameEdits(prev, edits) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			for _, edit := range edits {
+				for _, prev := range all {
+					if overlaps(prev, edit) {
+						conflict = true
+					}
+				}
+			}
+		}
+		if conflict {
+			conflicts = append(conflicts, fix)
+			continue
+		}
+		applied = append(applied, fix)
+		if !duplicate {
+			accepted = append(accepted, edits)
+			all = append(all, edits...)
+		}
+	}
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].start < all[j].start })
+	var sb strings.Builder
+	pos := 0
+	for _, edit := range all {
+		sb.WriteString(source[pos:edit.start])
+		sb.WriteString(edit.text)
+		pos = edit.end
+	}
+	sb.WriteString(source[pos:])
+	return sb.String(), applied, conflicts
+}
+
+// FixResult summarizes the fixes applied to the files.
+type FixResult struct {
+	Applied   []*Fix
+	Conflicts []*Fix
+	// Files changed, sorted
+	Files []string
+}
+
+func (r *FixResult) Fixed(fix *Fix) bool {
+	for _, applied := range r.Applied {
+		if applied == fix {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+FixFiles applies the fixes attached to the diagnostics in output.
+
+With dryRun the files are left untouched and a unified diff of the
+changes is written to diff instead.
+*/
+func FixFiles(output GroupedOutput, dryRun bool, diff io.Writer) (*FixResult, error) {
+	fixesByPath := make(map[string][]*Fix)
+	for _, d := range output.Diagnostics() {
+		if d.Fix != nil {
+			fixesByPath[d.Path] = append(fixesByPath[d.Path], d.Fix)
+		}
+	}
+	paths := make([]string, 0, len(fixesByPath))
+	for path := range fixesByPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	result := &FixResult{}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", path, err)
+		}
+		source := string(content)
+		fixed, applied, conflicts := ApplyFixes(source, fixesByPath[path])
+		result.Applied = append(result.Applied, applied...)
+		result.Conflicts = append(result.Conflicts, conflicts...)
+		if fixed == source {
+			continue
+		}
+		result.Files = append(result.Files, path)
+		if dryRun {
+			name := reportPath(path)
+			oldName, newName := "a/"+name, "b/"+name
+			if filepath.IsAbs(name) {
+				oldName, newName = name, name
+			}
+			if _, err := io.WriteString(diff, UnifiedDiff(oldName, newName, source, fixed)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, []byte(fixed), info.Mode().Perm()); err != nil {
+			return nil, fmt.Errorf("error writing %s: %v", path, err)
+		}
+	}
+	return result, nil
+}
+
+// RemoveFixed drops the diagnostics whose fix was applied.
+func RemoveFixed(output GroupedOutput, result *FixResult) {
+	for _, modules := range output {
+		for path, entry := range modules {
+			kept := entry.Diagnostics[:0]
+			for _, d := range entry.Diagnostics {
+				if d.Fix == nil || !result.Fixed(d.Fix) {
+					kept = append(kept, d)
+				}
+			}
+			entry.Diagnostics = kept
+			modules[path] = entry
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package nf
+
+import (
+	"fmt"
+
+	"go.starlark.net/starlark"
+)
+
+var _ starlark.Value = (*StarlarkSpan)(nil)
+var _ starlark.HasAttrs = (*StarlarkSpan)(nil)
+
+type StarlarkSpan struct {
+	Span
+}
+
+func (s *StarlarkSpan) String() string {
+	return fmt.Sprintf("Span(%s)", s.Span)
+}
+
+func (s *StarlarkSpan) Type() string {
+	return "span"
+}
+
+func (s *StarlarkSpan) Freeze() {}
+
+func (s *StarlarkSpan) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (s *StarlarkSpan) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: span")
+}
+
+func (s *StarlarkSpan) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "line":
+		return starlark.MakeInt(s.Line), nil
+	case "column":
+		return starlark.MakeInt(s.Column), nil
+	case "last_line":
+		return starlark.MakeInt(s.LastLine), nil
+	case "last_column":
+		return starlark.MakeInt(s.LastColumn), nil
+	case "lines":
+		return &StarlarkSpan{s.Lines()}, nil
+	default:
+		return nil, starlark.NoSuchAttrError(fmt.Sprintf("span has no attribute %q", name))
+	}
+}
+
+func (s *StarlarkSpan) AttrNames() []string {
+	return []string{"line", "column", "last_line", "last_column", "lines"}
+}
+
+var _ starlark.Value = (*StarlarkEdit)(nil)
+
+type StarlarkEdit struct {
+	TextEdit
+}
+
+func (e *StarlarkEdit) String() string {
+	return fmt.Sprintf("Edit(%s, %q)", e.Span, e.NewText)
+}
+
+func (e *StarlarkEdit) Type() string {
+	return "edit"
+}
+
+func (e *StarlarkEdit) Freeze() {}
+
+func (e *StarlarkEdit) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (e *StarlarkEdit) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: edit")
+}
+
+/*
+editFunc implements edit():
+
+	edit(span, text)
+	edit(line, column, last_line, last_column, text)
+
+The edit replaces the text of the span with text.
+*/
+func editFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(args) == 2 {
+		var span *StarlarkSpan
+		var text string
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &span, &text); err != nil {
+			return nil, err
+		}
+		return &StarlarkEdit{TextEdit{Span: span.Span, NewText: text}}, nil
+	}
+	var s Span
+	var text string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 5, &s.Line, &s.Column, &s.LastLine, &s.LastColumn, &text); err != nil {
+		return nil, err
+	}
+	if s.Line < 1 || s.Column < 1 || s.LastLine < s.Line || (s.LastLine == s.Line && s.LastColumn < s.Column) {
+		return nil, fmt.Errorf("%s: invalid span %s", b.Name(), s)
+	}
+	return &StarlarkEdit{TextEdit{Span: s, NewText: text}}, nil
+}
+
+// fixFromStarlark converts the fix argument of error() and warning(),
+// an edit or a list of edits.
+func fixFromStarlark(value starlark.Value, description string) (*Fix, error) {
+	fix := &Fix{Description: description}
+	if edit, ok := value.(*StarlarkEdit); ok {
+		fix.Edits = append(fix.Edits, edit.TextEdit)
+		return fix, nil
+	}
+	iterable, ok := value.(starlark.Iterable)
+	if !ok {
+		return nil, fmt.Errorf("fix must be an edit or a list of edits, got %s", value.Type())
+	}
+	iter := iterable.Iterate()
+	defer iter.Done()
+	var item starlark.Value
+	for iter.Next(&item) {
+		edit, ok := item.(*StarlarkEdit)
+		if !ok {
+			return nil, fmt.Errorf("fix must be an edit or a list of edits, got %s in list", item.Type())
+		}
+		fix.Edits = append(fix.Edits, edit.TextEdit)
+	}
+	if len(fix.Edits) == 0 {
+		return nil, nil
+	}
+	return fix, nil
+}
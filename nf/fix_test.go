@@ -0,0 +1,126 @@
+package nf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyFixes(t *testing.T) {
+	source := "process FOO {\n    echo true\n    label 'a'\n    label 'a'\n}\n"
+	rename := &Fix{Edits: []TextEdit{{Span: Span{2, 5, 2, 9}, NewText: "debug"}}}
+	remove := &Fix{Edits: []TextEdit{{Span: Span{4, 5, 4, 14}.Lines(), NewText: ""}}}
+	overlapping := &Fix{Edits: []TextEdit{{Span: Span{2, 5, 2, 14}, NewText: "debug false"}}}
+	duplicate := &Fix{Edits: []TextEdit{{Span: Span{2, 5, 2, 9}, NewText: "debug"}}}
+
+	fixed, applied, conflicts := ApplyFixes(source, []*Fix{rename, remove, overlapping, duplicate})
+	expected := "process FOO {\n    debug true\n    label 'a'\n}\n"
+	if fixed != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, fixed)
+	}
+	if len(applied) != 3 || len(conflicts) != 1 || conflicts[0] != overlapping {
+		t.Errorf("Expected the overlapping fix to be the only conflict, got applied=%v conflicts=%v", applied, conflicts)
+	}
+}
+
+func TestApplyFixesOverlappingEdits(t *testing.T) {
+	source := "abcdef\n"
+	fix := &Fix{Edits: []TextEdit{
+		{Span: Span{1, 1, 1, 4}, NewText: "x"},
+		{Span: Span{1, 3, 1, 5}, NewText: "y"},
+	}}
+	fixed, applied, conflicts := ApplyFixes(source, []*Fix{fix})
+	if fixed != source || len(applied) != 0 || len(conflicts) != 1 {
+		t.Errorf("Expected a fix with overlapping edits to be rejected, got %q", fixed)
+	}
+}
+
+func TestApplyFixesUnicodeColumns(t *testing.T) {
+	source := "tag 'é' // ü\n"
+	fix := &Fix{Edits: []TextEdit{{Span: Span{1, 9, 1, 13}, NewText: ""}}}
+	fixed, _, _ := ApplyFixes(source, []*Fix{fix})
+	if fixed != "tag 'é' \n" {
+		t.Errorf("Expected columns to count runes, got %q", fixed)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n12\n"
+	expected := `--- a/main.nf
++++ b/main.nf
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -8,5 +8,4 @@
+ 8
+ 9
+ 10
+-11
+ 12
+`
+	if diff := UnifiedDiff("a/main.nf", "b/main.nf", a, b); diff != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, diff)
+	}
+	if diff := UnifiedDiff("a", "b", a, a); diff != "" {
+		t.Errorf("Expected no diff for equal inputs, got:\n%s", diff)
+	}
+}
+
+func TestLintFix(t *testing.T) {
+	rulesContent := `
+def rule_no_echo(module):
+    for process in module.processes:
+        for echo in process.directives.echo:
+            warning("use debug instead of echo", line=echo.line, fix=edit(echo.name_span, "debug"))
+`
+	processContent := `
+process FOO {
+    echo true
+    script:
+    """
+    echo "test"
+    """
+}
+`
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	processFile := filepath.Join(tmpDir, "process.nf")
+	if err := os.WriteFile(rulesFile, []byte(rulesContent), 0644); err != nil {
+		t.Fatal("Failed to write rules file:", err)
+	}
+	if err := os.WriteFile(processFile, []byte(processContent), 0644); err != nil {
+		t.Fatal("Failed to write process file:", err)
+	}
+
+	var output strings.Builder
+	config := LintConfig{RulesFile: rulesFile, Directory: processFile, Fix: true, DryRun: true}
+	if err := RunLintWithConfig(config, &output); err != nil {
+		t.Fatal("Dry run failed:", err)
+	}
+	if !strings.Contains(output.String(), "-    echo true\n+    debug true\n") {
+		t.Errorf("Expected the dry run to print a diff, got:\n%s", output.String())
+	}
+	if content, _ := os.ReadFile(processFile); string(content) != processContent {
+		t.Errorf("Expected the dry run to leave the file untouched, got:\n%s", content)
+	}
+
+	output.Reset()
+	config.DryRun = false
+	if err := RunLintWithConfig(config, &output); err != nil {
+		t.Fatal("Fix failed:", err)
+	}
+	content, _ := os.ReadFile(processFile)
+	if !strings.Contains(string(content), "    debug true\n") {
+		t.Errorf("Expected echo to be replaced, got:\n%s", content)
+	}
+	if strings.Contains(output.String(), "use debug instead of echo") {
+		t.Errorf("Expected the fixed warning not to be reported, got:\n%s", output.String())
+	}
+}
@@ -0,0 +1,200 @@
+package nf
+
+import (
+	"fmt"
+	"math/big"
+	"reft-go/parser"
+
+	"go.starlark.net/starlark"
+)
+
+// Function is a top-level `def` of a module
+type Function struct {
+	Name   string
+	Params []string
+	Line   int
+	Method *parser.MethodNode
+}
+
+func functionsOf(ast *parser.ModuleNode) []Function {
+	functions := make([]Function, 0, len(ast.Methods))
+	for _, method := range ast.Methods {
+		params := make([]string, len(method.GetParameters()))
+		for i, param := range method.GetParameters() {
+			params[i] = param.GetName()
+		}
+		functions = append(functions, Function{
+			Name:   method.GetName(),
+			Params: params,
+			Line:   method.GetLineNumber(),
+			Method: method,
+		})
+	}
+	return functions
+}
+
+var _ starlark.Value = (*StarlarkFunction)(nil)
+var _ starlark.HasAttrs = (*StarlarkFunction)(nil)
+
+type StarlarkFunction struct {
+	Function
+	ModulePath string
+}
+
+func (f *StarlarkFunction) String() string {
+	return fmt.Sprintf("Function(%s)", f.Name)
+}
+
+func (f *StarlarkFunction) Type() string {
+	return "function_def"
+}
+
+func (f *StarlarkFunction) Freeze() {}
+
+func (f *StarlarkFunction) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (f *StarlarkFunction) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: function_def")
+}
+
+func (f *StarlarkFunction) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "name":
+		return starlark.String(f.Name), nil
+	case "module_path":
+		return starlark.String(f.ModulePath), nil
+	case "line":
+		return starlark.MakeInt(f.Line), nil
+	case "params":
+		return stringList(f.Params), nil
+	default:
+		return nil, starlark.NoSuchAttrError(fmt.Sprintf("function_def has no attribute %q", name))
+	}
+}
+
+func (f *StarlarkFunction) AttrNames() []string {
+	return []string{"name", "module_path", "line", "params"}
+}
+
+var _ starlark.Value = (*StarlarkParam)(nil)
+var _ starlark.HasAttrs = (*StarlarkParam)(nil)
+
+type StarlarkParam struct {
+	ParamInfo
+}
+
+func (p *StarlarkParam) String() string {
+	return fmt.Sprintf("Param(%s)", p.Name)
+}
+
+func (p *StarlarkParam) Type() string {
+	return "param"
+}
+
+func (p *StarlarkParam) Freeze() {}
+
+func (p *StarlarkParam) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (p *StarlarkParam) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: param")
+}
+
+/*
+Attr returns the attributes of the param. The default is None if the
+module doesn't assign the param. A constant default has its Starlark
+type, any other default is its source text, like "${projectDir}/assets".
+*/
+func (p *StarlarkParam) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "name":
+		return starlark.String(p.Name), nil
+	case "line":
+		return starlark.MakeInt(p.LineNumber), nil
+	case "default":
+		if p.Default == nil {
+			return starlark.None, nil
+		}
+		if constant, ok := p.Default.(*parser.ConstantExpression); ok {
+			if value, ok := constantToStarlark(constant.GetValue()); ok {
+				return value, nil
+			}
+		}
+		return starlark.String(p.Default.GetText()), nil
+	default:
+		return nil, starlark.NoSuchAttrError(fmt.Sprintf("param has no attribute %q", name))
+	}
+}
+
+func (p *StarlarkParam) AttrNames() []string {
+	return []string{"name", "line", "default"}
+}
+
+func constantToStarlark(value interface{}) (starlark.Value, bool) {
+	switch v := value.(type) {
+	case nil:
+		return starlark.None, true
+	case bool:
+		return starlark.Bool(v), true
+	case string:
+		return starlark.String(v), true
+	case int:
+		return starlark.MakeInt(v), true
+	case int64:
+		return starlark.MakeInt64(v), true
+	case *big.Int:
+		return starlark.MakeBigInt(v), true
+	case float32:
+		return starlark.Float(v), true
+	case float64:
+		return starlark.Float(v), true
+	case *big.Float:
+		f, _ := v.Float64()
+		return starlark.Float(f), true
+	default:
+		return nil, false
+	}
+}
+
+var _ starlark.Value = (*StarlarkWorkflowCall)(nil)
+var _ starlark.HasAttrs = (*StarlarkWorkflowCall)(nil)
+
+type StarlarkWorkflowCall struct {
+	WorkflowCall
+}
+
+func (c *StarlarkWorkflowCall) String() string {
+	return fmt.Sprintf("Call(%s)", c.Name)
+}
+
+func (c *StarlarkWorkflowCall) Type() string {
+	return "workflow_call"
+}
+
+func (c *StarlarkWorkflowCall) Freeze() {}
+
+func (c *StarlarkWorkflowCall) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (c *StarlarkWorkflowCall) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: workflow_call")
+}
+
+func (c *StarlarkWorkflowCall) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "name":
+		return starlark.String(c.Name), nil
+	case "line":
+		return starlark.MakeInt(c.Line), nil
+	default:
+		return nil, starlark.NoSuchAttrError(fmt.Sprintf("workflow_call has no attribute %q", name))
+	}
+}
+
+func (c *StarlarkWorkflowCall) AttrNames() []string {
+	return []string{"name", "line"}
+}
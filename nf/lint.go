@@ -4,12 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/fatih/color"
 	re "github.com/magnetde/starlark-re"
@@ -21,189 +20,621 @@ type LintConfig struct {
 	RulesFile string
 	Directory string
 	RuleToRun string
+	// Format of the output, text if empty
+	Format Format
+	// Fix applies the fixes attached to diagnostics
+	Fix bool
+	// DryRun writes the fixes as a unified diff instead of applying them
+	DryRun bool
+	// Baseline is a file of known diagnostics that are not reported
+	Baseline string
+	// UpdateBaseline writes the current diagnostics to Baseline instead
+	UpdateBaseline bool
+	// RulesPath are directories load() looks for modules in, before
+	// the rules_path of the settings
+	RulesPath []string
+	// Jobs is how many rule calls run in parallel, the number of CPUs if 0
+	Jobs int
+	// MaxSteps and RuleTimeout limit each call of a rule function,
+	// 0 is no limit. Calls over the limit are reported as errors.
+	MaxSteps    uint64
+	RuleTimeout time.Duration
+	// ProfileRules prints the time and steps each rule took
+	ProfileRules bool
+	// WatchInterval is how often WatchLint polls for changes,
+	// DefaultWatchInterval if 0
+	WatchInterval time.Duration
+	// Cache of the parsed modules, nil parses every module
+	Cache *ModuleCache
+	// ChangedSince is a git ref. Only the diagnostics on lines changed
+	// since are reported, the rules still see the whole project.
+	ChangedSince string
+	// BuiltinRules run besides the registered ones, like the Go rules
+	// selected with --go-rules
+	BuiltinRules []BuiltinRule
+	// Warnings receives notes about the report, like the rule output its
+	// format doesn't show. They are dropped if nil.
+	Warnings io.Writer
+	// LoadSettings reads the settings of the lint root instead of
+	// FindSettings, like corelint.LoadSettings which merges .nf-core.yml.
+	// Its warnings are written to Warnings.
+	LoadSettings func(dir string) (*Settings, []string, error)
+	// SettingsFiles are the names of the other files LoadSettings reads,
+	// watch mode reloads the settings when they change
+	SettingsFiles []string
+}
+
+// loadSettings reads the settings of dir, with LoadSettings if it is set
+func (config LintConfig) loadSettings(dir string) (*Settings, error) {
+	if config.LoadSettings == nil {
+		return FindSettings(dir)
+	}
+	settings, warnings, err := config.LoadSettings(dir)
+	if err != nil {
+		return nil, err
+	}
+	if config.Warnings != nil {
+		for _, warning := range warnings {
+			fmt.Fprintf(config.Warnings, "Warning: %s\n", warning)
+		}
+	}
+	return settings, nil
 }
 
 type RuleModuleOutput struct {
-	Errors  []string
-	Outputs []string
+	Diagnostics []Diagnostic
+	Outputs     []string
+}
+
+// HasErrors reports whether any diagnostic has error severity.
+// Warnings and infos don't fail the lint.
+func (o RuleModuleOutput) HasErrors() bool {
+	for _, d := range o.Diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
 }
 
 // rule -> module -> output
 type GroupedOutput map[string]map[string]RuleModuleOutput
 
+// errLintFailed is returned when there are diagnostics with error severity
+var errLintFailed = errors.New("Linting failed")
+
 func RunLintWithConfig(config LintConfig, output io.Writer) error {
 	if output == nil {
 		output = os.Stdout
 	}
 
+	l, err := newLinter(config)
+	if err != nil {
+		return err
+	}
+	if _, err := l.loadRules(); err != nil {
+		return err
+	}
+
+	// Parse the directory and get the modules. The rules run on the
+	// modules that parse, the others are reported under ParseErrorRule.
+	modules, parseErrors, err := ParseDirectoryCached(l.dir, l.config.Cache)
+	if err != nil {
+		return fmt.Errorf("error processing directory: %v", err)
+	}
+	l.setModules(modules, parseErrors)
+	l.loadConfigs()
+
+	groupedOutput, err := l.lint(nil, output)
+	if err != nil {
+		return err
+	}
+	return l.report(groupedOutput, output)
+}
+
+/*
+linter runs the rules of a rules file on a directory. Watch mode keeps it
+between runs, so only the rules on the files that changed run again.
+*/
+type linter struct {
+	config    LintConfig
+	rulesFile string
+	dir       string
+	// Relative paths given to error() and warning() are relative to this
+	lintRoot string
+	settings *Settings
+	rules    *lintRules
+	modules  map[string]*Module
+	// the modules that failed to parse, by path
+	parseErrors map[string]ParseError
+	configs     map[string]StarlarkConfig
+	// Config files are also loaded for their suppression comments,
+	// so a parse error only matters when there are config rules
+	configErr error
+	// the last call of each module and config rule on each file
+	results map[ruleJobKey]*ruleJob
+}
+
+type ruleJobKey struct {
+	rule string
+	path string
+}
+
+/*
+lintRules are the functions of a rules file: rules (functions starting
+with "rule_"), config rules ("configrule_") and project rules
+("project_rule_").
+*/
+type lintRules struct {
+	modules  map[string]starlark.Callable
+	configs  map[string]starlark.Callable
+	projects map[string]starlark.Callable
+}
+
+func newLinter(config LintConfig) (*linter, error) {
 	// Check if rules file exists
 	if _, err := os.Stat(config.RulesFile); os.IsNotExist(err) {
-		return fmt.Errorf("rules file not found: %s", config.RulesFile)
+		return nil, fmt.Errorf("rules file not found: %s", config.RulesFile)
 	}
 
 	// Convert relative paths to absolute paths
 	rulesFile, _ := filepath.Abs(config.RulesFile)
 	dir, _ := filepath.Abs(config.Directory)
 
-	// Read the rules.py file
-	rulesContent, err := os.ReadFile(rulesFile)
+	lintRoot := dir
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		lintRoot = filepath.Dir(dir)
+	}
+
+	settings, err := config.loadSettings(lintRoot)
 	if err != nil {
-		return fmt.Errorf("error reading rules.py file: %v", err)
+		return nil, err
 	}
 
 	// Remove the 'fail' function from the Universe
 	delete(starlark.Universe, "fail")
 
-	var outputMutex sync.Mutex
-	groupedOutput := make(GroupedOutput)
+	return &linter{
+		config:      config,
+		rulesFile:   rulesFile,
+		dir:         dir,
+		lintRoot:    lintRoot,
+		settings:    settings,
+		modules:     make(map[string]*Module),
+		parseErrors: make(map[string]ParseError),
+		configs:     make(map[string]StarlarkConfig),
+		results:     make(map[ruleJobKey]*ruleJob),
+	}, nil
+}
 
-	// Create a new Starlark thread with a custom print function
-	thread := &starlark.Thread{
-		Name: "lint_thread",
-		Print: func(thread *starlark.Thread, msg string) {
-			outputMutex.Lock()
-			defer outputMutex.Unlock()
+// enabled reports whether a rule runs. --name runs the rule even if the
+// settings disable it.
+func (l *linter) enabled(ruleName string) bool {
+	if l.config.RuleToRun != "" {
+		return ruleName == l.config.RuleToRun
+	}
+	return l.settings.RuleEnabled(ruleName)
+}
 
-			ruleName := thread.Local("current_rule").(string)
-			moduleName := thread.Local("current_module").(string)
+// loadRules runs the rules file and returns the files it loaded, which
+// are returned even if it fails. The rules run again on every file after.
+func (l *linter) loadRules() ([]string, error) {
+	// The thread the rules file is loaded on, rules run on the
+	// threads of the ruleRunner
+	thread := newLintThread("lint_thread", l.lintRoot)
 
-			entry := groupedOutput[ruleName][moduleName]
-			entry.Outputs = append(entry.Outputs, msg)
-			groupedOutput[ruleName][moduleName] = entry
-		},
+	// Create predefined variables for the Starlark environment
+	predefined := starlark.StringDict{
+		"fatal":   starlark.NewBuiltin("fatal", fatalFunc),
+		"error":   starlark.NewBuiltin("error", diagnosticFunc(SeverityError)),
+		"warning": starlark.NewBuiltin("warning", diagnosticFunc(SeverityWarning)),
+		"edit":    starlark.NewBuiltin("edit", editFunc),
+		"walk":    starlark.NewBuiltin("walk", walkFunc),
+		"re":      re.NewModule(), // Add the regex module
+	}
+
+	// Modules loaded by the rules file see the same predeclared functions
+	rulesPath := make([]string, 0, len(l.config.RulesPath)+len(l.settings.RulesPath))
+	for _, searchDir := range l.config.RulesPath {
+		searchDir, _ = filepath.Abs(searchDir)
+		rulesPath = append(rulesPath, searchDir)
+	}
+	rulesPath = append(rulesPath, l.settings.RulesPath...)
+	loader := newRuleLoader(l.rulesFile, rulesPath, predefined)
+	thread.Load = loader.loadFunc(l.rulesFile)
+	// the limits of the rule calls apply to loading the rules file and
+	// the modules it loads too
+	if l.config.MaxSteps > 0 {
+		thread.SetMaxExecutionSteps(l.config.MaxSteps)
+		loader.maxSteps = l.config.MaxSteps
+	}
+
+	l.rules = nil
+	l.results = make(map[ruleJobKey]*ruleJob)
+
+	// Read the rules.py file
+	rulesContent, err := os.ReadFile(l.rulesFile)
+	if err != nil {
+		return loader.files(), fmt.Errorf("error reading rules.py file: %v", err)
 	}
 
 	fo := &syntax.FileOptions{}
 
 	// Parse the Starlark code without executing it
-	f, err := fo.Parse(rulesFile, rulesContent, 0)
+	f, err := fo.Parse(l.rulesFile, rulesContent, 0)
 	if err != nil {
-		log.Fatalf("Error parsing rules program: %v", err)
+		return loader.files(), fmt.Errorf("error parsing rules program: %v", err)
 	}
 
 	// Compile the parsed code
 	prog, err := starlark.FileProgram(f, func(name string) bool {
-		if name == "fatal" || name == "error" || name == "re" {
-			return true
-		}
-		return false
+		_, ok := predefined[name]
+		return ok
 	})
 	if err != nil {
-		log.Fatalf("Error compiling rules program: %v", err)
-	}
-
-	// Create predefined variables for the Starlark environment
-	predefined := starlark.StringDict{
-		"fatal": starlark.NewBuiltin("fatal", fatalFunc),
-		"error": starlark.NewBuiltin("error", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-			sep := " "
-			if err := starlark.UnpackArgs("error", nil, kwargs, "sep?", &sep); err != nil {
-				return nil, err
-			}
-			buf := new(strings.Builder)
-			for i, v := range args {
-				if i > 0 {
-					buf.WriteString(sep)
-				}
-				if s, ok := starlark.AsString(v); ok {
-					buf.WriteString(s)
-				} else {
-					buf.WriteString(v.String())
-				}
-			}
-
-			outputMutex.Lock()
-			defer outputMutex.Unlock()
-
-			ruleName := thread.Local("current_rule").(string)
-			moduleName := thread.Local("current_module").(string)
-
-			entry := groupedOutput[ruleName][moduleName]
-			entry.Errors = append(entry.Errors, buf.String())
-			groupedOutput[ruleName][moduleName] = entry
-
-			return starlark.None, nil
-		}),
-		"re": re.NewModule(), // Add the regex module
+		return loader.files(), fmt.Errorf("error compiling rules program: %v", err)
 	}
 
 	// Execute the compiled program
+	if l.config.RuleTimeout > 0 {
+		timer := time.AfterFunc(l.config.RuleTimeout, func() {
+			thread.Cancel("timeout")
+			loader.cancel("timeout")
+		})
+		defer timer.Stop()
+	}
 	globals, err := prog.Init(thread, predefined)
 	if err != nil {
-		log.Fatalf("Error initializing rules program: %v", err)
+		return loader.files(), fmt.Errorf("error initializing rules program: %v", err)
 	}
+	// The rules run in parallel
+	globals.Freeze()
 
-	// Collect rules (functions starting with "rule_")
-	rules := make(map[string]starlark.Callable)
+	rules := &lintRules{
+		modules:  make(map[string]starlark.Callable),
+		configs:  make(map[string]starlark.Callable),
+		projects: make(map[string]starlark.Callable),
+	}
 	for name, value := range globals {
 		if strings.HasPrefix(name, "rule_") {
 			if callable, ok := value.(starlark.Callable); ok {
 				strippedRuleName := strings.TrimPrefix(name, "rule_")
-				rules[strippedRuleName] = callable
+				rules.modules[strippedRuleName] = callable
+			}
+		} else if strings.HasPrefix(name, "configrule_") {
+			if callable, ok := value.(starlark.Callable); ok {
+				strippedRuleName := strings.TrimPrefix(name, "configrule_")
+				rules.configs[strippedRuleName] = callable
+			}
+		} else if strings.HasPrefix(name, "project_rule_") {
+			if callable, ok := value.(starlark.Callable); ok {
+				strippedRuleName := strings.TrimPrefix(name, "project_rule_")
+				rules.projects[strippedRuleName] = callable
 			}
 		}
 	}
+	l.rules = rules
+	return loader.files(), nil
+}
 
-	// Create the params list
-	/*
-		params, includes := parse(rulesFile)
-		starlarkParams := make([]starlark.Value, len(params))
-		for i, param := range params {
-			starlarkParams[i] = NewStarlarkParamInfo(param)
-		}
-		_ = starlark.NewList(starlarkParams)
-	*/
+func (l *linter) setModules(modules []*Module, parseErrors []ParseError) {
+	l.modules = make(map[string]*Module, len(modules))
+	for _, module := range modules {
+		l.modules[module.Path] = module
+	}
+	l.parseErrors = make(map[string]ParseError, len(parseErrors))
+	for _, parseErr := range parseErrors {
+		l.parseErrors[parseErr.Path] = parseErr
+	}
+}
+
+// sortedModules returns the modules sorted by path
+func (l *linter) sortedModules() []*Module {
+	modules := make([]*Module, 0, len(l.modules))
+	for _, module := range l.modules {
+		modules = append(modules, module)
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Path < modules[j].Path })
+	return modules
+}
 
-	/*
-		starlarkIncludes := make([]starlark.Value, len(includes))
-		for i, include := range includes {
-			starlarkIncludes[i] = NewStarlarkIncludeInfo(include)
+func (l *linter) loadConfigs() {
+	l.configs = make(map[string]StarlarkConfig)
+	l.configErr = nil
+	if configLoader == nil {
+		return
+	}
+	configs, err := configLoader(l.dir)
+	l.configErr = err
+	for _, configFile := range configs {
+		configFile.Value.Freeze()
+		l.configs[configFile.Path] = configFile
+	}
+}
+
+// sortedConfigs returns the config files sorted by path
+func (l *linter) sortedConfigs() []StarlarkConfig {
+	configs := make([]StarlarkConfig, 0, len(l.configs))
+	for _, configFile := range l.configs {
+		configs = append(configs, configFile)
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Path < configs[j].Path })
+	return configs
+}
+
+/*
+lint runs the rules and returns their output, with the suppressions and
+the settings applied.
+
+Module and config rules run again on the files in changed, and on the
+files they didn't run on before. The results of the previous run are
+reused for the others. Project rules and built-in rules always run.
+*/
+func (l *linter) lint(changed map[string]bool, output io.Writer) (GroupedOutput, error) {
+	if len(l.rules.configs) > 0 {
+		if configLoader == nil {
+			return nil, fmt.Errorf("configrule_ functions are not supported in this build")
 		}
-		_ = starlark.NewList(starlarkIncludes)
-	*/
+		if l.configErr != nil {
+			return nil, fmt.Errorf("error processing config files: %v", l.configErr)
+		}
+	}
 
-	// Parse the directory and get the modules
-	modules, err := ProcessDirectory(dir)
-	if err != nil {
-		return fmt.Errorf("error processing directory: %v", err)
+	modules := l.sortedModules()
+	configs := l.sortedConfigs()
+	groupedOutput := make(GroupedOutput)
+	results := make(map[ruleJobKey]*ruleJob)
+	// jobs are the calls to run, all the calls whose output is reported
+	var jobs, all []*ruleJob
+	queue := func(job *ruleJob, cached bool) {
+		key := ruleJobKey{job.rule, job.path}
+		if previous, ok := l.results[key]; ok && cached && !changed[job.path] {
+			job = previous
+		} else {
+			jobs = append(jobs, job)
+		}
+		if cached {
+			results[key] = job
+		}
+		all = append(all, job)
 	}
 
-	// Execute each rule
-	for ruleName, ruleFunc := range rules {
-		if config.RuleToRun != "" && ruleName != config.RuleToRun {
+	// Queue each rule on each module
+	for _, ruleName := range sortedRuleNames(l.rules.modules) {
+		if !l.enabled(ruleName) {
 			continue
 		}
 		groupedOutput[ruleName] = make(map[string]RuleModuleOutput)
 		for _, module := range modules {
-			groupedOutput[ruleName][module.Path] = RuleModuleOutput{}
-			starlarkModule := ConvertToStarlarkModule(module)
+			if !l.settings.Applies(ruleName, module.Path) {
+				continue
+			}
+			queue(&ruleJob{
+				rule: ruleName,
+				fn:   l.rules.modules[ruleName],
+				path: module.Path,
+				arg:  func() starlark.Value { return ConvertToStarlarkModule(module) },
+			}, true)
+		}
+	}
 
-			// Set the current rule and module context
-			thread.SetLocal("current_rule", ruleName)
-			thread.SetLocal("current_module", module.Path)
+	// Queue each config rule on each config file
+	for _, ruleName := range sortedRuleNames(l.rules.configs) {
+		if !l.enabled(ruleName) {
+			continue
+		}
+		groupedOutput[ruleName] = make(map[string]RuleModuleOutput)
+		for _, configFile := range configs {
+			if !l.settings.Applies(ruleName, configFile.Path) {
+				continue
+			}
+			queue(&ruleJob{
+				rule: ruleName,
+				fn:   l.rules.configs[ruleName],
+				path: configFile.Path,
+				arg:  func() starlark.Value { return configFile.Value },
+			}, true)
+		}
+	}
 
-			_, err := starlark.Call(thread, ruleFunc, starlark.Tuple{starlarkModule}, nil)
-			if err != nil {
-				if evalErr, ok := err.(*starlark.EvalError); ok {
-					entry := groupedOutput[ruleName][module.Path]
-					entry.Errors = append(entry.Errors, evalErr.Msg)
-					groupedOutput[ruleName][module.Path] = entry
-					//fmt.Printf("Rule %s execution failed: %s\n", ruleName, evalErr.Msg)
-				} else {
-					log.Fatalf("Error calling rule %s: %v\n", ruleName, err)
-				}
+	// Queue each project rule once, on the whole pipeline.
+	// Diagnostics default to the pipeline directory, rules pass path=
+	// to report them on a file.
+	if len(l.rules.projects) > 0 {
+		project := &StarlarkProject{NewProject(l.dir, modules, configs)}
+		for _, ruleName := range sortedRuleNames(l.rules.projects) {
+			if !l.enabled(ruleName) {
+				continue
+			}
+			groupedOutput[ruleName] = make(map[string]RuleModuleOutput)
+			queue(&ruleJob{
+				rule: ruleName,
+				fn:   l.rules.projects[ruleName],
+				path: l.dir,
+				arg:  func() starlark.Value { return project },
+			}, false)
+		}
+	}
+
+	runner := &ruleRunner{
+		workers:  l.config.Jobs,
+		lintRoot: l.lintRoot,
+		settings: l.settings,
+		maxSteps: l.config.MaxSteps,
+		timeout:  l.config.RuleTimeout,
+	}
+	if err := runner.run(jobs); err != nil {
+		return nil, err
+	}
+	mergeOutputs(groupedOutput, all)
+	profiles := make(ruleProfiles)
+	for _, job := range jobs {
+		profiles.add(job.rule, job.path, job.duration, job.steps)
+	}
+
+	// Execute the built-in rules
+	for _, rule := range append(builtinRules[:len(builtinRules):len(builtinRules)], l.config.BuiltinRules...) {
+		if !l.enabled(rule.Name) {
+			continue
+		}
+		if _, ok := groupedOutput[rule.Name]; ok {
+			return nil, fmt.Errorf("built-in rule %s has the same name as another rule", rule.Name)
+		}
+		start := time.Now()
+		results, err := rule.Run(l.dir, modules)
+		if err != nil {
+			return nil, fmt.Errorf("error running built-in rule %s: %v", rule.Name, err)
+		}
+		profiles.add(rule.Name, l.dir, time.Since(start), 0)
+		groupedOutput[rule.Name] = results
+	}
+	l.results = results
+
+	if len(l.parseErrors) > 0 && l.settings.RuleEnabled(ParseErrorRule) {
+		groupedOutput[ParseErrorRule] = make(map[string]RuleModuleOutput)
+		for path, parseErr := range l.parseErrors {
+			groupedOutput[ParseErrorRule][path] = RuleModuleOutput{Diagnostics: []Diagnostic{parseErr.Diagnostic()}}
+		}
+	}
+
+	if l.config.ProfileRules {
+		// keep machine readable output parseable
+		profileOutput := output
+		if l.config.Format != "" && l.config.Format != FormatText {
+			profileOutput = os.Stderr
+		}
+		PrintRuleProfile(profileOutput, profiles.sorted())
+	}
+
+	suppressions := make(map[string]*Suppressions)
+	for _, module := range modules {
+		suppressions[module.Path] = ParseSuppressions(module.Path, module.Comments)
+	}
+	for _, configFile := range configs {
+		suppressions[configFile.Path] = ParseSuppressions(configFile.Path, configFile.Comments)
+	}
+	ApplySuppressions(groupedOutput, suppressions, func(rule string) bool {
+		if rule == "" {
+			return l.config.RuleToRun == ""
+		}
+		_, ok := groupedOutput[rule]
+		return ok
+	})
+	if l.config.RuleToRun == "" && !l.settings.RuleEnabled(UnusedSuppressionRule) {
+		delete(groupedOutput, UnusedSuppressionRule)
+	}
+	l.settings.Apply(groupedOutput, l.dir)
+	return groupedOutput, nil
+}
+
+// report applies the baseline and the fixes, and writes the output. It
+// returns errLintFailed if there are errors left.
+func (l *linter) report(groupedOutput GroupedOutput, output io.Writer) error {
+	config := l.config
+	modules := l.sortedModules()
+
+	unchanged := 0
+	if config.ChangedSince != "" {
+		if config.UpdateBaseline {
+			return fmt.Errorf("--update-baseline can't be used with --changed-since")
+		}
+		changed, err := GitChangedLines(l.lintRoot, config.ChangedSince)
+		if err != nil {
+			return fmt.Errorf("error finding the lines changed since %s: %v", config.ChangedSince, err)
+		}
+		unchanged = changed.Filter(groupedOutput, l.dir)
+	}
+
+	if config.UpdateBaseline {
+		if config.Baseline == "" {
+			return fmt.Errorf("--update-baseline requires --baseline")
+		}
+		baseline := NewBaseline(groupedOutput, l.lintRoot, modules)
+		if err := baseline.Save(config.Baseline); err != nil {
+			return fmt.Errorf("error writing baseline: %v", err)
+		}
+		fmt.Fprintf(output, "Wrote %d problem(s) to %s\n", baseline.Total(), config.Baseline)
+		return nil
+	}
+	baselined := 0
+	if config.Baseline != "" {
+		baseline, err := LoadBaseline(config.Baseline)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("baseline file not found: %s (create it with --update-baseline)", config.Baseline)
+		}
+		if err != nil {
+			return fmt.Errorf("error reading baseline: %v", err)
+		}
+		baselined = baseline.Filter(groupedOutput, l.lintRoot, modules)
+	}
+
+	if config.Fix {
+		result, err := FixFiles(groupedOutput, config.DryRun, output)
+		if err != nil {
+			return fmt.Errorf("error applying fixes: %v", err)
+		}
+		RemoveFixed(groupedOutput, result)
+		// the diff is the only output of a dry run, it fails if errors
+		// would be left after the fixes
+		if config.DryRun {
+			if groupedOutput.HasErrors() {
+				return errLintFailed
 			}
+			return nil
+		}
+		if config.Format == "" || config.Format == FormatText {
+			PrintFixSummary(output, result)
 		}
 	}
 
-	hasErrors := printGroupedOutput(groupedOutput, output)
+	hasErrors, err := WriteReport(output, config.Format, groupedOutput)
+	if err != nil {
+		return fmt.Errorf("error writing output: %v", err)
+	}
+	if note := DroppedOutputNote(config.Format, groupedOutput); note != "" && config.Warnings != nil {
+		fmt.Fprintln(config.Warnings, note)
+	}
+	if baselined > 0 && (config.Format == "" || config.Format == FormatText) {
+		fmt.Fprintf(output, "%d problem(s) in the baseline not shown\n", baselined)
+	}
+	if unchanged > 0 && (config.Format == "" || config.Format == FormatText) {
+		fmt.Fprintf(output, "%d problem(s) on lines unchanged since %s not shown\n", unchanged, config.ChangedSince)
+	}
 	if hasErrors {
-		return fmt.Errorf("Linting failed")
+		return errLintFailed
 	}
 
 	return nil
 }
 
+func sortedRuleNames(rules map[string]starlark.Callable) []string {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ruleKwargs passes the config of a rule from the settings to rule
+// functions that have a config parameter.
+func ruleKwargs(settings *Settings, ruleName string, ruleFunc starlark.Callable) ([]starlark.Tuple, error) {
+	fn, ok := ruleFunc.(*starlark.Function)
+	if !ok {
+		return nil, nil
+	}
+	for i := 0; i < fn.NumParams(); i++ {
+		if name, _ := fn.Param(i); name == "config" {
+			ruleConfig, err := settings.RuleConfig(ruleName)
+			if err != nil {
+				return nil, err
+			}
+			return []starlark.Tuple{{starlark.String("config"), ruleConfig}}, nil
+		}
+	}
+	return nil, nil
+}
+
 func printGroupedOutput(groupedOutput GroupedOutput, output io.Writer) bool {
 	hasErrors := false
 	ruleNames := make([]string, 0, len(groupedOutput))
@@ -215,6 +646,8 @@ func printGroupedOutput(groupedOutput GroupedOutput, output io.Writer) bool {
 	rulePrinter := color.New(color.FgCyan, color.Bold)
 	modulePrinter := color.New(color.FgYellow)
 	errorPrinter := color.New(color.FgRed)
+	warningPrinter := color.New(color.FgMagenta)
+	infoPrinter := color.New(color.FgBlue)
 	outputPrinter := color.New(color.FgGreen)
 
 	fmt.Fprintln(output) // Start with a blank line
@@ -230,11 +663,27 @@ func printGroupedOutput(groupedOutput GroupedOutput, output io.Writer) bool {
 
 		for _, moduleName := range moduleNames {
 			entry := groupedOutput[ruleName][moduleName]
-			if len(entry.Errors) > 0 || len(entry.Outputs) > 0 {
+			if len(entry.Diagnostics) > 0 || len(entry.Outputs) > 0 {
 				modulePrinter.Fprintf(output, "  Module: %s\n", moduleName)
-				for _, e := range entry.Errors {
+				if entry.HasErrors() {
 					hasErrors = true
-					errorPrinter.Fprintf(output, "    Error: %s\n", e)
+				}
+				for _, d := range entry.Diagnostics {
+					printer, label := errorPrinter, "Error"
+					switch d.Severity {
+					case SeverityWarning:
+						printer, label = warningPrinter, "Warning"
+					case SeverityInfo:
+						printer, label = infoPrinter, "Info"
+					}
+					msg := d.Message
+					if d.Line > 0 {
+						msg = d.Location() + ": " + msg
+					}
+					if d.Code != "" {
+						msg += " [" + d.Code + "]"
+					}
+					printer.Fprintf(output, "    %s: %s\n", label, msg)
 				}
 				for _, o := range entry.Outputs {
 					outputPrinter.Fprintf(output, "    Output: %s\n", o)
@@ -246,6 +695,89 @@ func printGroupedOutput(groupedOutput GroupedOutput, output io.Writer) bool {
 	return hasErrors
 }
 
+// PrintFixSummary reports how many problems --fix fixed.
+func PrintFixSummary(output io.Writer, result *FixResult) {
+	fmt.Fprintf(output, "\nFixed %d problem(s) in %d file(s)\n", len(result.Applied), len(result.Files))
+	if len(result.Conflicts) > 0 {
+		color.New(color.FgMagenta).Fprintf(output, "Skipped %d conflicting fix(es), run again to apply them\n", len(result.Conflicts))
+	}
+}
+
+/*
+diagnosticFunc implements error() and warning():
+
+	error(*args, sep=" ", line=0, column=0, severity="error", code="", fix=None, path="")
+
+The message is built from the positional arguments like print().
+The diagnostic is reported for the module the current rule runs on,
+or for path if given. Relative paths are relative to the linted directory.
+fix is an edit() or a list of edits that --fix applies.
+*/
+func diagnosticFunc(defaultSeverity Severity) func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error) {
+	return func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		sep := " "
+		line, column := 0, 0
+		severity := string(defaultSeverity)
+		code := ""
+		var fixValue starlark.Value = starlark.None
+		path := ""
+		if err := starlark.UnpackArgs(b.Name(), nil, kwargs, "sep?", &sep, "line?", &line, "column?", &column, "severity?", &severity, "code?", &code, "fix?", &fixValue, "path?", &path); err != nil {
+			return nil, err
+		}
+		sev, err := ParseSeverity(severity)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", b.Name(), err)
+		}
+		if line < 0 || column < 0 {
+			return nil, fmt.Errorf("%s: line and column must not be negative", b.Name())
+		}
+		buf := new(strings.Builder)
+		for i, v := range args {
+			if i > 0 {
+				buf.WriteString(sep)
+			}
+			if s, ok := starlark.AsString(v); ok {
+				buf.WriteString(s)
+			} else {
+				buf.WriteString(v.String())
+			}
+		}
+		var fix *Fix
+		if fixValue != starlark.None {
+			if fix, err = fixFromStarlark(fixValue, buf.String()); err != nil {
+				return nil, fmt.Errorf("%s: %v", b.Name(), err)
+			}
+		}
+
+		output, ok := thread.Local("output").(GroupedOutput)
+		if !ok {
+			return nil, fmt.Errorf("%s: called outside of a rule", b.Name())
+		}
+		ruleName := thread.Local("current_rule").(string)
+		moduleName := thread.Local("current_module").(string)
+		if path != "" {
+			moduleName = path
+			if !filepath.IsAbs(path) {
+				moduleName = filepath.Join(thread.Local("lint_root").(string), path)
+			}
+		}
+
+		entry := output[ruleName][moduleName]
+		entry.Diagnostics = append(entry.Diagnostics, Diagnostic{
+			Path:     moduleName,
+			Line:     line,
+			Column:   column,
+			Severity: sev,
+			Code:     code,
+			Message:  buf.String(),
+			Fix:      fix,
+		})
+		output[ruleName][moduleName] = entry
+
+		return starlark.None, nil
+	}
+}
+
 // failnowFunc is the implementation of the failnow function for Starlark
 func fatalFunc(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	sep := " "
@@ -1,6 +1,7 @@
 package nf
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
@@ -696,3 +697,397 @@ process FOO {
 		t.Errorf("Expected output:\n%q\nbut got:\n%q", expectedOutput, outputStr)
 	}
 }
+
+func TestDiagnostics(t *testing.T) {
+	rulesContent := `
+def rule_positions(module):
+    for process in module.processes:
+        for cpus in process.directives.cpus:
+            if cpus.num > 8:
+                error("too many cpus", line=cpus.line, column=5, code="max-cpus")
+        warning("process", process.name, line=process.line)
+`
+	processContent := `
+process FOO {
+    cpus 16
+    script:
+    """
+    echo "test"
+    """
+}
+`
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	processFile := filepath.Join(tmpDir, "process.nf")
+	if err := os.WriteFile(rulesFile, []byte(rulesContent), 0644); err != nil {
+		t.Fatal("Failed to write rules file:", err)
+	}
+	if err := os.WriteFile(processFile, []byte(processContent), 0644); err != nil {
+		t.Fatal("Failed to write process file:", err)
+	}
+
+	var output strings.Builder
+	err := RunLintWithConfig(LintConfig{RulesFile: rulesFile, Directory: processFile}, &output)
+	if err == nil {
+		t.Fatal("Expected linting to fail, but it succeeded")
+	}
+
+	for _, expected := range []string{
+		"Error: " + processFile + ":3:5: too many cpus [max-cpus]",
+		"Warning: " + processFile + ":2: process FOO",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Expected output to contain %q, but got:\n%s", expected, output.String())
+		}
+	}
+}
+
+func TestDiagnosticsInvalidSeverity(t *testing.T) {
+	rulesContent := `
+def rule_bad_severity(module):
+    error("oops", severity="fatal")
+`
+	processContent := `
+process FOO {
+    script:
+    """
+    echo "test"
+    """
+}
+`
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	processFile := filepath.Join(tmpDir, "process.nf")
+	if err := os.WriteFile(rulesFile, []byte(rulesContent), 0644); err != nil {
+		t.Fatal("Failed to write rules file:", err)
+	}
+	if err := os.WriteFile(processFile, []byte(processContent), 0644); err != nil {
+		t.Fatal("Failed to write process file:", err)
+	}
+
+	var output strings.Builder
+	if err := RunLintWithConfig(LintConfig{RulesFile: rulesFile, Directory: processFile}, &output); err == nil {
+		t.Fatal("Expected linting to fail, but it succeeded")
+	}
+	if !strings.Contains(output.String(), `unknown severity "fatal"`) {
+		t.Errorf("Expected an unknown severity error, but got:\n%s", output.String())
+	}
+}
+
+func TestProjectRules(t *testing.T) {
+	rulesContent := `
+def project_rule_unique_process_names(pipeline):
+    seen = {}
+    for module in pipeline.modules:
+        for process in module.processes:
+            if process.name in seen:
+                error("process", process.name, "is also defined in", seen[process.name], path=module.path, line=process.line)
+            else:
+                seen[process.name] = module.path
+
+def project_rule_entry(pipeline):
+    workflow = pipeline.entry_workflow
+    if workflow == None:
+        error("no entry workflow")
+        return
+    for include in pipeline.includes:
+        if not include.resolved:
+            error("unresolved include", include.source, path=include.module_path, line=include.line)
+    warning("entry workflow in", workflow.module_path, path="main.nf", line=workflow.line)
+`
+	mainContent := `
+include { FOO } from './modules/foo'
+include { BAR } from './modules/bar'
+
+workflow {
+    FOO()
+}
+`
+	fooContent := `
+process FOO {
+    script:
+    """
+    echo "test"
+    """
+}
+`
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	pipelineDir := filepath.Join(tmpDir, "pipeline")
+	mainFile := filepath.Join(pipelineDir, "main.nf")
+	fooFile := filepath.Join(pipelineDir, "modules", "foo.nf")
+	otherFile := filepath.Join(pipelineDir, "modules", "other.nf")
+	if err := os.MkdirAll(filepath.Dir(fooFile), 0755); err != nil {
+		t.Fatal("Failed to create module directory:", err)
+	}
+	for path, content := range map[string]string{
+		rulesFile: rulesContent,
+		mainFile:  mainContent,
+		fooFile:   fooContent,
+		otherFile: fooContent,
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal("Failed to write file:", err)
+		}
+	}
+
+	var output strings.Builder
+	err := RunLintWithConfig(LintConfig{RulesFile: rulesFile, Directory: pipelineDir}, &output)
+	if err == nil {
+		t.Fatal("Expected linting to fail, but it succeeded")
+	}
+
+	for _, expected := range []string{
+		"Error: " + otherFile + ":2: process FOO is also defined in " + fooFile,
+		"Error: " + mainFile + ":3: unresolved include ./modules/bar",
+		"Warning: " + mainFile + ":5: entry workflow in " + mainFile,
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Expected output to contain %q, but got:\n%s", expected, output.String())
+		}
+	}
+	if strings.Contains(output.String(), "unresolved include ./modules/foo") {
+		t.Errorf("Expected ./modules/foo to resolve, but got:\n%s", output.String())
+	}
+}
+
+func TestLintSettings(t *testing.T) {
+	rulesContent := `
+def rule_max_cpus(module, config):
+    for process in module.processes:
+        for directive in process.directives.cpus:
+            if directive.num > config["max"]:
+                error("process", process.name, "uses more than", config["max"], "cpus", line=directive.line)
+
+def rule_no_labels(module):
+    for process in module.processes:
+        if len(process.directives.label) == 0:
+            error("process", process.name, "has no labels")
+`
+	processContent := `
+process FOO {
+    cpus 16
+
+    script:
+    """
+    echo "test"
+    """
+}
+`
+	settingsContent := `
+rules:
+  no_labels: false
+  max_cpus:
+    severity: warning
+    exclude: ["tests/**"]
+    config:
+      max: 8
+`
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	mainFile := filepath.Join(tmpDir, "main.nf")
+	testFile := filepath.Join(tmpDir, "tests", "main.nf")
+	if err := os.MkdirAll(filepath.Dir(testFile), 0755); err != nil {
+		t.Fatal("Failed to create test directory:", err)
+	}
+	for path, content := range map[string]string{
+		rulesFile:                             rulesContent,
+		mainFile:                              processContent,
+		testFile:                              processContent,
+		filepath.Join(tmpDir, "reftrace.yml"): settingsContent,
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal("Failed to write file:", err)
+		}
+	}
+
+	var output strings.Builder
+	err := RunLintWithConfig(LintConfig{RulesFile: rulesFile, Directory: tmpDir}, &output)
+	if err != nil {
+		t.Fatalf("Expected only warnings, got %v:\n%s", err, output.String())
+	}
+	expected := "Warning: " + mainFile + ":3: process FOO uses more than 8 cpus"
+	if !strings.Contains(output.String(), expected) {
+		t.Errorf("Expected output to contain %q, but got:\n%s", expected, output.String())
+	}
+	for _, unexpected := range []string{testFile, "has no labels"} {
+		if strings.Contains(output.String(), unexpected) {
+			t.Errorf("Expected output not to contain %q, but got:\n%s", unexpected, output.String())
+		}
+	}
+}
+
+func TestModuleAttributes(t *testing.T) {
+	rulesContent := `
+def rule_describe(module):
+    print("dsl", module.dsl_version)
+    for param in module.params:
+        print("param", param.name, param.line, repr(param.default))
+    for function in module.functions:
+        print("function", function.name, function.line, function.params)
+    for workflow in module.workflows:
+        print("workflow", workflow.name or "<entry>", [call.name for call in workflow.calls])
+`
+	mainContent := `include { FOO as ALIGN } from './modules/foo'
+
+params.input = null
+params.max_cpus = 16
+params.outdir = "${projectDir}/results"
+
+def greet(name, greeting) {
+    return "${greeting} ${name}"
+}
+
+process BAR {
+    script:
+    """
+    echo "test"
+    """
+}
+
+workflow PREPARE {
+    take:
+    reads
+
+    main:
+    ALIGN(reads)
+    reads | BAR
+    println(greet("a", "b"))
+
+    emit:
+    out = ALIGN.out
+}
+
+workflow {
+    PREPARE(Channel.fromPath(params.input))
+}
+`
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	mainFile := filepath.Join(tmpDir, "main.nf")
+	for path, content := range map[string]string{rulesFile: rulesContent, mainFile: mainContent} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal("Failed to write file:", err)
+		}
+	}
+
+	var output strings.Builder
+	if err := RunLintWithConfig(LintConfig{RulesFile: rulesFile, Directory: mainFile}, &output); err != nil {
+		t.Fatalf("Linting failed: %v\n%s", err, output.String())
+	}
+	for _, expected := range []string{
+		"Output: dsl 2",
+		"Output: param input 3 None",
+		"Output: param max_cpus 4 16",
+		// a default that isn't a constant is its source text
+		`Output: param outdir 5 "`,
+		`projectDir}/results"`,
+		`Output: function greet 7 ["name", "greeting"]`,
+		`Output: workflow PREPARE ["ALIGN", "BAR"]`,
+		`Output: workflow <entry> ["PREPARE"]`,
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Expected output to contain %q, but got:\n%s", expected, output.String())
+		}
+	}
+}
+
+func TestAST(t *testing.T) {
+	rulesContent := `
+def rule_ast(module):
+    print("module", module.ast.kind, [child.kind for child in module.ast.children])
+    body = module.processes[0].body
+    calls = []
+    def visit(node):
+        if node.kind == "MethodCallExpression":
+            calls.append((node.name, node.line))
+        # skip the closures in the body
+        return node.kind != "ClosureExpression" or node.line == body.line
+    walk(body, visit)
+    print("process calls", calls)
+    numbers = []
+    def visit_numbers(node):
+        if type(node.value) == "int":
+            numbers.append(node.value)
+    walk(module.workflows[0].body, visit_numbers)
+    print("workflow numbers", numbers)
+`
+	mainContent := `def twice(x) {
+    return x * 2
+}
+
+process FOO {
+    cpus 2
+    memory { task.attempt * 2.GB }
+
+    script:
+    """
+    echo hello
+    """
+}
+
+workflow {
+    FOO()
+    println(twice(21))
+}
+`
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	mainFile := filepath.Join(tmpDir, "main.nf")
+	for path, content := range map[string]string{rulesFile: rulesContent, mainFile: mainContent} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal("Failed to write file:", err)
+		}
+	}
+
+	var output strings.Builder
+	if err := RunLintWithConfig(LintConfig{RulesFile: rulesFile, Directory: mainFile}, &output); err != nil {
+		t.Fatalf("Linting failed: %v\n%s", err, output.String())
+	}
+	for _, expected := range []string{
+		`Output: module ModuleNode ["MethodNode", "ExpressionStatement", "ExpressionStatement"]`,
+		`Output: process calls [("cpus", 6), ("memory", 7)]`,
+		`Output: workflow numbers [21]`,
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Expected output to contain %q, but got:\n%s", expected, output.String())
+		}
+	}
+}
+
+func TestLintParseErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	writeFiles(t, map[string]string{
+		rulesFile:                        "def rule_names(module):\n    print(\"linted\", [p.name for p in module.processes])\n",
+		filepath.Join(tmpDir, "good.nf"): "process GOOD {\n    script:\n    \"\"\"\n    echo test\n    \"\"\"\n}\n",
+		filepath.Join(tmpDir, "dsl1.nf"): "nextflow.enable.dsl=1\n",
+	})
+
+	var output strings.Builder
+	err := RunLintWithConfig(LintConfig{RulesFile: rulesFile, Directory: tmpDir, Format: FormatJSON}, &output)
+	if err == nil {
+		t.Fatal("Expected the parse error to fail the lint")
+	}
+
+	var report struct {
+		Diagnostics []jsonDiagnostic `json:"diagnostics"`
+	}
+	if err := json.Unmarshal([]byte(output.String()), &report); err != nil {
+		t.Fatalf("Failed to parse output: %v\n%s", err, output.String())
+	}
+	if len(report.Diagnostics) != 1 {
+		t.Fatalf("Expected one diagnostic, got %+v", report.Diagnostics)
+	}
+	d := report.Diagnostics[0]
+	if d.Rule != ParseErrorRule || d.Code != "parse-error" || d.Severity != SeverityError || !strings.HasSuffix(d.Path, "dsl1.nf") {
+		t.Errorf("Expected a parse error on dsl1.nf, got %+v", d)
+	}
+
+	// the rules still run on the modules that parse
+	output.Reset()
+	RunLintWithConfig(LintConfig{RulesFile: rulesFile, Directory: tmpDir}, &output)
+	if !strings.Contains(output.String(), `Output: linted ["GOOD"]`) {
+		t.Errorf("Expected the rule to run on good.nf, but got:\n%s", output.String())
+	}
+}
@@ -0,0 +1,170 @@
+package nf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+/*
+ruleLoader implements load() for rules files:
+
+	load("common.star", "has_label")        # next to the loading file, then in the search path
+	load("./lib/common.star", "has_label")  # relative to the loading file
+	load("//rules/common.star", "has_label") # relative to the repository root
+
+Every module is executed once and shares the predeclared functions of the
+rules file. A module that loads itself, directly or not, is an error.
+*/
+type ruleLoader struct {
+	root        string
+	searchPath  []string
+	predeclared starlark.StringDict
+	// nil while the module is being loaded
+	cache map[string]*loadEntry
+	// the modules being loaded, to report cycles
+	stack []string
+	// The limits of the threads modules run on, like of the thread of
+	// the rules file. 0 is no limit.
+	maxSteps uint64
+	mu       sync.Mutex
+	// the threads running modules, and why they were cancelled
+	threads   map[*starlark.Thread]bool
+	cancelled string
+}
+
+type loadEntry struct {
+	globals starlark.StringDict
+	err     error
+}
+
+func newRuleLoader(rulesFile string, searchPath []string, predeclared starlark.StringDict) *ruleLoader {
+	return &ruleLoader{
+		root:        repositoryRoot(filepath.Dir(rulesFile)),
+		searchPath:  searchPath,
+		predeclared: predeclared,
+		cache:       map[string]*loadEntry{rulesFile: nil},
+		stack:       []string{rulesFile},
+		threads:     make(map[*starlark.Thread]bool),
+	}
+}
+
+// cancel stops the modules being loaded and the ones loaded after, like
+// Thread.Cancel does for the thread of the rules file
+func (l *ruleLoader) cancel(reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cancelled = reason
+	for thread := range l.threads {
+		thread.Cancel(reason)
+	}
+}
+
+// repositoryRoot returns the directory of the git repository dir is in,
+// or dir if it isn't in one.
+func repositoryRoot(dir string) string {
+	for current := dir; ; {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return dir
+		}
+		current = parent
+	}
+}
+
+func (l *ruleLoader) resolve(dir, module string) (string, error) {
+	var candidates []string
+	switch {
+	case strings.HasPrefix(module, "//"):
+		candidates = []string{filepath.Join(l.root, module[2:])}
+	case filepath.IsAbs(module):
+		candidates = []string{module}
+	case strings.HasPrefix(module, "./") || strings.HasPrefix(module, "../"):
+		candidates = []string{filepath.Join(dir, module)}
+	default:
+		candidates = []string{filepath.Join(dir, module)}
+		for _, searchDir := range l.searchPath {
+			candidates = append(candidates, filepath.Join(searchDir, module))
+		}
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("module not found, looked for %s", strings.Join(candidates, ", "))
+}
+
+// loadFunc returns the load function of the thread running the module at from
+func (l *ruleLoader) loadFunc(from string) func(*starlark.Thread, string) (starlark.StringDict, error) {
+	return func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+		path, err := l.resolve(filepath.Dir(from), module)
+		if err != nil {
+			return nil, err
+		}
+		if entry, ok := l.cache[path]; ok {
+			if entry == nil {
+				return nil, fmt.Errorf("cycle in load graph: %s", l.cycle(path))
+			}
+			return entry.globals, entry.err
+		}
+
+		l.cache[path] = nil
+		l.stack = append(l.stack, path)
+		globals, err := l.exec(path)
+		l.stack = l.stack[:len(l.stack)-1]
+		l.cache[path] = &loadEntry{globals, err}
+		return globals, err
+	}
+}
+
+// files returns the rules file and the modules it loaded
+func (l *ruleLoader) files() []string {
+	files := make([]string, 0, len(l.cache))
+	for path := range l.cache {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func (l *ruleLoader) cycle(path string) string {
+	for i, loading := range l.stack {
+		if loading == path {
+			return strings.Join(append(l.stack[i:len(l.stack):len(l.stack)], path), " -> ")
+		}
+	}
+	return path
+}
+
+func (l *ruleLoader) exec(path string) (starlark.StringDict, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	thread := &starlark.Thread{Name: "load " + path, Load: l.loadFunc(path)}
+	if l.maxSteps > 0 {
+		thread.SetMaxExecutionSteps(l.maxSteps)
+	}
+	l.mu.Lock()
+	if l.cancelled != "" {
+		thread.Cancel(l.cancelled)
+	}
+	l.threads[thread] = true
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		delete(l.threads, thread)
+		l.mu.Unlock()
+	}()
+	return starlark.ExecFileOptions(&syntax.FileOptions{}, thread, path, content, l.predeclared)
+}
@@ -0,0 +1,140 @@
+package nf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+func writeFiles(t *testing.T, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal("Failed to create directory:", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal("Failed to write file:", err)
+		}
+	}
+}
+
+// execRules runs a rules file the way RunLintWithConfig does
+func execRules(t *testing.T, rulesFile string, searchPath []string) (starlark.StringDict, error) {
+	t.Helper()
+	content, err := os.ReadFile(rulesFile)
+	if err != nil {
+		t.Fatal("Failed to read rules file:", err)
+	}
+	predeclared := starlark.StringDict{"edit": starlark.NewBuiltin("edit", editFunc)}
+	thread := &starlark.Thread{Load: newRuleLoader(rulesFile, searchPath, predeclared).loadFunc(rulesFile)}
+	return starlark.ExecFileOptions(&syntax.FileOptions{}, thread, rulesFile, content, predeclared)
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	rulesFile := filepath.Join(root, "rules", "rules.py")
+	writeFiles(t, map[string]string{
+		filepath.Join(root, ".git", "HEAD"): "ref: refs/heads/main\n",
+		rulesFile: `
+load("labels.star", "has_label")
+load("./lib/names.star", "upper")
+load("//shared/common.star", "common")
+load("paths.star", "searched")
+result = [has_label, upper, common, searched]
+`,
+		filepath.Join(root, "rules", "labels.star"):       "load(\"//shared/common.star\", \"common\")\nhas_label = common + \"-label\"\n",
+		filepath.Join(root, "rules", "lib", "names.star"): "upper = \"NAME\"\n",
+		filepath.Join(root, "shared", "common.star"):      "common = \"common\"\nspan = edit\n",
+		filepath.Join(root, "search", "paths.star"):       "searched = \"searched\"\n",
+	})
+
+	globals, err := execRules(t, rulesFile, []string{filepath.Join(root, "search")})
+	if err != nil {
+		t.Fatal("Failed to run rules file:", err)
+	}
+	if got := globals["result"].String(); got != `["common-label", "NAME", "common", "searched"]` {
+		t.Errorf("Unexpected result %s", got)
+	}
+}
+
+func TestLoadCache(t *testing.T) {
+	root := t.TempDir()
+	rulesFile := filepath.Join(root, "rules.py")
+	writeFiles(t, map[string]string{
+		rulesFile:                          "",
+		filepath.Join(root, "common.star"): "items = []\n",
+	})
+	loader := newRuleLoader(rulesFile, nil, nil)
+	load := loader.loadFunc(rulesFile)
+	first, err := load(nil, "common.star")
+	if err != nil {
+		t.Fatal("Failed to load module:", err)
+	}
+	second, err := load(nil, "./common.star")
+	if err != nil {
+		t.Fatal("Failed to load module:", err)
+	}
+	if first["items"] != second["items"] {
+		t.Error("Expected the module to be executed once")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	root := t.TempDir()
+	rulesFile := filepath.Join(root, "rules.py")
+	writeFiles(t, map[string]string{
+		rulesFile:                     "load(\"a.star\", \"a\")\n",
+		filepath.Join(root, "a.star"): "load(\"b.star\", \"b\")\na = b\n",
+		filepath.Join(root, "b.star"): "load(\"a.star\", \"a\")\nb = a\n",
+	})
+	_, err := execRules(t, rulesFile, nil)
+	want := "cycle in load graph: " + filepath.Join(root, "a.star") + " -> " + filepath.Join(root, "b.star") + " -> " + filepath.Join(root, "a.star")
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected %q, got %v", want, err)
+	}
+
+	writeFiles(t, map[string]string{rulesFile: "load(\"missing.star\", \"m\")\n"})
+	_, err = execRules(t, rulesFile, nil)
+	if err == nil || !strings.Contains(err.Error(), "module not found") {
+		t.Errorf("Expected a missing module error, got %v", err)
+	}
+}
+
+func TestLoadLimits(t *testing.T) {
+	root := t.TempDir()
+	rulesFile := filepath.Join(root, "rules.py")
+	writeFiles(t, map[string]string{
+		rulesFile: "load(\"loop.star\", \"x\")\n",
+		filepath.Join(root, "loop.star"): `
+def loop():
+    for i in range(1000000000):
+        pass
+
+loop()
+x = 1
+`,
+	})
+	run := func(loader *ruleLoader) error {
+		thread := &starlark.Thread{Load: loader.loadFunc(rulesFile)}
+		_, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, rulesFile, []byte("load(\"loop.star\", \"x\")\n"), nil)
+		return err
+	}
+
+	loader := newRuleLoader(rulesFile, nil, nil)
+	loader.maxSteps = 1000
+	if err := run(loader); err == nil || !strings.Contains(err.Error(), "too many steps") {
+		t.Errorf("Expected the loaded module to run out of steps, got %v", err)
+	}
+
+	loader = newRuleLoader(rulesFile, nil, nil)
+	timer := time.AfterFunc(50*time.Millisecond, func() { loader.cancel("timeout") })
+	defer timer.Stop()
+	if err := run(loader); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("Expected the loaded module to be cancelled, got %v", err)
+	}
+}
@@ -16,6 +16,9 @@ type Module struct {
 	DSLVersion int
 	Params     []ParamInfo
 	Workflows  []Workflow
+	Functions  []Function
+	Comments   []parser.Comment
+	AST        *parser.ModuleNode
 }
 
 func (m *Module) ToProto() *pb.Module {
@@ -45,6 +48,13 @@ func (m *Module) ToProto() *pb.Module {
 
 func BuildModule(filePath string) (*Module, error, bool) {
 	ast, err := parser.BuildAST(filePath)
+	return moduleFromAST(filePath, ast, err)
+}
+
+// moduleFromAST builds the module of a file from its AST, or returns the
+// error of building the AST. The bool is set for errors that are likely
+// bugs in RefTrace.
+func moduleFromAST(filePath string, ast *parser.ModuleNode, err error) (*Module, error, bool) {
 	if err != nil {
 		if _, ok := err.(*parser.SyntaxException); ok {
 			return nil, err, false
@@ -103,6 +113,7 @@ func BuildModule(filePath string) (*Module, error, bool) {
 	workflowVisitor := NewWorkflowVisitor()
 	workflowVisitor.VisitBlockStatement(ast.StatementBlock)
 	workflows := workflowVisitor.workflows
+	resolveWorkflowCalls(workflows, processes, includes)
 
 	return &Module{
 		Path:       filePath,
@@ -111,6 +122,9 @@ func BuildModule(filePath string) (*Module, error, bool) {
 		DSLVersion: dslVersion,
 		Params:     params,
 		Workflows:  workflows,
+		Functions:  functionsOf(ast),
+		Comments:   ast.Comments,
+		AST:        ast,
 	}, nil, false
 }
 
@@ -121,9 +135,14 @@ func ConvertToStarlarkModule(m *Module) *StarlarkModule {
 	}
 
 	return &StarlarkModule{
-		Path:      m.Path,
-		Processes: starlarkProcesses,
-		Includes:  m.Includes,
+		Path:       m.Path,
+		Processes:  starlarkProcesses,
+		Includes:   m.Includes,
+		Workflows:  m.Workflows,
+		Params:     m.Params,
+		Functions:  m.Functions,
+		DSLVersion: m.DSLVersion,
+		AST:        m.AST,
 	}
 }
 
@@ -131,9 +150,14 @@ var _ starlark.Value = (*StarlarkModule)(nil)
 var _ starlark.HasAttrs = (*StarlarkModule)(nil)
 
 type StarlarkModule struct {
-	Path      string
-	Processes []*StarlarkProcess
-	Includes  []IncludeStatement
+	Path       string
+	Processes  []*StarlarkProcess
+	Includes   []IncludeStatement
+	Workflows  []Workflow
+	Params     []ParamInfo
+	Functions  []Function
+	DSLVersion int
+	AST        *parser.ModuleNode
 }
 
 func (m *StarlarkModule) String() string {
@@ -170,11 +194,33 @@ func (m *StarlarkModule) Attr(name string) (starlark.Value, error) {
 			includes[i] = inc
 		}
 		return starlark.NewList(includes), nil
+	case "workflows":
+		workflows := make([]starlark.Value, len(m.Workflows))
+		for i, workflow := range m.Workflows {
+			workflows[i] = &StarlarkWorkflow{workflow, m.Path}
+		}
+		return starlark.NewList(workflows), nil
+	case "params":
+		params := make([]starlark.Value, len(m.Params))
+		for i, param := range m.Params {
+			params[i] = &StarlarkParam{param}
+		}
+		return starlark.NewList(params), nil
+	case "functions":
+		functions := make([]starlark.Value, len(m.Functions))
+		for i, function := range m.Functions {
+			functions[i] = &StarlarkFunction{function, m.Path}
+		}
+		return starlark.NewList(functions), nil
+	case "dsl_version":
+		return starlark.MakeInt(m.DSLVersion), nil
+	case "ast":
+		return newStarlarkNode(m.AST), nil
 	default:
 		return nil, starlark.NoSuchAttrError(fmt.Sprintf("module has no attribute %q", name))
 	}
 }
 
 func (m *StarlarkModule) AttrNames() []string {
-	return []string{"path", "processes", "includes"}
+	return []string{"path", "processes", "includes", "workflows", "params", "functions", "dsl_version", "ast"}
 }
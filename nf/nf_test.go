@@ -135,6 +135,31 @@ func TestCountProcesses(t *testing.T) {
 	}
 }
 
+func TestParseDirectoryPartial(t *testing.T) {
+	filePath := filepath.Join(getTestDataDir(), "nf-core")
+	modules, parseErrors, err := ParseDirectory(filePath)
+	if err != nil {
+		t.Fatalf("Failed to process directory: %v", err)
+	}
+	if len(modules) == 0 {
+		t.Error("Expected the modules that parse to be returned")
+	}
+	if len(parseErrors) != 2 {
+		t.Fatalf("Expected 2 parse errors, got %v", parseErrors)
+	}
+	for i, name := range []string{"clipseq", "eager"} {
+		if expected := filepath.Join(getTestDataDir(), "nf-core", name, "main.nf"); parseErrors[i].Path != expected {
+			t.Errorf("Expected a parse error in %s, got %s", expected, parseErrors[i].Path)
+		}
+		if parseErrors[i].LikelyBug {
+			t.Errorf("Expected %s to be an error in the module, not a likely bug", parseErrors[i].Path)
+		}
+		if d := parseErrors[i].Diagnostic(); d.Severity != SeverityError || d.Code != "parse-error" {
+			t.Errorf("Expected a parse-error diagnostic, got %+v", d)
+		}
+	}
+}
+
 func TestCountProcessesAirrflow(t *testing.T) {
 	filePath := filepath.Join(getTestDataDir(), "nf-core", "airrflow")
 	modules, err := ProcessDirectory(filePath)
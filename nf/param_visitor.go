@@ -11,6 +11,9 @@ var _ parser.GroovyCodeVisitor = (*ParamVisitor)(nil)
 type ParamInfo struct {
 	Name       string
 	LineNumber int
+	// Default is the value of the first `params.name = value` assignment,
+	// nil if there is none
+	Default parser.Expression
 }
 
 func (p *ParamInfo) ToProto() *pb.Param {
@@ -22,7 +25,8 @@ func (p *ParamInfo) ToProto() *pb.Param {
 
 type ParamVisitor struct {
 	*BaseVisitor
-	params map[string]int // Use a map to represent a set of strings with line numbers
+	params   map[string]int // Use a map to represent a set of strings with line numbers
+	defaults map[string]parser.Expression
 }
 
 // NewParamVisitor creates a new ParamVisitor
@@ -30,6 +34,7 @@ func NewParamVisitor() *ParamVisitor {
 	v := &ParamVisitor{
 		BaseVisitor: NewBaseVisitor(),
 		params:      make(map[string]int),
+		defaults:    make(map[string]parser.Expression),
 	}
 	v.VisitPropertyExpressionHook = func(expression *parser.PropertyExpression) {
 		varExpr, isVarExpr := expression.GetObjectExpression().(*parser.VariableExpression)
@@ -45,13 +50,36 @@ func NewParamVisitor() *ParamVisitor {
 		v.VisitExpression(expression.GetObjectExpression())
 		v.VisitExpression(expression.GetProperty())
 	}
+	v.VisitBinaryExpressionHook = func(expression *parser.BinaryExpression) {
+		if name, ok := paramName(expression.GetLeftExpression()); ok && expression.GetOperation().GetText() == "=" {
+			if _, exists := v.defaults[name]; !exists {
+				v.defaults[name] = expression.GetRightExpression()
+			}
+		}
+		v.VisitExpression(expression.GetLeftExpression())
+		v.VisitExpression(expression.GetRightExpression())
+	}
 	return v
 }
 
+// paramName returns the name of a params.name expression
+func paramName(expression parser.Expression) (string, bool) {
+	propExpr, ok := expression.(*parser.PropertyExpression)
+	if !ok {
+		return "", false
+	}
+	varExpr, isVarExpr := propExpr.GetObjectExpression().(*parser.VariableExpression)
+	constExpr, isConstExpr := propExpr.GetProperty().(*parser.ConstantExpression)
+	if !isVarExpr || !isConstExpr || varExpr.GetName() != "params" {
+		return "", false
+	}
+	return constExpr.GetText(), true
+}
+
 func (v *ParamVisitor) GetSortedParams() []ParamInfo {
 	sortedParams := make([]ParamInfo, 0, len(v.params))
 	for param, lineNumber := range v.params {
-		sortedParams = append(sortedParams, ParamInfo{Name: param, LineNumber: lineNumber})
+		sortedParams = append(sortedParams, ParamInfo{Name: param, LineNumber: lineNumber, Default: v.defaults[param]})
 	}
 	sort.Slice(sortedParams, func(i, j int) bool {
 		return sortedParams[i].LineNumber < sortedParams[j].LineNumber
@@ -27,6 +27,7 @@ type ProcessBodyVisitor struct {
 	inputs       []inputs.Input
 	outputs      []outputs.Output
 	directives   []directives.Directive
+	nodes        map[directives.Directive]*parser.MethodCallExpression
 	errors       []error
 }
 
@@ -148,7 +149,9 @@ func makeDirective(statement parser.Statement) (directives.Directive, error) {
 		statement, statement.GetLineNumber())
 }
 
-func makeDirectives(statements []parser.Statement) ([]directives.Directive, []error) {
+// makeDirectives also returns the method call each directive was made from
+func makeDirectives(statements []parser.Statement) ([]directives.Directive, map[directives.Directive]*parser.MethodCallExpression, []error) {
+	nodes := make(map[directives.Directive]*parser.MethodCallExpression)
 	var directives []directives.Directive
 	var errors []error
 
@@ -160,10 +163,15 @@ func makeDirectives(statements []parser.Statement) ([]directives.Directive, []er
 		}
 		if directive != nil {
 			directives = append(directives, directive)
+			if exprStmt, ok := statement.(*parser.ExpressionStatement); ok {
+				if mce, ok := exprStmt.GetExpression().(*parser.MethodCallExpression); ok {
+					nodes[directive] = mce
+				}
+			}
 		}
 	}
 
-	return directives, errors
+	return directives, nodes, errors
 }
 
 func makeInput(statement parser.Statement) (inputs.Input, error) {
@@ -258,8 +266,9 @@ func (v *ProcessBodyVisitor) VisitBlockStatement(block *parser.BlockStatement) {
 	possibleOutputs := findOutputs(stmts)
 	v.outputs = makeOutputs(possibleOutputs)
 	possibleDirectives := findPossibleDirectives(stmts)
-	directives, errors := makeDirectives(possibleDirectives)
+	directives, nodes, errors := makeDirectives(possibleDirectives)
 	v.directives = directives
+	v.nodes = nodes
 	if len(errors) > 0 {
 		v.errors = append(v.errors, errors...)
 	}
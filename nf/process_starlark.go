@@ -6,6 +6,7 @@ import (
 	"reft-go/nf/directives"
 	"reft-go/nf/inputs"
 	"reft-go/nf/outputs"
+	"reft-go/parser"
 
 	"go.starlark.net/starlark"
 )
@@ -13,9 +14,11 @@ import (
 func ConvertToStarlarkProcess(p Process) *StarlarkProcess {
 	sp := &StarlarkProcess{
 		Name:       p.Name,
-		Directives: &StarlarkProcessDirectives{},
+		Line:       p.Line(),
+		Directives: &StarlarkProcessDirectives{nodes: p.nodes},
 		Inputs:     &StarlarkProcessInputs{},
 		Outputs:    &StarlarkProcessOutputs{},
+		Body:       p.Closure,
 	}
 
 	// Handle inputs
@@ -151,13 +154,15 @@ var _ starlark.HasAttrs = (*StarlarkProcess)(nil)
 
 type StarlarkProcess struct {
 	Name       string
+	Line       int
 	Directives *StarlarkProcessDirectives
 	Inputs     *StarlarkProcessInputs
 	Outputs    *StarlarkProcessOutputs
+	Body       *parser.ClosureExpression
 }
 
 func (p *StarlarkProcess) AttrNames() []string {
-	return []string{"name", "directives", "inputs", "outputs"}
+	return []string{"name", "line", "directives", "inputs", "outputs", "body"}
 }
 
 var _ starlark.Value = (*StarlarkProcessInputs)(nil)
@@ -345,6 +350,7 @@ type StarlarkProcessDirectives struct {
 	Time             []*directives.TimeDirective
 	Dynamic          []*directives.DynamicDirective
 	Unknown          []*directives.UnknownDirective
+	nodes            map[directives.Directive]*parser.MethodCallExpression
 }
 
 func (p *StarlarkProcess) String() string {
@@ -372,12 +378,16 @@ func (p *StarlarkProcess) Attr(name string) (starlark.Value, error) {
 	switch name {
 	case "name":
 		return starlark.String(p.Name), nil
+	case "line":
+		return starlark.MakeInt(p.Line), nil
 	case "directives":
 		return &StarlarkProcessDirectivesWrapper{p.Directives}, nil
 	case "inputs":
 		return p.Inputs, nil
 	case "outputs":
 		return p.Outputs, nil
+	case "body":
+		return newStarlarkNode(p.Body), nil
 	default:
 		return nil, fmt.Errorf("process has no attribute %q", name)
 	}
@@ -410,106 +420,145 @@ func (w *StarlarkProcessDirectivesWrapper) Hash() (uint32, error) {
 	return 0, fmt.Errorf("unhashable type: process_directives")
 }
 
-func starlarkListFromDirectives(directives interface{}) *starlark.List {
-	v := reflect.ValueOf(directives)
+func (w *StarlarkProcessDirectivesWrapper) listFromDirectives(items interface{}) *starlark.List {
+	v := reflect.ValueOf(items)
 	if v.Kind() != reflect.Slice {
 		return starlark.NewList(nil)
 	}
 
 	elements := make([]starlark.Value, v.Len())
 	for i := 0; i < v.Len(); i++ {
-		elements[i] = v.Index(i).Interface().(starlark.Value)
+		directive := v.Index(i).Interface().(directives.Directive)
+		elements[i] = &starlarkDirectiveWithLine{directive, w.nodes[directive]}
 	}
 
 	return starlark.NewList(elements)
 }
 
+var _ starlark.HasAttrs = (*starlarkDirectiveWithLine)(nil)
+
+// starlarkDirectiveWithLine adds the position of a directive to it,
+// so rules can report where it is and attach fixes.
+// span covers the whole directive and name_span its name.
+type starlarkDirectiveWithLine struct {
+	directives.Directive
+	node *parser.MethodCallExpression
+}
+
+func (d *starlarkDirectiveWithLine) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "line":
+		return starlark.MakeInt(d.Line()), nil
+	case "span":
+		if d.node == nil {
+			return starlark.None, nil
+		}
+		return &StarlarkSpan{SpanOf(d.node)}, nil
+	case "name_span":
+		if d.node == nil {
+			return starlark.None, nil
+		}
+		return &StarlarkSpan{NameSpan(d.node)}, nil
+	}
+	if hasAttrs, ok := d.Directive.(starlark.HasAttrs); ok {
+		return hasAttrs.Attr(name)
+	}
+	return nil, nil
+}
+
+func (d *starlarkDirectiveWithLine) AttrNames() []string {
+	if hasAttrs, ok := d.Directive.(starlark.HasAttrs); ok {
+		return append([]string{"line", "span", "name_span"}, hasAttrs.AttrNames()...)
+	}
+	return []string{"line", "span", "name_span"}
+}
+
 func (w *StarlarkProcessDirectivesWrapper) Attr(name string) (starlark.Value, error) {
 	switch name {
 	case "accelerator":
-		return starlarkListFromDirectives(w.Accelerator), nil
+		return w.listFromDirectives(w.Accelerator), nil
 	case "after_script":
-		return starlarkListFromDirectives(w.AfterScript), nil
+		return w.listFromDirectives(w.AfterScript), nil
 	case "arch":
-		return starlarkListFromDirectives(w.Arch), nil
+		return w.listFromDirectives(w.Arch), nil
 	case "array":
-		return starlarkListFromDirectives(w.Array), nil
+		return w.listFromDirectives(w.Array), nil
 	case "before_script":
-		return starlarkListFromDirectives(w.BeforeScript), nil
+		return w.listFromDirectives(w.BeforeScript), nil
 	case "cache":
-		return starlarkListFromDirectives(w.Cache), nil
+		return w.listFromDirectives(w.Cache), nil
 	case "cluster_options":
-		return starlarkListFromDirectives(w.ClusterOptions), nil
+		return w.listFromDirectives(w.ClusterOptions), nil
 	case "conda":
-		return starlarkListFromDirectives(w.Conda), nil
+		return w.listFromDirectives(w.Conda), nil
 	case "container":
-		return starlarkListFromDirectives(w.Container), nil
+		return w.listFromDirectives(w.Container), nil
 	case "container_options":
-		return starlarkListFromDirectives(w.ContainerOptions), nil
+		return w.listFromDirectives(w.ContainerOptions), nil
 	case "cpus":
-		return starlarkListFromDirectives(w.Cpus), nil
+		return w.listFromDirectives(w.Cpus), nil
 	case "debug":
-		return starlarkListFromDirectives(w.Debug), nil
+		return w.listFromDirectives(w.Debug), nil
 	case "disk":
-		return starlarkListFromDirectives(w.Disk), nil
+		return w.listFromDirectives(w.Disk), nil
 	case "echo":
-		return starlarkListFromDirectives(w.Echo), nil
+		return w.listFromDirectives(w.Echo), nil
 	case "error_strategy":
-		return starlarkListFromDirectives(w.ErrorStrategy), nil
+		return w.listFromDirectives(w.ErrorStrategy), nil
 	case "executor":
-		return starlarkListFromDirectives(w.Executor), nil
+		return w.listFromDirectives(w.Executor), nil
 	case "ext":
-		return starlarkListFromDirectives(w.Ext), nil
+		return w.listFromDirectives(w.Ext), nil
 	case "fair":
-		return starlarkListFromDirectives(w.Fair), nil
+		return w.listFromDirectives(w.Fair), nil
 	case "label":
-		return starlarkListFromDirectives(w.Label), nil
+		return w.listFromDirectives(w.Label), nil
 	case "machine_type":
-		return starlarkListFromDirectives(w.MachineType), nil
+		return w.listFromDirectives(w.MachineType), nil
 	case "max_submit_await":
-		return starlarkListFromDirectives(w.MaxSubmitAwait), nil
+		return w.listFromDirectives(w.MaxSubmitAwait), nil
 	case "max_errors":
-		return starlarkListFromDirectives(w.MaxErrors), nil
+		return w.listFromDirectives(w.MaxErrors), nil
 	case "max_forks":
-		return starlarkListFromDirectives(w.MaxForks), nil
+		return w.listFromDirectives(w.MaxForks), nil
 	case "max_retries":
-		return starlarkListFromDirectives(w.MaxRetries), nil
+		return w.listFromDirectives(w.MaxRetries), nil
 	case "memory":
-		return starlarkListFromDirectives(w.Memory), nil
+		return w.listFromDirectives(w.Memory), nil
 	case "module":
-		return starlarkListFromDirectives(w.Module), nil
+		return w.listFromDirectives(w.Module), nil
 	case "penv":
-		return starlarkListFromDirectives(w.Penv), nil
+		return w.listFromDirectives(w.Penv), nil
 	case "pod":
-		return starlarkListFromDirectives(w.Pod), nil
+		return w.listFromDirectives(w.Pod), nil
 	case "publish_dir":
-		return starlarkListFromDirectives(w.PublishDir), nil
+		return w.listFromDirectives(w.PublishDir), nil
 	case "queue":
-		return starlarkListFromDirectives(w.Queue), nil
+		return w.listFromDirectives(w.Queue), nil
 	case "resource_labels":
-		return starlarkListFromDirectives(w.ResourceLabels), nil
+		return w.listFromDirectives(w.ResourceLabels), nil
 	case "resource_limits":
-		return starlarkListFromDirectives(w.ResourceLimits), nil
+		return w.listFromDirectives(w.ResourceLimits), nil
 	case "scratch":
-		return starlarkListFromDirectives(w.Scratch), nil
+		return w.listFromDirectives(w.Scratch), nil
 	case "shell":
-		return starlarkListFromDirectives(w.Shell), nil
+		return w.listFromDirectives(w.Shell), nil
 	case "spack":
-		return starlarkListFromDirectives(w.Spack), nil
+		return w.listFromDirectives(w.Spack), nil
 	case "stage_in_mode":
-		return starlarkListFromDirectives(w.StageInMode), nil
+		return w.listFromDirectives(w.StageInMode), nil
 	case "stage_out_mode":
-		return starlarkListFromDirectives(w.StageOutMode), nil
+		return w.listFromDirectives(w.StageOutMode), nil
 	case "store_dir":
-		return starlarkListFromDirectives(w.StoreDir), nil
+		return w.listFromDirectives(w.StoreDir), nil
 	case "tag":
-		return starlarkListFromDirectives(w.Tag), nil
+		return w.listFromDirectives(w.Tag), nil
 	case "time":
-		return starlarkListFromDirectives(w.Time), nil
+		return w.listFromDirectives(w.Time), nil
 	case "dynamic":
-		return starlarkListFromDirectives(w.Dynamic), nil
+		return w.listFromDirectives(w.Dynamic), nil
 	case "unknown":
-		return starlarkListFromDirectives(w.Unknown), nil
+		return w.listFromDirectives(w.Unknown), nil
 	default:
 		return nil, fmt.Errorf("directives has no attribute %q", name)
 	}
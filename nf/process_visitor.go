@@ -33,12 +33,18 @@ type Process struct {
 	Closure    *parser.ClosureExpression
 	Errors     []error
 	line       int
+	nodes      map[directives.Directive]*parser.MethodCallExpression
 }
 
 func (p *Process) Line() int {
 	return p.line
 }
 
+// DirectiveNode returns the method call a directive was parsed from, or nil.
+func (p *Process) DirectiveNode(directive directives.Directive) *parser.MethodCallExpression {
+	return p.nodes[directive]
+}
+
 type ProcessVisitor struct {
 	processes []Process
 }
@@ -184,6 +190,7 @@ func makeProcess(name string, closure *parser.ClosureExpression) Process {
 		Closure:    closure,
 		Errors:     visitor.errors,
 		line:       closure.GetLineNumber(),
+		nodes:      visitor.nodes,
 	}
 }
 
@@ -0,0 +1,64 @@
+package nf
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// RuleProfile is how long a rule ran, summed over its calls.
+type RuleProfile struct {
+	Rule     string
+	Calls    int
+	Duration time.Duration
+	// Steps is 0 for the built-in rules, which aren't written in Starlark
+	Steps uint64
+	// Slowest is the path the slowest call was on
+	Slowest         string
+	SlowestDuration time.Duration
+}
+
+type ruleProfiles map[string]*RuleProfile
+
+func (p ruleProfiles) add(rule, path string, duration time.Duration, steps uint64) {
+	profile, ok := p[rule]
+	if !ok {
+		profile = &RuleProfile{Rule: rule}
+		p[rule] = profile
+	}
+	profile.Calls++
+	profile.Duration += duration
+	profile.Steps += steps
+	if duration >= profile.SlowestDuration {
+		profile.Slowest, profile.SlowestDuration = path, duration
+	}
+}
+
+// sorted returns the profiles, slowest rule first
+func (p ruleProfiles) sorted() []RuleProfile {
+	profiles := make([]RuleProfile, 0, len(p))
+	for _, profile := range p {
+		profiles = append(profiles, *profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].Duration != profiles[j].Duration {
+			return profiles[i].Duration > profiles[j].Duration
+		}
+		return profiles[i].Rule < profiles[j].Rule
+	})
+	return profiles
+}
+
+// PrintRuleProfile writes the profiles as a table.
+func PrintRuleProfile(output io.Writer, profiles []RuleProfile) {
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Rule\tCalls\tTime\tSteps\tSlowest")
+	for _, profile := range profiles {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%s (%s)\n",
+			profile.Rule, profile.Calls, profile.Duration.Round(time.Microsecond), profile.Steps,
+			profile.Slowest, profile.SlowestDuration.Round(time.Microsecond))
+	}
+	w.Flush()
+}
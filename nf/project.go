@@ -0,0 +1,269 @@
+package nf
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"go.starlark.net/starlark"
+)
+
+// ProjectInclude is an include statement with the module it refers to.
+type ProjectInclude struct {
+	// ModulePath is the module the include statement is in
+	ModulePath string
+	Include    IncludeStatement
+	// Path is the canonical path of the included module
+	Path     string
+	Resolved bool
+}
+
+// Project is a whole pipeline, as seen by project_rule_* functions.
+type Project struct {
+	Directory string
+	// Modules sorted by path
+	Modules  []*Module
+	Includes []ProjectInclude
+	Configs  []StarlarkConfig
+	// EntryModule and EntryWorkflow are nil if there is no entry workflow
+	EntryModule   *Module
+	EntryWorkflow *Workflow
+}
+
+func NewProject(dir string, modules []*Module, configs []StarlarkConfig) *Project {
+	sorted := append([]*Module(nil), modules...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	paths := make(map[string]bool)
+	for _, module := range sorted {
+		paths[module.Path] = true
+	}
+
+	project := &Project{Directory: dir, Modules: sorted, Configs: configs}
+	for _, module := range sorted {
+		for _, include := range module.Includes {
+			path := canonicalize(sorted, module.Path, include.ModulePath)
+			project.Includes = append(project.Includes, ProjectInclude{
+				ModulePath: module.Path,
+				Include:    include,
+				Path:       path,
+				Resolved:   paths[path],
+			})
+		}
+	}
+	project.EntryModule, project.EntryWorkflow = entryWorkflow(dir, sorted)
+	return project
+}
+
+/*
+entryWorkflow finds the unnamed workflow Nextflow runs.
+
+It is looked for in main.nf at the root of the pipeline first, then in
+the other modules in path order. When linting a single file, that file
+is the root.
+*/
+func entryWorkflow(dir string, modules []*Module) (*Module, *Workflow) {
+	find := func(module *Module) *Workflow {
+		for i := range module.Workflows {
+			if module.Workflows[i].Name == "" {
+				return &module.Workflows[i]
+			}
+		}
+		return nil
+	}
+	for _, module := range modules {
+		if module.Path == dir || module.Path == filepath.Join(dir, "main.nf") {
+			if workflow := find(module); workflow != nil {
+				return module, workflow
+			}
+		}
+	}
+	for _, module := range modules {
+		if workflow := find(module); workflow != nil {
+			return module, workflow
+		}
+	}
+	return nil, nil
+}
+
+var _ starlark.Value = (*StarlarkProject)(nil)
+var _ starlark.HasAttrs = (*StarlarkProject)(nil)
+
+type StarlarkProject struct {
+	*Project
+}
+
+func (p *StarlarkProject) String() string {
+	return fmt.Sprintf("Pipeline(%s)", p.Directory)
+}
+
+func (p *StarlarkProject) Type() string {
+	return "pipeline"
+}
+
+func (p *StarlarkProject) Freeze() {}
+
+func (p *StarlarkProject) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (p *StarlarkProject) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: pipeline")
+}
+
+func (p *StarlarkProject) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "directory":
+		return starlark.String(p.Directory), nil
+	case "modules":
+		modules := make([]starlark.Value, len(p.Modules))
+		for i, module := range p.Modules {
+			modules[i] = ConvertToStarlarkModule(module)
+		}
+		return starlark.NewList(modules), nil
+	case "includes":
+		includes := make([]starlark.Value, len(p.Includes))
+		for i := range p.Includes {
+			includes[i] = &StarlarkProjectInclude{p.Includes[i]}
+		}
+		return starlark.NewList(includes), nil
+	case "configs":
+		configs := make([]starlark.Value, len(p.Configs))
+		for i, config := range p.Configs {
+			configs[i] = config.Value
+		}
+		return starlark.NewList(configs), nil
+	case "entry_workflow":
+		if p.EntryWorkflow == nil {
+			return starlark.None, nil
+		}
+		return &StarlarkWorkflow{*p.EntryWorkflow, p.EntryModule.Path}, nil
+	default:
+		return nil, starlark.NoSuchAttrError(fmt.Sprintf("pipeline has no attribute %q", name))
+	}
+}
+
+func (p *StarlarkProject) AttrNames() []string {
+	return []string{"directory", "modules", "includes", "configs", "entry_workflow"}
+}
+
+var _ starlark.Value = (*StarlarkProjectInclude)(nil)
+var _ starlark.HasAttrs = (*StarlarkProjectInclude)(nil)
+
+type StarlarkProjectInclude struct {
+	ProjectInclude
+}
+
+func (i *StarlarkProjectInclude) String() string {
+	return fmt.Sprintf("Include(%s -> %s)", i.ModulePath, i.Path)
+}
+
+func (i *StarlarkProjectInclude) Type() string {
+	return "resolved_include"
+}
+
+func (i *StarlarkProjectInclude) Freeze() {}
+
+func (i *StarlarkProjectInclude) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (i *StarlarkProjectInclude) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: resolved_include")
+}
+
+func (i *StarlarkProjectInclude) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "module_path":
+		return starlark.String(i.ModulePath), nil
+	case "line":
+		return starlark.MakeInt(i.Include.LineNumber), nil
+	case "source":
+		return starlark.String(i.Include.ModulePath), nil
+	case "path":
+		return starlark.String(i.Path), nil
+	case "resolved":
+		return starlark.Bool(i.Resolved), nil
+	case "items":
+		items := make([]starlark.Value, len(i.Include.Items))
+		for j := range i.Include.Items {
+			items[j] = &i.Include.Items[j]
+		}
+		return starlark.NewList(items), nil
+	default:
+		return nil, starlark.NoSuchAttrError(fmt.Sprintf("resolved_include has no attribute %q", name))
+	}
+}
+
+func (i *StarlarkProjectInclude) AttrNames() []string {
+	return []string{"module_path", "line", "source", "path", "resolved", "items"}
+}
+
+var _ starlark.Value = (*StarlarkWorkflow)(nil)
+var _ starlark.HasAttrs = (*StarlarkWorkflow)(nil)
+
+type StarlarkWorkflow struct {
+	Workflow
+	ModulePath string
+}
+
+func (w *StarlarkWorkflow) String() string {
+	if w.Name == "" {
+		return fmt.Sprintf("Workflow(%s)", w.ModulePath)
+	}
+	return fmt.Sprintf("Workflow(%s)", w.Name)
+}
+
+func (w *StarlarkWorkflow) Type() string {
+	return "workflow"
+}
+
+func (w *StarlarkWorkflow) Freeze() {}
+
+func (w *StarlarkWorkflow) Truth() starlark.Bool {
+	return starlark.Bool(true)
+}
+
+func (w *StarlarkWorkflow) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: workflow")
+}
+
+func (w *StarlarkWorkflow) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "name":
+		return starlark.String(w.Name), nil
+	case "module_path":
+		return starlark.String(w.ModulePath), nil
+	case "line":
+		if w.Closure == nil {
+			return starlark.MakeInt(0), nil
+		}
+		return starlark.MakeInt(w.Closure.GetLineNumber()), nil
+	case "takes":
+		return stringList(w.Takes), nil
+	case "emits":
+		return stringList(w.Emits), nil
+	case "calls":
+		calls := make([]starlark.Value, len(w.Calls))
+		for i, call := range w.Calls {
+			calls[i] = &StarlarkWorkflowCall{call}
+		}
+		return starlark.NewList(calls), nil
+	case "body":
+		return newStarlarkNode(w.Closure), nil
+	default:
+		return nil, starlark.NoSuchAttrError(fmt.Sprintf("workflow has no attribute %q", name))
+	}
+}
+
+func (w *StarlarkWorkflow) AttrNames() []string {
+	return []string{"name", "module_path", "line", "takes", "emits", "calls", "body"}
+}
+
+func stringList(items []string) *starlark.List {
+	values := make([]starlark.Value, len(items))
+	for i, item := range items {
+		values[i] = starlark.String(item)
+	}
+	return starlark.NewList(values)
+}
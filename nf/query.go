@@ -0,0 +1,412 @@
+package nf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+QueryNode is an element of the pipeline model that queries select:
+
+	module
+	  process > directive, input, output
+	  workflow > call
+	  include, param, function
+
+Every node has the attributes name, file and line. Attributes can have
+several values, like the labels of a process. Inputs and outputs don't
+record a line, they have the line of their process.
+*/
+type QueryNode struct {
+	Kind     string              `json:"kind"`
+	Name     string              `json:"name,omitempty"`
+	Path     string              `json:"path"`
+	Line     int                 `json:"line,omitempty"`
+	Attrs    map[string][]string `json:"attrs,omitempty"`
+	Children []*QueryNode        `json:"-"`
+}
+
+var queryKinds = []string{"module", "process", "directive", "input", "output", "workflow", "call", "include", "param", "function"}
+
+func (n *QueryNode) addAttr(name string, values ...string) {
+	if n.Attrs == nil {
+		n.Attrs = make(map[string][]string)
+	}
+	n.Attrs[name] = append(n.Attrs[name], values...)
+}
+
+func (n *QueryNode) attr(name string) ([]string, bool) {
+	switch name {
+	case "name":
+		return []string{n.Name}, n.Name != ""
+	case "file":
+		return []string{n.Path}, true
+	case "line":
+		return []string{strconv.Itoa(n.Line)}, n.Line > 0
+	}
+	values, ok := n.Attrs[name]
+	return values, ok
+}
+
+/*
+Query selects nodes of the pipeline model with selectors like CSS ones:
+
+	process[label="process_high"] > directive[name=container]
+	workflow call[target=FASTQC]
+	input[type=path], output[type=path]
+
+A selector is a kind, or * for any, followed by attribute conditions.
+A space selects descendants and > selects children. The conditions are
+
+	[attr]        the node has the attribute
+	[attr=v]      one of the values is v
+	[attr!=v]     none of the values is v
+	[attr^=v]     one of the values starts with v
+	[attr$=v]     one of the values ends with v
+	[attr*=v]     one of the values contains v
+	[attr~=re]    one of the values matches the regular expression
+*/
+type Query struct {
+	selectors []querySelector
+}
+
+// querySelector is a list of compound selectors, each related to the one
+// before it by its combinator
+type querySelector []queryCompound
+
+type queryCompound struct {
+	// ' ' for descendants and '>' for children, 0 for the first
+	combinator byte
+	// "" for any kind
+	kind  string
+	attrs []queryAttr
+}
+
+type queryAttr struct {
+	name  string
+	op    string
+	value string
+	re    *regexp.Regexp
+}
+
+func (a queryAttr) matches(node *QueryNode) bool {
+	values, ok := node.attr(a.name)
+	if a.op == "" {
+		return ok
+	}
+	if a.op == "!=" {
+		for _, value := range values {
+			if value == a.value {
+				return false
+			}
+		}
+		return true
+	}
+	for _, value := range values {
+		var match bool
+		switch a.op {
+		case "=":
+			match = value == a.value
+		case "^=":
+			match = strings.HasPrefix(value, a.value)
+		case "$=":
+			match = strings.HasSuffix(value, a.value)
+		case "*=":
+			match = strings.Contains(value, a.value)
+		case "~=":
+			match = a.re.MatchString(value)
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func (c queryCompound) matches(node *QueryNode) bool {
+	if c.kind != "" && c.kind != node.Kind {
+		return false
+	}
+	for _, attr := range c.attrs {
+		if !attr.matches(node) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether node matches the selector up to compound i,
+// given its ancestors, closest last
+func (s querySelector) matches(node *QueryNode, ancestors []*QueryNode, i int) bool {
+	if !s[i].matches(node) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	if s[i].combinator == '>' {
+		last := len(ancestors) - 1
+		return last >= 0 && s.matches(ancestors[last], ancestors[:last], i-1)
+	}
+	for j := len(ancestors) - 1; j >= 0; j-- {
+		if s.matches(ancestors[j], ancestors[:j], i-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the nodes under roots, the roots included, that match any
+// selector of the query, in the order of the tree.
+func (q *Query) Select(roots []*QueryNode) []*QueryNode {
+	var matches []*QueryNode
+	var visit func(node *QueryNode, ancestors []*QueryNode)
+	visit = func(node *QueryNode, ancestors []*QueryNode) {
+		for _, selector := range q.selectors {
+			if selector.matches(node, ancestors, len(selector)-1) {
+				matches = append(matches, node)
+				break
+			}
+		}
+		ancestors = append(ancestors, node)
+		for _, child := range node.Children {
+			visit(child, ancestors[:len(ancestors):len(ancestors)])
+		}
+	}
+	for _, root := range roots {
+		visit(root, nil)
+	}
+	return matches
+}
+
+// ParseQuery parses a list of selectors separated by commas.
+func ParseQuery(expr string) (*Query, error) {
+	p := &queryParser{input: []rune(expr)}
+	query := &Query{}
+	for {
+		selector, err := p.selector()
+		if err != nil {
+			return nil, fmt.Errorf("invalid query at offset %d: %v", p.pos, err)
+		}
+		query.selectors = append(query.selectors, selector)
+		p.skipSpace()
+		if p.done() {
+			return query, nil
+		}
+		if p.peek() != ',' {
+			return nil, fmt.Errorf("invalid query at offset %d: unexpected %q", p.pos, p.peek())
+		}
+		p.pos++
+	}
+}
+
+type queryParser struct {
+	input []rune
+	pos   int
+}
+
+func (p *queryParser) done() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *queryParser) peek() rune {
+	if p.done() {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *queryParser) skipSpace() bool {
+	start := p.pos
+	for !p.done() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *queryParser) selector() (querySelector, error) {
+	p.skipSpace()
+	var selector querySelector
+	var combinator byte
+	for {
+		compound, err := p.compound()
+		if err != nil {
+			return nil, err
+		}
+		compound.combinator = combinator
+		selector = append(selector, compound)
+
+		space := p.skipSpace()
+		switch {
+		case p.peek() == '>':
+			p.pos++
+			p.skipSpace()
+			combinator = '>'
+		case p.done() || p.peek() == ',':
+			return selector, nil
+		case space:
+			combinator = ' '
+		default:
+			return nil, fmt.Errorf("unexpected %q", p.peek())
+		}
+	}
+}
+
+func (p *queryParser) compound() (queryCompound, error) {
+	var compound queryCompound
+	if p.peek() == '*' {
+		p.pos++
+	} else if kind := p.ident(); kind != "" {
+		if !isQueryKind(kind) {
+			return compound, fmt.Errorf("unknown kind %q, expected one of %s", kind, strings.Join(queryKinds, ", "))
+		}
+		compound.kind = kind
+	} else if p.peek() != '[' {
+		if p.done() {
+			return compound, fmt.Errorf("expected a selector")
+		}
+		return compound, fmt.Errorf("unexpected %q", p.peek())
+	}
+	for p.peek() == '[' {
+		p.pos++
+		attr, err := p.attr()
+		if err != nil {
+			return compound, err
+		}
+		compound.attrs = append(compound.attrs, attr)
+	}
+	return compound, nil
+}
+
+func isQueryKind(kind string) bool {
+	for _, k := range queryKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
+}
+
+func (p *queryParser) ident() string {
+	start := p.pos
+	for !p.done() && isIdentRune(p.peek()) {
+		p.pos++
+	}
+	return string(p.input[start:p.pos])
+}
+
+// attr parses an attribute condition after its [
+func (p *queryParser) attr() (queryAttr, error) {
+	var attr queryAttr
+	p.skipSpace()
+	if attr.name = p.ident(); attr.name == "" {
+		return attr, fmt.Errorf("expected an attribute name")
+	}
+	p.skipSpace()
+	if p.peek() == ']' {
+		p.pos++
+		return attr, nil
+	}
+	for _, op := range []string{"=", "!=", "^=", "$=", "*=", "~="} {
+		if strings.HasPrefix(string(p.input[p.pos:]), op) {
+			attr.op = op
+			p.pos += len(op)
+			break
+		}
+	}
+	if attr.op == "" {
+		return attr, fmt.Errorf("expected an operator or ] after %q", attr.name)
+	}
+	p.skipSpace()
+	value, err := p.value()
+	if err != nil {
+		return attr, err
+	}
+	attr.value = value
+	if attr.op == "~=" {
+		if attr.re, err = regexp.Compile(value); err != nil {
+			return attr, err
+		}
+	}
+	p.skipSpace()
+	if p.peek() != ']' {
+		return attr, fmt.Errorf("expected ] after the value of %q", attr.name)
+	}
+	p.pos++
+	return attr, nil
+}
+
+// value parses a quoted string, or a bare value up to a space or ]
+func (p *queryParser) value() (string, error) {
+	quote := p.peek()
+	if quote != '"' && quote != '\'' {
+		start := p.pos
+		for !p.done() && p.peek() != ']' && !unicode.IsSpace(p.peek()) {
+			p.pos++
+		}
+		if p.pos == start {
+			return "", fmt.Errorf("expected a value")
+		}
+		return string(p.input[start:p.pos]), nil
+	}
+	p.pos++
+	var value strings.Builder
+	for !p.done() {
+		r := p.input[p.pos]
+		p.pos++
+		switch {
+		case r == quote:
+			return value.String(), nil
+		case r == '\\' && !p.done():
+			value.WriteRune(p.input[p.pos])
+			p.pos++
+		default:
+			value.WriteRune(r)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// WriteQueryResults writes the matches as text, one per line, or as JSON.
+func WriteQueryResults(w io.Writer, format Format, matches []*QueryNode) error {
+	switch format {
+	case FormatText:
+		for _, match := range matches {
+			location := reportPath(match.Path)
+			if match.Line > 0 {
+				location = fmt.Sprintf("%s:%d", location, match.Line)
+			}
+			description := match.Kind
+			if match.Name != "" {
+				description += " " + match.Name
+			}
+			if values := match.Attrs["value"]; len(values) > 0 {
+				description += " " + strings.Join(values, ", ")
+			}
+			if _, err := fmt.Fprintf(w, "%s: %s\n", location, description); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatJSON:
+		results := make([]QueryNode, len(matches))
+		for i, match := range matches {
+			results[i] = *match
+			results[i].Path = reportPath(match.Path)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	default:
+		return fmt.Errorf("query results can't be written as %s, only as text or json", format)
+	}
+}
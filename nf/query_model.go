@@ -0,0 +1,186 @@
+package nf
+
+import (
+	"reft-go/nf/directives"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// QueryModel returns the tree of every module, sorted by path.
+func QueryModel(modules []*Module) []*QueryNode {
+	sorted := make([]*Module, len(modules))
+	copy(sorted, modules)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	roots := make([]*QueryNode, len(sorted))
+	for i, module := range sorted {
+		roots[i] = moduleQueryNode(module)
+	}
+	return roots
+}
+
+func moduleQueryNode(module *Module) *QueryNode {
+	root := &QueryNode{Kind: "module", Path: module.Path}
+	root.addAttr("dsl_version", strconv.Itoa(module.DSLVersion))
+
+	for _, include := range module.Includes {
+		for _, item := range include.Items {
+			node := &QueryNode{Kind: "include", Name: item.Name, Path: module.Path, Line: include.LineNumber}
+			node.addAttr("from", include.ModulePath)
+			if item.Alias != "" {
+				node.addAttr("alias", item.Alias)
+			}
+			root.Children = append(root.Children, node)
+		}
+	}
+	for _, param := range module.Params {
+		node := &QueryNode{Kind: "param", Name: param.Name, Path: module.Path, Line: param.LineNumber}
+		if param.Default != nil {
+			node.addAttr("default", param.Default.GetText())
+		}
+		root.Children = append(root.Children, node)
+	}
+	for _, function := range module.Functions {
+		node := &QueryNode{Kind: "function", Name: function.Name, Path: module.Path, Line: function.Line}
+		node.addAttr("params", function.Params...)
+		root.Children = append(root.Children, node)
+	}
+	for i := range module.Processes {
+		root.Children = append(root.Children, processQueryNode(module.Path, &module.Processes[i]))
+	}
+
+	// calls to an included process or workflow also match its original name
+	originals := make(map[string]string)
+	for _, include := range module.Includes {
+		for _, item := range include.Items {
+			if item.Alias != "" {
+				originals[item.Alias] = item.Name
+			}
+		}
+	}
+	for _, workflow := range module.Workflows {
+		node := &QueryNode{Kind: "workflow", Name: workflow.Name, Path: module.Path}
+		if workflow.Closure != nil {
+			node.Line = workflow.Closure.GetLineNumber()
+		}
+		if workflow.Name == "" {
+			node.addAttr("entry", "true")
+		}
+		node.addAttr("takes", workflow.Takes...)
+		node.addAttr("emits", workflow.Emits...)
+		for _, call := range workflow.Calls {
+			callNode := &QueryNode{Kind: "call", Name: call.Name, Path: module.Path, Line: call.Line}
+			callNode.addAttr("target", call.Name)
+			if original, ok := originals[call.Name]; ok {
+				callNode.addAttr("target", original)
+			}
+			node.Children = append(node.Children, callNode)
+		}
+		root.Children = append(root.Children, node)
+	}
+
+	sort.SliceStable(root.Children, func(i, j int) bool {
+		return root.Children[i].Line < root.Children[j].Line
+	})
+	return root
+}
+
+/*
+processQueryNode returns the node of a process. The process has the
+values of its directives as attributes too, so
+
+	process[label=process_high]
+
+selects the processes with that label.
+*/
+func processQueryNode(path string, process *Process) *QueryNode {
+	node := &QueryNode{Kind: "process", Name: process.Name, Path: path, Line: process.Line()}
+	for _, directive := range process.Directives {
+		child := directiveQueryNode(path, process, directive)
+		node.addAttr(child.Name, child.Attrs["value"]...)
+		node.Children = append(node.Children, child)
+	}
+	for _, input := range process.Inputs {
+		node.Children = append(node.Children, valueQueryNode("input", path, process.Line(), input))
+	}
+	for _, output := range process.Outputs {
+		node.Children = append(node.Children, valueQueryNode("output", path, process.Line(), output))
+	}
+	return node
+}
+
+func directiveQueryNode(path string, process *Process, directive directives.Directive) *QueryNode {
+	node := &QueryNode{Kind: "directive", Name: directive.Type(), Path: path, Line: directive.Line()}
+	if call := process.DirectiveNode(directive); call != nil {
+		node.Name = call.GetMethodAsString()
+		args := call.GetArguments().GetText()
+		node.addAttr("value", strings.TrimSuffix(strings.TrimPrefix(args, "("), ")"))
+	}
+	addStarlarkAttrs(node, directive)
+	return node
+}
+
+// valueQueryNode returns the node of an input or output. Its name is the
+// qualifier, like path or tuple, and the elements of a tuple are its
+// children.
+func valueQueryNode(kind, path string, line int, value starlark.Value) *QueryNode {
+	node := &QueryNode{Kind: kind, Name: strings.ToLower(value.Type()), Path: path, Line: line}
+	node.addAttr("type", node.Name)
+	addStarlarkAttrs(node, value)
+	if hasAttrs, ok := value.(starlark.HasAttrs); ok {
+		if values, err := hasAttrs.Attr("values"); err == nil {
+			if list, ok := values.(*starlark.List); ok {
+				for i := 0; i < list.Len(); i++ {
+					node.Children = append(node.Children, valueQueryNode(kind, path, line, list.Index(i)))
+				}
+			}
+		}
+	}
+	return node
+}
+
+// addStarlarkAttrs adds the attributes of value that are strings, numbers
+// or booleans, or lists of them, unless the node has them already
+func addStarlarkAttrs(node *QueryNode, value starlark.Value) {
+	hasAttrs, ok := value.(starlark.HasAttrs)
+	if !ok {
+		return
+	}
+	for _, name := range hasAttrs.AttrNames() {
+		if _, ok := node.attr(name); ok || name == "name" {
+			continue
+		}
+		attr, err := hasAttrs.Attr(name)
+		if err != nil || attr == nil {
+			continue
+		}
+		if values, ok := scalarValues(attr); ok && len(values) > 0 {
+			node.addAttr(name, values...)
+		}
+	}
+}
+
+func scalarValues(value starlark.Value) ([]string, bool) {
+	switch v := value.(type) {
+	case starlark.NoneType:
+		return nil, true
+	case starlark.String:
+		return []string{string(v)}, true
+	case starlark.Int, starlark.Float, starlark.Bool:
+		return []string{v.String()}, true
+	case *starlark.List:
+		var values []string
+		for i := 0; i < v.Len(); i++ {
+			elements, ok := scalarValues(v.Index(i))
+			if !ok {
+				return nil, false
+			}
+			values = append(values, elements...)
+		}
+		return values, true
+	}
+	return nil, false
+}
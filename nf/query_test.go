@@ -0,0 +1,137 @@
+package nf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func queryTree() []*QueryNode {
+	container := &QueryNode{Kind: "directive", Name: "container", Path: "main.nf", Line: 4,
+		Attrs: map[string][]string{"value": {"biocontainers/fastqc:0.12.1"}}}
+	label := &QueryNode{Kind: "directive", Name: "label", Path: "main.nf", Line: 3,
+		Attrs: map[string][]string{"value": {"process_high"}}}
+	fastqc := &QueryNode{Kind: "process", Name: "FASTQC", Path: "main.nf", Line: 2,
+		Attrs:    map[string][]string{"label": {"process_high"}, "container": {"biocontainers/fastqc:0.12.1"}},
+		Children: []*QueryNode{label, container}}
+	multiqc := &QueryNode{Kind: "process", Name: "MULTIQC", Path: "main.nf", Line: 10,
+		Attrs: map[string][]string{"label": {"process_single"}},
+		Children: []*QueryNode{{Kind: "directive", Name: "label", Path: "main.nf", Line: 11,
+			Attrs: map[string][]string{"value": {"process_single"}}}}}
+	call := &QueryNode{Kind: "call", Name: "QC", Path: "main.nf", Line: 21,
+		Attrs: map[string][]string{"target": {"QC", "FASTQC"}}}
+	workflow := &QueryNode{Kind: "workflow", Name: "PIPELINE", Path: "main.nf", Line: 20, Children: []*QueryNode{call}}
+	return []*QueryNode{{Kind: "module", Path: "main.nf", Children: []*QueryNode{fastqc, multiqc, workflow}}}
+}
+
+func TestQuerySelect(t *testing.T) {
+	tests := []struct {
+		query string
+		lines []int
+	}{
+		{`process`, []int{2, 10}},
+		{`process[label="process_high"] > directive[name=container]`, []int{4}},
+		{`process[label!=process_high] directive`, []int{11}},
+		{`workflow call[target=FASTQC]`, []int{21}},
+		{`module > call`, nil},
+		{`module call`, []int{21}},
+		{`directive[value^=bio], directive[value$=_single]`, []int{4, 11}},
+		{`*[name~='^(FASTQC|QC)$']`, []int{2, 21}},
+		{`process[container]`, []int{2}},
+		{`[value*="fastqc"]`, []int{4}},
+		{`[file$=main.nf][line=20]`, []int{20}},
+	}
+	for _, tt := range tests {
+		query, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) failed: %v", tt.query, err)
+			continue
+		}
+		var lines []int
+		for _, match := range query.Select(queryTree()) {
+			lines = append(lines, match.Line)
+		}
+		if !reflect.DeepEqual(lines, tt.lines) {
+			t.Errorf("%s: expected lines %v, got %v", tt.query, tt.lines, lines)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := map[string]string{
+		``:                     "expected a selector",
+		`processes`:            `unknown kind "processes"`,
+		`process[label`:        "expected an operator",
+		`process[label=]`:      "expected a value",
+		`process[label="high]`: "unterminated string",
+		`process[name~="("]`:   "missing closing )",
+		`process >`:            "expected a selector",
+		`process,`:             "expected a selector",
+		`process]`:             `unexpected ']'`,
+	}
+	for query, expected := range tests {
+		_, err := ParseQuery(query)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("ParseQuery(%q): expected an error containing %q, got %v", query, expected, err)
+		}
+	}
+}
+
+func TestQueryModel(t *testing.T) {
+	mainContent := `include { FASTQC as QC } from './modules/fastqc'
+
+process MULTIQC {
+    label 'process_single'
+    container 'biocontainers/multiqc:1.21'
+
+    input:
+    path reports
+
+    script:
+    """
+    multiqc .
+    """
+}
+
+workflow {
+    QC(Channel.fromPath(params.input))
+    MULTIQC(QC.out.collect())
+}
+`
+	mainFile := filepath.Join(t.TempDir(), "main.nf")
+	if err := os.WriteFile(mainFile, []byte(mainContent), 0644); err != nil {
+		t.Fatal("Failed to write file:", err)
+	}
+	module, err, _ := BuildModule(mainFile)
+	if err != nil {
+		t.Fatal("Failed to build module:", err)
+	}
+
+	var output strings.Builder
+	for _, expr := range []string{
+		`process[label=process_single] > directive[name=container]`,
+		`workflow call[target=FASTQC]`,
+		`include[alias=QC]`,
+		`input[type=path]`,
+	} {
+		query, err := ParseQuery(expr)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q) failed: %v", expr, err)
+		}
+		if err := WriteQueryResults(&output, FormatText, query.Select(QueryModel([]*Module{module}))); err != nil {
+			t.Fatal("Failed to write results:", err)
+		}
+	}
+	for _, expected := range []string{
+		"main.nf:5: directive container biocontainers/multiqc:1.21\n",
+		"main.nf:17: call QC\n",
+		"main.nf:1: include FASTQC\n",
+		"main.nf:3: input path\n",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Expected output to contain %q, but got:\n%s", expected, output.String())
+		}
+	}
+}
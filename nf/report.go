@@ -0,0 +1,498 @@
+package nf
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatText       Format = "text"
+	FormatJSON       Format = "json"
+	FormatSARIF      Format = "sarif"
+	FormatJUnit      Format = "junit"
+	FormatCheckstyle Format = "checkstyle"
+	FormatGitHub     Format = "github"
+)
+
+var Formats = []Format{FormatText, FormatJSON, FormatSARIF, FormatJUnit, FormatCheckstyle, FormatGitHub}
+
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return FormatText, nil
+	}
+	for _, f := range Formats {
+		if Format(s) == f {
+			return f, nil
+		}
+	}
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("unknown format %q, expected one of %s", s, strings.Join(names, ", "))
+}
+
+// RuleDiagnostic is a diagnostic along with the rule that reported it
+type RuleDiagnostic struct {
+	Rule string
+	Diagnostic
+}
+
+// Diagnostics flattens the output, sorted by rule, path and position.
+func (g GroupedOutput) Diagnostics() []RuleDiagnostic {
+	var diagnostics []RuleDiagnostic
+	for rule, modules := range g {
+		for _, entry := range modules {
+			for _, d := range entry.Diagnostics {
+				diagnostics = append(diagnostics, RuleDiagnostic{rule, d})
+			}
+		}
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return diagnostics
+}
+
+func (g GroupedOutput) HasErrors() bool {
+	for _, modules := range g {
+		for _, entry := range modules {
+			if entry.HasErrors() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteReport writes the output in the given format.
+// It returns true if any diagnostic has error severity.
+func WriteReport(w io.Writer, format Format, output GroupedOutput) (bool, error) {
+	var err error
+	switch format {
+	case FormatText, "":
+		return printGroupedOutput(output, w), nil
+	case FormatJSON:
+		err = writeJSON(w, output)
+	case FormatSARIF:
+		err = writeSARIF(w, output)
+	case FormatJUnit:
+		err = writeJUnit(w, output)
+	case FormatCheckstyle:
+		err = writeCheckstyle(w, output)
+	case FormatGitHub:
+		err = writeGitHub(w, output)
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	return output.HasErrors(), err
+}
+
+// DroppedOutputNote returns a note about the lines printed by the rules
+// that the format doesn't show, "" if it shows them or there are none.
+func DroppedOutputNote(format Format, output GroupedOutput) string {
+	if n := len(output.outputs()); n > 0 && !formatsWithOutputs[format] {
+		return fmt.Sprintf("%d line(s) printed by the rules are not shown in %s output, use text or json to see them", n, format)
+	}
+	return ""
+}
+
+// The formats that show what the rules print
+var formatsWithOutputs = map[Format]bool{FormatText: true, "": true, FormatJSON: true, FormatJUnit: true}
+
+// ruleOutput is a line a rule printed while running on a file
+type ruleOutput struct {
+	Rule   string `json:"rule"`
+	Path   string `json:"path"`
+	Output string `json:"output"`
+}
+
+// outputs returns what the rules printed, sorted by rule and path
+func (g GroupedOutput) outputs() []ruleOutput {
+	var outputs []ruleOutput
+	for rule, modules := range g {
+		for path, entry := range modules {
+			for _, line := range entry.Outputs {
+				outputs = append(outputs, ruleOutput{rule, path, line})
+			}
+		}
+	}
+	sort.SliceStable(outputs, func(i, j int) bool {
+		a, b := outputs[i], outputs[j]
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Path < b.Path
+	})
+	return outputs
+}
+
+// reportPath makes a path relative to the working directory when it is
+// inside it, which is what code scanning and PR annotations expect.
+func reportPath(path string) string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(cwd, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+type jsonDiagnostic struct {
+	Rule     string   `json:"rule"`
+	Path     string   `json:"path"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Message  string   `json:"message"`
+}
+
+func writeJSON(w io.Writer, output GroupedOutput) error {
+	report := struct {
+		Diagnostics []jsonDiagnostic `json:"diagnostics"`
+		// what the rules printed
+		Outputs []ruleOutput `json:"outputs,omitempty"`
+	}{Diagnostics: []jsonDiagnostic{}}
+	for _, d := range output.Diagnostics() {
+		report.Diagnostics = append(report.Diagnostics, jsonDiagnostic{
+			Rule:     d.Rule,
+			Path:     reportPath(d.Path),
+			Line:     d.Line,
+			Column:   d.Column,
+			Severity: d.Severity,
+			Code:     d.Code,
+			Message:  d.Message,
+		})
+	}
+	for _, o := range output.outputs() {
+		o.Path = reportPath(o.Path)
+		report.Outputs = append(report.Outputs, o)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// SARIF 2.1.0, the subset code scanning needs
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func sarifLevel(severity Severity) string {
+	switch severity {
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "note"
+	default:
+		return "error"
+	}
+}
+
+func writeSARIF(w io.Writer, output GroupedOutput) error {
+	ruleNames := make([]string, 0, len(output))
+	for rule := range output {
+		ruleNames = append(ruleNames, rule)
+	}
+	sort.Strings(ruleNames)
+
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "reftrace", Rules: []sarifRule{}}},
+		Results: []sarifResult{},
+	}
+	for _, rule := range ruleNames {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: rule})
+	}
+	for _, d := range output.Diagnostics() {
+		location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: reportPath(d.Path)},
+		}}
+		if d.Line > 0 {
+			location.PhysicalLocation.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+		}
+		message := d.Message
+		if d.Code != "" {
+			message += " [" + d.Code + "]"
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    d.Rule,
+			Level:     sarifLevel(d.Severity),
+			Message:   sarifMessage{Text: message},
+			Locations: []sarifLocation{location},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// JUnit: one suite per rule and one test case per file.
+// Errors are failures, other diagnostics go to system-out.
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func formatDiagnosticLine(d Diagnostic) string {
+	line := fmt.Sprintf("%s: %s: %s", reportPath(d.Location()), d.Severity, d.Message)
+	if d.Code != "" {
+		line += " [" + d.Code + "]"
+	}
+	return line
+}
+
+func writeJUnit(w io.Writer, output GroupedOutput) error {
+	suites := junitTestSuites{}
+	ruleNames := make([]string, 0, len(output))
+	for rule := range output {
+		ruleNames = append(ruleNames, rule)
+	}
+	sort.Strings(ruleNames)
+
+	for _, rule := range ruleNames {
+		suite := junitTestSuite{Name: rule}
+		paths := make([]string, 0, len(output[rule]))
+		for path := range output[rule] {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			entry := output[rule][path]
+			testCase := junitTestCase{Name: reportPath(path), ClassName: rule}
+			var failures, others []string
+			for _, d := range entry.Diagnostics {
+				if d.Severity == SeverityError {
+					failures = append(failures, formatDiagnosticLine(d))
+				} else {
+					others = append(others, formatDiagnosticLine(d))
+				}
+			}
+			others = append(others, entry.Outputs...)
+			if len(failures) > 0 {
+				testCase.Failure = &junitFailure{
+					Message: fmt.Sprintf("%d error(s)", len(failures)),
+					Text:    strings.Join(failures, "\n"),
+				}
+				suite.Failures++
+			}
+			testCase.SystemOut = strings.Join(others, "\n")
+			suite.Cases = append(suite.Cases, testCase)
+			suite.Tests++
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr,omitempty"`
+	Column   int    `xml:"column,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+func writeCheckstyle(w io.Writer, output GroupedOutput) error {
+	report := checkstyleReport{Version: "4.3"}
+	files := make(map[string]*checkstyleFile)
+	var paths []string
+	for _, d := range output.Diagnostics() {
+		path := reportPath(d.Path)
+		file, ok := files[path]
+		if !ok {
+			file = &checkstyleFile{Name: path}
+			files[path] = file
+			paths = append(paths, path)
+		}
+		source := "reftrace." + d.Rule
+		if d.Code != "" {
+			source += "." + d.Code
+		}
+		file.Errors = append(file.Errors, checkstyleError{
+			Line:     d.Line,
+			Column:   d.Column,
+			Severity: string(d.Severity),
+			Message:  d.Message,
+			Source:   source,
+		})
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		report.Files = append(report.Files, *files[path])
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// GitHub Actions workflow commands, which annotate the files in a PR
+
+var githubDataEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+var githubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+
+func githubCommand(severity Severity) string {
+	switch severity {
+	case SeverityWarning:
+		return "warning"
+	case SeverityInfo:
+		return "notice"
+	default:
+		return "error"
+	}
+}
+
+func writeGitHub(w io.Writer, output GroupedOutput) error {
+	for _, d := range output.Diagnostics() {
+		properties := []string{"file=" + githubPropertyEscaper.Replace(reportPath(d.Path))}
+		if d.Line > 0 {
+			properties = append(properties, fmt.Sprintf("line=%d", d.Line))
+		}
+		if d.Column > 0 {
+			properties = append(properties, fmt.Sprintf("col=%d", d.Column))
+		}
+		title := d.Rule
+		if d.Code != "" {
+			title += " (" + d.Code + ")"
+		}
+		properties = append(properties, "title="+githubPropertyEscaper.Replace(title))
+		if _, err := fmt.Fprintf(w, "::%s %s::%s\n", githubCommand(d.Severity), strings.Join(properties, ","), githubDataEscaper.Replace(d.Message)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package nf
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testOutput() GroupedOutput {
+	return GroupedOutput{
+		"max_cpus": {
+			"main.nf": {Diagnostics: []Diagnostic{
+				{Path: "main.nf", Line: 3, Column: 5, Severity: SeverityError, Code: "max-cpus", Message: "too many cpus"},
+				{Path: "main.nf", Line: 2, Severity: SeverityWarning, Message: "100%, really"},
+			}},
+			"other.nf": {Outputs: []string{"checked other.nf"}},
+		},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat(""); err != nil || f != FormatText {
+		t.Errorf("Expected empty format to be text, got %q, %v", f, err)
+	}
+	if f, err := ParseFormat("sarif"); err != nil || f != FormatSARIF {
+		t.Errorf("Expected sarif, got %q, %v", f, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestWriteReportJSON(t *testing.T) {
+	var out strings.Builder
+	hasErrors, err := WriteReport(&out, FormatJSON, testOutput())
+	if err != nil || !hasErrors {
+		t.Fatalf("Expected errors and no write error, got %v, %v", hasErrors, err)
+	}
+	var report struct {
+		Diagnostics []jsonDiagnostic `json:"diagnostics"`
+		Outputs     []ruleOutput     `json:"outputs"`
+	}
+	if err := json.Unmarshal([]byte(out.String()), &report); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, out.String())
+	}
+	if len(report.Diagnostics) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %d", len(report.Diagnostics))
+	}
+	// sorted by position
+	if first := report.Diagnostics[0]; first.Line != 2 || first.Severity != SeverityWarning || first.Rule != "max_cpus" {
+		t.Errorf("Unexpected first diagnostic %+v", first)
+	}
+	expected := []ruleOutput{{Rule: "max_cpus", Path: "other.nf", Output: "checked other.nf"}}
+	if !reflect.DeepEqual(report.Outputs, expected) {
+		t.Errorf("Expected the outputs %+v, got %+v", expected, report.Outputs)
+	}
+	if note := DroppedOutputNote(FormatJSON, testOutput()); note != "" {
+		t.Errorf("Expected no note about the output, got %q", note)
+	}
+}
+
+func TestWriteReportSARIF(t *testing.T) {
+	var out strings.Builder
+	if _, err := WriteReport(&out, FormatSARIF, testOutput()); err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal([]byte(out.String()), &log); err != nil {
+		t.Fatalf("Invalid SARIF: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 2 {
+		t.Fatalf("Unexpected SARIF log:\n%s", out.String())
+	}
+	result := log.Runs[0].Results[1]
+	region := result.Locations[0].PhysicalLocation.Region
+	if result.RuleID != "max_cpus" || result.Level != "error" || region == nil || region.StartLine != 3 || region.StartColumn != 5 {
+		t.Errorf("Unexpected SARIF result %+v", result)
+	}
+	if note := DroppedOutputNote(FormatSARIF, testOutput()); !strings.HasPrefix(note, "1 line(s) printed by the rules are not shown in sarif output") {
+		t.Errorf("Expected a note about the dropped output, got %q", note)
+	}
+}
+
+func TestWriteReportJUnit(t *testing.T) {
+	var out strings.Builder
+	if _, err := WriteReport(&out, FormatJUnit, testOutput()); err != nil {
+		t.Fatal(err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal([]byte(out.String()), &suites); err != nil {
+		t.Fatalf("Invalid JUnit XML: %v", err)
+	}
+	if suites.Tests != 2 || suites.Failures != 1 {
+		t.Errorf("Expected 2 tests and 1 failure, got %d and %d", suites.Tests, suites.Failures)
+	}
+}
+
+func TestWriteReportCheckstyle(t *testing.T) {
+	var out strings.Builder
+	if _, err := WriteReport(&out, FormatCheckstyle, testOutput()); err != nil {
+		t.Fatal(err)
+	}
+	var report checkstyleReport
+	if err := xml.Unmarshal([]byte(out.String()), &report); err != nil {
+		t.Fatalf("Invalid Checkstyle XML: %v", err)
+	}
+	if len(report.Files) != 1 || len(report.Files[0].Errors) != 2 || report.Files[0].Errors[1].Source != "reftrace.max_cpus.max-cpus" {
+		t.Errorf("Unexpected Checkstyle report:\n%s", out.String())
+	}
+}
+
+func TestWriteReportGitHub(t *testing.T) {
+	var out strings.Builder
+	if _, err := WriteReport(&out, FormatGitHub, testOutput()); err != nil {
+		t.Fatal(err)
+	}
+	expected := "::warning file=main.nf,line=2,title=max_cpus::100%25, really\n" +
+		"::error file=main.nf,line=3,col=5,title=max_cpus (max-cpus)::too many cpus\n"
+	if out.String() != expected {
+		t.Errorf("Expected:\n%s\nbut got:\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,333 @@
+package nf
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"go.starlark.net/starlark"
+)
+
+// The kinds of rules
+const (
+	// the rule_, configrule_ and project_rule_ functions of the rules file
+	RuleKindModule  = "module"
+	RuleKindConfig  = "config"
+	RuleKindProject = "project"
+	// rules written in Go
+	RuleKindGo = "go"
+)
+
+// RuleInfo is what `reft rules` shows about a rule.
+type RuleInfo struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	// Severity of the problems the rule reports, with the settings
+	// applied. Empty if it depends on the problem, like for the rules of
+	// the rules file, which call error() or warning().
+	Severity Severity `json:"severity,omitempty"`
+	Enabled  bool     `json:"enabled"`
+	Category string   `json:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Fixable  bool     `json:"fixable,omitempty"`
+	// Description is a one line summary of what the rule enforces
+	Description string `json:"description,omitempty"`
+	// Doc is the long-form documentation, with examples of code the rule
+	// reports and of code it accepts. For the rules of the rules file it
+	// is the docstring of the function, after its first line.
+	Doc     string `json:"doc,omitempty"`
+	DocsURL string `json:"docs_url,omitempty"`
+	// Source is where the rule is defined, as file:line
+	Source string `json:"source,omitempty"`
+}
+
+// The prefixes of the rule functions, by kind
+var rulePrefixes = []struct{ prefix, kind string }{
+	{"project_rule_", RuleKindProject},
+	{"configrule_", RuleKindConfig},
+	{"rule_", RuleKindModule},
+}
+
+// trimRulePrefix returns the name of a rule without the prefix of its
+// function, if it has one
+func trimRulePrefix(name string) string {
+	for _, p := range rulePrefixes {
+		if strings.HasPrefix(name, p.prefix) {
+			return strings.TrimPrefix(name, p.prefix)
+		}
+	}
+	return name
+}
+
+// The rules nf reports under itself
+var coreRules = []BuiltinRule{
+	{
+		Name: ParseErrorRule,
+		Info: RuleInfo{
+			Severity:    SeverityError,
+			Description: "Modules must parse",
+			Doc: `A module that fails to parse is reported here instead of failing the
+whole lint, and the other rules don't run on it. A message that says
+"likely a bug in RefTrace" is about a module that Nextflow accepts but
+RefTrace doesn't, please report it.
+
+Bad:
+
+    process FOO {
+        container 'ubuntu:latest
+    }
+
+Good:
+
+    process FOO {
+        container 'ubuntu:latest'
+    }`,
+			Source: FuncSource(ParseError.Diagnostic),
+		},
+	},
+	{
+		Name: UnusedSuppressionRule,
+		Info: RuleInfo{
+			Severity:    SeverityWarning,
+			Description: "Suppression comments must suppress a problem",
+			Doc: `A reftrace-disable comment that no longer suppresses anything is
+reported, so suppressions don't outlive the problems they were added for.
+It is only reported for the rules that ran.
+
+Bad:
+
+    process FOO {
+        // reftrace-disable-next-line no_labels
+        label 'process_low'
+    }
+
+Good:
+
+    process FOO {
+        label 'process_low'
+    }`,
+			Source: FuncSource(ApplySuppressions),
+		},
+	},
+}
+
+/*
+FuncSource returns where a Go function is defined, as the import path of
+its package, the name of its file and the line, so it doesn't depend on
+where RefTrace was built.
+*/
+func FuncSource(fn any) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return ""
+	}
+	file, line := f.FileLine(f.Entry())
+	// reft-go/nf/configlint.ruleResourceLimits
+	name := f.Name()
+	pkg := name
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		if j := strings.Index(name[i:], "."); j >= 0 {
+			pkg = name[:i+j]
+		}
+	} else if j := strings.Index(name, "."); j >= 0 {
+		pkg = name[:j]
+	}
+	return fmt.Sprintf("%s/%s:%d", pkg, filepath.Base(file), line)
+}
+
+// RuleDoc returns the documentation of a rule from docs/<id>.md in docs,
+// "" if it has none. Packages of rules embed their documentation this way.
+func RuleDoc(docs fs.FS, id string) string {
+	doc, err := fs.ReadFile(docs, "docs/"+id+".md")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(doc))
+}
+
+// cleanDoc removes the indentation of the lines of a docstring after the
+// first, like Python's inspect.cleandoc
+func cleanDoc(doc string) string {
+	lines := strings.Split(strings.ReplaceAll(doc, "\t", "    "), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		if trimmed := strings.TrimLeft(line, " "); trimmed != "" {
+			if n := len(line) - len(trimmed); indent < 0 || n < indent {
+				indent = n
+			}
+		}
+	}
+	lines[0] = strings.TrimSpace(lines[0])
+	for i := 1; i < len(lines); i++ {
+		if len(lines[i]) >= indent && indent > 0 {
+			lines[i] = lines[i][indent:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// splitDoc returns the first line of a docstring and the rest of it
+func splitDoc(doc string) (string, string) {
+	summary, rest, _ := strings.Cut(cleanDoc(doc), "\n")
+	return strings.TrimSpace(summary), strings.Trim(rest, "\n")
+}
+
+// ruleInfos returns the rules of the rules file sorted by name
+func (r *lintRules) ruleInfos() []RuleInfo {
+	var infos []RuleInfo
+	for kind, rules := range map[string]map[string]starlark.Callable{
+		RuleKindModule:  r.modules,
+		RuleKindConfig:  r.configs,
+		RuleKindProject: r.projects,
+	} {
+		for name, rule := range rules {
+			info := RuleInfo{ID: name, Kind: kind}
+			if fn, ok := rule.(*starlark.Function); ok {
+				info.Description, info.Doc = splitDoc(fn.Doc())
+				pos := fn.Position()
+				info.Source = fmt.Sprintf("%s:%d", reportPath(pos.Filename()), pos.Line)
+			}
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].ID != infos[j].ID {
+			return infos[i].ID < infos[j].ID
+		}
+		return infos[i].Kind < infos[j].Kind
+	})
+	return infos
+}
+
+/*
+ListRules returns the rules `reft lint` knows about: the rules of the rules
+file, if config has one, then the built-in rules and config.BuiltinRules.
+Whether they are enabled and their severity come from the settings of
+config.Directory.
+*/
+func ListRules(config LintConfig) ([]RuleInfo, error) {
+	var rules []RuleInfo
+	var settings *Settings
+	if config.RulesFile != "" {
+		l, err := newLinter(config)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := l.loadRules(); err != nil {
+			return nil, err
+		}
+		settings = l.settings
+		rules = l.rules.ruleInfos()
+	} else {
+		dir, _ := filepath.Abs(config.Directory)
+		var err error
+		if settings, err = config.loadSettings(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	builtins := append(append(coreRules[:len(coreRules):len(coreRules)], builtinRules...), config.BuiltinRules...)
+	for _, rule := range builtins {
+		info := rule.Info
+		info.ID = rule.Name
+		info.Kind = RuleKindGo
+		if info.Source == "" && rule.Run != nil {
+			info.Source = FuncSource(rule.Run)
+		}
+		rules = append(rules, info)
+	}
+
+	for i := range rules {
+		rules[i].Enabled = settings.RuleEnabled(rules[i].ID)
+		if name := settings.Rule(rules[i].ID).Severity; name != "" {
+			rules[i].Severity, _ = ParseSeverity(name)
+		}
+	}
+	return rules, nil
+}
+
+// FindRules returns the rules with an ID. The ID may have the prefix of
+// the rule function, like rule_no_labels.
+func FindRules(rules []RuleInfo, id string) []RuleInfo {
+	var found []RuleInfo
+	for _, rule := range rules {
+		if rule.ID == id || rule.ID == trimRulePrefix(id) {
+			found = append(found, rule)
+		}
+	}
+	return found
+}
+
+// WriteRuleList writes the rules as a table, or as JSON.
+func WriteRuleList(w io.Writer, format Format, rules []RuleInfo) error {
+	switch format {
+	case FormatText:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tKind\tSeverity\tDescription\tSource")
+		for _, rule := range rules {
+			severity := string(rule.Severity)
+			if !rule.Enabled {
+				severity = "off"
+			} else if severity == "" {
+				severity = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", rule.ID, rule.Kind, severity, rule.Description, rule.Source)
+		}
+		return tw.Flush()
+	case FormatJSON:
+		if rules == nil {
+			rules = []RuleInfo{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rules)
+	default:
+		return fmt.Errorf("rules can't be written as %s, only as text or json", format)
+	}
+}
+
+// WriteRuleDoc writes what there is to know about a rule, for
+// `reft rules explain`.
+func WriteRuleDoc(w io.Writer, rule RuleInfo) {
+	fmt.Fprintf(w, "%s\n", rule.ID)
+	if rule.Description != "" {
+		fmt.Fprintf(w, "\n%s\n", rule.Description)
+	}
+	fmt.Fprintln(w)
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "  %-10s %s\n", name+":", value)
+		}
+	}
+	field("Kind", rule.Kind)
+	severity := string(rule.Severity)
+	if severity == "" {
+		severity = "set by the rule"
+	}
+	if !rule.Enabled {
+		severity += " (disabled by the settings)"
+	}
+	field("Severity", severity)
+	field("Category", rule.Category)
+	field("Tags", strings.Join(rule.Tags, ", "))
+	if rule.Fixable {
+		field("Fixable", "yes, with reft lint --fix")
+	}
+	field("Source", rule.Source)
+	field("Docs", rule.DocsURL)
+
+	switch {
+	case rule.Doc != "":
+		fmt.Fprintf(w, "\n%s\n", rule.Doc)
+	case rule.Kind != RuleKindGo:
+		fmt.Fprintf(w, "\nNo documentation. Add a docstring to the rule function: its first line\nis the description and the rest of it is shown here.\n")
+	}
+}
@@ -0,0 +1,69 @@
+package nf
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	writeFiles(t, map[string]string{
+		rulesFile: `load("helpers.py", "rule_loaded")
+
+def rule_documented(module):
+    """Processes must be documented
+
+    Bad:
+
+        process FOO {}
+    """
+    pass
+
+def configrule_bare(config):
+    pass
+`,
+		filepath.Join(tmpDir, "helpers.py"):   "def rule_loaded(module):\n    \"\"\"Loaded from another file\"\"\"\n    pass\n",
+		filepath.Join(tmpDir, "reftrace.yml"): "rules:\n  bare: false\n  documented:\n    severity: warning\n",
+	})
+
+	rules, err := ListRules(LintConfig{RulesFile: rulesFile, Directory: tmpDir})
+	if err != nil {
+		t.Fatal("Failed to list the rules:", err)
+	}
+	byID := make(map[string]RuleInfo)
+	for _, rule := range rules {
+		byID[rule.ID] = rule
+	}
+
+	documented := byID["documented"]
+	if documented.Kind != RuleKindModule || documented.Description != "Processes must be documented" ||
+		documented.Doc != "Bad:\n\n    process FOO {}" || documented.Severity != SeverityWarning || !documented.Enabled {
+		t.Errorf("Unexpected info for rule_documented: %+v", documented)
+	}
+	if !strings.HasSuffix(documented.Source, "rules.py:3") {
+		t.Errorf("Expected rule_documented to be defined in rules.py:3, got %s", documented.Source)
+	}
+	if bare := byID["bare"]; bare.Kind != RuleKindConfig || bare.Description != "" || bare.Enabled {
+		t.Errorf("Unexpected info for configrule_bare: %+v", bare)
+	}
+	if loaded := byID["loaded"]; !strings.HasSuffix(loaded.Source, "helpers.py:1") || loaded.Description != "Loaded from another file" {
+		t.Errorf("Unexpected info for rule_loaded: %+v", loaded)
+	}
+	if parseError := byID[ParseErrorRule]; parseError.Kind != RuleKindGo || parseError.Doc == "" ||
+		!strings.HasPrefix(parseError.Source, "reft-go/nf/util.go:") {
+		t.Errorf("Unexpected info for %s: %+v", ParseErrorRule, parseError)
+	}
+
+	if found := FindRules(rules, "rule_documented"); len(found) != 1 || found[0].ID != "documented" {
+		t.Errorf("Expected rule_documented to find documented, got %+v", found)
+	}
+	var output strings.Builder
+	WriteRuleDoc(&output, byID["bare"])
+	for _, expected := range []string{"Severity:  set by the rule (disabled by the settings)", "No documentation"} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Expected output to contain %q, but got:\n%s", expected, output.String())
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package nf
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.starlark.net/starlark"
+)
+
+// A ruleJob is a call of a rule function on a module, a config file or
+// the whole pipeline.
+type ruleJob struct {
+	rule string
+	fn   starlark.Callable
+	path string
+	arg  func() starlark.Value
+	// What the call printed and reported. Rules can report diagnostics on
+	// other files than path with path=.
+	output GroupedOutput
+	err    error
+	// How long the call took and how many Starlark steps it ran
+	duration time.Duration
+	steps    uint64
+}
+
+// ruleRunner calls rule functions
+type ruleRunner struct {
+	// workers is the number of calls run in parallel, the number of CPUs if 0
+	workers  int
+	lintRoot string
+	settings *Settings
+	// maxSteps and timeout limit every call of a rule, 0 is no limit
+	maxSteps uint64
+	timeout  time.Duration
+}
+
+/*
+run calls the rule functions on a pool of workers, each with its own
+thread. The globals of the rules file must be frozen before, so rules
+can't share state through them.
+
+Every job collects its output separately, mergeOutputs merges them in the
+order of the jobs, so the output doesn't depend on how the jobs were
+scheduled.
+*/
+func (r *ruleRunner) run(jobs []*ruleJob) error {
+	workers := r.workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(jobs))
+
+	queue := make(chan *ruleJob)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(thread *starlark.Thread) {
+			defer wg.Done()
+			for job := range queue {
+				r.call(thread, job)
+			}
+		}(newLintThread(fmt.Sprintf("lint_worker_%d", i), r.lintRoot))
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	for _, job := range jobs {
+		if job.err != nil {
+			return fmt.Errorf("error calling rule %s: %v", job.rule, job.err)
+		}
+	}
+	return nil
+}
+
+// mergeOutputs adds the outputs of the jobs to groupedOutput, which must
+// have an entry for their rules
+func mergeOutputs(groupedOutput GroupedOutput, jobs []*ruleJob) {
+	for _, job := range jobs {
+		for ruleName, byPath := range job.output {
+			for path, output := range byPath {
+				entry := groupedOutput[ruleName][path]
+				entry.Diagnostics = append(entry.Diagnostics, output.Diagnostics...)
+				entry.Outputs = append(entry.Outputs, output.Outputs...)
+				groupedOutput[ruleName][path] = entry
+			}
+		}
+	}
+}
+
+func (r *ruleRunner) call(thread *starlark.Thread, job *ruleJob) {
+	job.output = GroupedOutput{job.rule: {job.path: RuleModuleOutput{}}}
+
+	// Set the current rule and module context
+	thread.SetLocal("current_rule", job.rule)
+	thread.SetLocal("current_module", job.path)
+	thread.SetLocal("output", job.output)
+
+	// The thread is reused, so the limits start over for every call
+	thread.Steps = 0
+	if r.maxSteps > 0 {
+		thread.SetMaxExecutionSteps(r.maxSteps)
+	} else {
+		thread.SetMaxExecutionSteps(math.MaxUint64)
+	}
+	var timedOut atomic.Bool
+	var timer *time.Timer
+	cancelled := make(chan struct{})
+	if r.timeout > 0 {
+		timer = time.AfterFunc(r.timeout, func() {
+			timedOut.Store(true)
+			thread.Cancel("timeout")
+			close(cancelled)
+		})
+	}
+
+	start := time.Now()
+	kwargs, err := ruleKwargs(r.settings, job.rule, job.fn)
+	if err == nil {
+		_, err = starlark.Call(thread, job.fn, starlark.Tuple{job.arg()}, kwargs)
+	}
+	job.duration = time.Since(start)
+	job.steps = thread.Steps
+
+	if timer != nil && !timer.Stop() {
+		// wait for the cancellation, so it doesn't cancel the next call
+		<-cancelled
+	}
+	thread.Uncancel()
+
+	if evalErr, ok := err.(*starlark.EvalError); ok {
+		message, code := evalErr.Msg, ""
+		switch {
+		case timedOut.Load():
+			message, code = fmt.Sprintf("rule %s timed out after %s", job.rule, r.timeout), "rule-timeout"
+		case r.maxSteps > 0 && job.steps >= r.maxSteps:
+			message, code = fmt.Sprintf("rule %s exceeded the limit of %d steps", job.rule, r.maxSteps), "rule-step-limit"
+		}
+		entry := job.output[job.rule][job.path]
+		entry.Diagnostics = append(entry.Diagnostics, Diagnostic{
+			Path:     job.path,
+			Severity: SeverityError,
+			Code:     code,
+			Message:  message,
+		})
+		job.output[job.rule][job.path] = entry
+	} else if err != nil {
+		job.err = err
+	}
+}
+
+// newLintThread returns a thread that rules can run on
+func newLintThread(name, lintRoot string) *starlark.Thread {
+	thread := &starlark.Thread{Name: name, Print: lintPrint}
+	// Relative paths given to error() and warning() are relative to this
+	thread.SetLocal("lint_root", lintRoot)
+	return thread
+}
+
+// lintPrint adds what rules print to their output. Prints outside of a
+// rule, while the rules file is loaded, go to stderr.
+func lintPrint(thread *starlark.Thread, msg string) {
+	output, ok := thread.Local("output").(GroupedOutput)
+	if !ok {
+		fmt.Fprintln(os.Stderr, msg)
+		return
+	}
+	ruleName := thread.Local("current_rule").(string)
+	moduleName := thread.Local("current_module").(string)
+
+	entry := output[ruleName][moduleName]
+	entry.Outputs = append(entry.Outputs, msg)
+	output[ruleName][moduleName] = entry
+}
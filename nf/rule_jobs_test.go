@@ -0,0 +1,150 @@
+package nf
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.starlark.net/starlark"
+)
+
+func TestRunRuleJobsDeterministic(t *testing.T) {
+	rules := `
+def rule_count(name):
+    print("checking", name)
+    warning("checked", name, path="summary.txt")
+    if name == "broken.nf":
+        fail_here = {}["missing"]
+`
+	predeclared := starlark.StringDict{
+		"error":   starlark.NewBuiltin("error", diagnosticFunc(SeverityError)),
+		"warning": starlark.NewBuiltin("warning", diagnosticFunc(SeverityWarning)),
+	}
+	thread := newLintThread("test", "/pipeline")
+	globals, err := starlark.ExecFile(thread, "rules.py", rules, predeclared)
+	if err != nil {
+		t.Fatal("Failed to load rules:", err)
+	}
+	globals.Freeze()
+
+	settings := &Settings{}
+	run := func(workers int) GroupedOutput {
+		var jobs []*ruleJob
+		for i := 0; i < 50; i++ {
+			name := fmt.Sprintf("%02d.nf", i)
+			if i == 25 {
+				name = "broken.nf"
+			}
+			jobs = append(jobs, &ruleJob{
+				rule: "count",
+				fn:   globals["rule_count"].(starlark.Callable),
+				path: filepath.Join("/pipeline", name),
+				arg:  func() starlark.Value { return starlark.String(name) },
+			})
+		}
+		output := GroupedOutput{"count": make(map[string]RuleModuleOutput)}
+		runner := &ruleRunner{workers: workers, lintRoot: "/pipeline", settings: settings}
+		if err := runner.run(jobs); err != nil {
+			t.Fatal("Failed to run rules:", err)
+		}
+		mergeOutputs(output, jobs)
+		return output
+	}
+
+	sequential := run(1)
+	summary := sequential["count"]["/pipeline/summary.txt"].Diagnostics
+	if len(summary) != 50 || summary[0].Message != "checked 00.nf" || summary[49].Message != "checked 49.nf" {
+		t.Fatalf("Expected a diagnostic per job in job order, got %+v", summary)
+	}
+	if outputs := sequential["count"]["/pipeline/03.nf"].Outputs; len(outputs) != 1 || outputs[0] != "checking 03.nf" {
+		t.Errorf("Expected the print of the rule, got %v", outputs)
+	}
+	if broken := sequential["count"]["/pipeline/broken.nf"].Diagnostics; len(broken) != 1 || broken[0].Severity != SeverityError {
+		t.Errorf("Expected the failing call to be reported as an error, got %+v", broken)
+	}
+
+	for i := 0; i < 5; i++ {
+		parallel := run(8)
+		got := parallel["count"]["/pipeline/summary.txt"].Diagnostics
+		for j := range summary {
+			if got[j].Message != summary[j].Message {
+				t.Fatalf("Expected the same order as a sequential run, got %q at %d", got[j].Message, j)
+			}
+		}
+	}
+}
+
+func TestRuleLimits(t *testing.T) {
+	rules := `
+def rule_loop(name):
+    for i in range(1 << 40):
+        pass
+
+def rule_quick(name):
+    warning("quick", name)
+`
+	predeclared := starlark.StringDict{
+		"warning": starlark.NewBuiltin("warning", diagnosticFunc(SeverityWarning)),
+	}
+	globals, err := starlark.ExecFile(newLintThread("test", "/pipeline"), "rules.py", rules, predeclared)
+	if err != nil {
+		t.Fatal("Failed to load rules:", err)
+	}
+	globals.Freeze()
+
+	tests := []struct {
+		runner *ruleRunner
+		code   string
+	}{
+		{&ruleRunner{workers: 1, settings: &Settings{}, timeout: 50 * time.Millisecond}, "rule-timeout"},
+		{&ruleRunner{workers: 1, settings: &Settings{}, maxSteps: 1000}, "rule-step-limit"},
+	}
+	for _, test := range tests {
+		jobs := []*ruleJob{}
+		for _, rule := range []string{"loop", "quick"} {
+			jobs = append(jobs, &ruleJob{
+				rule: rule,
+				fn:   globals["rule_"+rule].(starlark.Callable),
+				path: "/pipeline/main.nf",
+				arg:  func() starlark.Value { return starlark.String("main.nf") },
+			})
+		}
+		output := GroupedOutput{"loop": {}, "quick": {}}
+		if err := test.runner.run(jobs); err != nil {
+			t.Fatal("Failed to run rules:", err)
+		}
+		mergeOutputs(output, jobs)
+		if d := output["loop"]["/pipeline/main.nf"].Diagnostics; len(d) != 1 || d[0].Code != test.code || d[0].Severity != SeverityError {
+			t.Errorf("Expected a %s error, got %+v", test.code, d)
+		}
+		// the limit of one call doesn't carry over to the next on the same thread
+		if d := output["quick"]["/pipeline/main.nf"].Diagnostics; len(d) != 1 || d[0].Message != "quick main.nf" {
+			t.Errorf("Expected the next rule to run normally, got %+v", d)
+		}
+		if jobs[0].steps == 0 || jobs[1].steps == 0 {
+			t.Error("Expected the steps of the calls to be recorded")
+		}
+	}
+}
+
+func TestPrintRuleProfile(t *testing.T) {
+	profiles := make(ruleProfiles)
+	profiles.add("fast", "/p/a.nf", time.Millisecond, 10)
+	profiles.add("slow", "/p/a.nf", 2*time.Millisecond, 100)
+	profiles.add("slow", "/p/b.nf", 5*time.Millisecond, 300)
+
+	var output strings.Builder
+	PrintRuleProfile(&output, profiles.sorted())
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a header and two rules, got:\n%s", output.String())
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "slow 2 7ms 400 /p/b.nf (5ms)" {
+		t.Errorf("Unexpected profile of the slowest rule %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "fast") {
+		t.Errorf("Expected fast to come last, got %q", lines[2])
+	}
+}
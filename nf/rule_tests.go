@@ -0,0 +1,318 @@
+package nf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"go.starlark.net/starlark"
+)
+
+type RuleTestConfig struct {
+	RulesFile string
+	// Fixtures is a directory of modules and config files with
+	// expectation comments
+	Fixtures  string
+	RulesPath []string
+	// Golden also compares all the output of the rules on each fixture
+	// to the fixture's .golden file
+	Golden bool
+	// UpdateGolden writes the .golden files instead of comparing them
+	UpdateGolden bool
+}
+
+// errRuleTestsFailed is returned when a fixture doesn't get the
+// diagnostics it expects
+var errRuleTestsFailed = errors.New("Rule tests failed")
+
+/*
+An expectation is a comment in a fixture saying which diagnostic a rule
+reports on a line:
+
+	process FOO { // expect: rule_no_labels "has no labels"
+
+	// expect: rule_container_tag "latest"
+	container 'ubuntu:latest'
+
+A comment after code expects the diagnostic on its line, a comment on its
+own line on the next line that isn't an expectation, or on no line at the
+end of the file. The message in quotes is optional, a diagnostic matches
+if its message contains it. Diagnostics without a line match an
+expectation on any line.
+*/
+type expectation struct {
+	rule    string
+	message string
+	line    int
+	// the line of the comment
+	commentLine int
+}
+
+var expectationPattern = regexp.MustCompile(`//\s*expect:\s*([A-Za-z_][A-Za-z0-9_]*)(?:\s+("(?:[^"\\]|\\.)*"))?`)
+
+// parseExpectations returns the expectations in the content of a fixture
+func parseExpectations(content string) ([]expectation, error) {
+	var expectations, pending []expectation
+	for i, line := range strings.Split(content, "\n") {
+		match := expectationPattern.FindStringSubmatchIndex(line)
+		if match == nil {
+			for _, e := range pending {
+				e.line = i + 1
+				expectations = append(expectations, e)
+			}
+			pending = nil
+			continue
+		}
+		e := expectation{rule: line[match[2]:match[3]], commentLine: i + 1}
+		if match[4] >= 0 {
+			message, err := strconv.Unquote(line[match[4]:match[5]])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid message %s", i+1, line[match[4]:match[5]])
+			}
+			e.message = message
+		}
+		// strip the prefix of the rule function, diagnostics are reported
+		// under the name of the rule
+		e.rule = trimRulePrefix(e.rule)
+		if strings.TrimSpace(line[:match[0]]) == "" {
+			pending = append(pending, e)
+		} else {
+			e.line = i + 1
+			expectations = append(expectations, e)
+		}
+	}
+	// expectations at the end of the file expect a diagnostic on no line
+	expectations = append(expectations, pending...)
+	return expectations, nil
+}
+
+func (e expectation) matches(rule string, d Diagnostic) bool {
+	return e.rule == rule && (d.Line == 0 || d.Line == e.line) && strings.Contains(d.Message, e.message)
+}
+
+func (e expectation) String() string {
+	if e.message == "" {
+		return e.rule
+	}
+	return fmt.Sprintf("%s %q", e.rule, e.message)
+}
+
+/*
+RunRuleTests runs the rules of a rules file on a directory of fixtures,
+and reports the diagnostics each fixture expects but doesn't get and the
+ones it gets but doesn't expect. Diagnostics outside of the fixtures, like
+those of a project rule on the directory, can't be expected and fail the
+run. Only the rules of the rules file are tested, not the built-in ones.
+*/
+func RunRuleTests(config RuleTestConfig, output io.Writer) error {
+	if output == nil {
+		output = os.Stdout
+	}
+	l, err := newLinter(LintConfig{RulesFile: config.RulesFile, Directory: config.Fixtures, RulesPath: config.RulesPath})
+	if err != nil {
+		return err
+	}
+	if _, err := l.loadRules(); err != nil {
+		return err
+	}
+	modules, parseErrors, err := ParseDirectory(l.dir)
+	if err != nil {
+		return fmt.Errorf("error processing directory: %v", err)
+	}
+	l.setModules(modules, parseErrors)
+	l.loadConfigs()
+	groupedOutput, err := l.lint(nil, io.Discard)
+	if err != nil {
+		return err
+	}
+
+	ruleNames := make(map[string]bool)
+	for _, rules := range []map[string]starlark.Callable{l.rules.modules, l.rules.configs, l.rules.projects} {
+		for name := range rules {
+			ruleNames[name] = true
+		}
+	}
+	ruleNames[ParseErrorRule] = true
+	ruleNames[UnusedSuppressionRule] = true
+	for rule := range groupedOutput {
+		if !ruleNames[rule] {
+			delete(groupedOutput, rule)
+		}
+	}
+
+	var fixtures []string
+	err = filepath.WalkDir(l.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ext := filepath.Ext(path); !d.IsDir() && (ext == ".nf" || ext == ".config") {
+			fixtures = append(fixtures, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error processing directory: %v", err)
+	}
+
+	isFixture := make(map[string]bool)
+	for _, path := range fixtures {
+		isFixture[path] = true
+	}
+	failed := 0
+	for _, path := range fixtures {
+		problems, err := testFixture(path, groupedOutput, ruleNames, config)
+		if err != nil {
+			return err
+		}
+		if len(problems) == 0 {
+			fmt.Fprintf(output, "%s %s\n", color.GreenString("ok  "), reportPath(path))
+			continue
+		}
+		failed++
+		fmt.Fprintf(output, "%s %s\n", color.RedString("FAIL"), reportPath(path))
+		for _, problem := range problems {
+			fmt.Fprintf(output, "    %s\n", strings.ReplaceAll(problem, "\n", "\n    "))
+		}
+	}
+	// diagnostics on the directory, like those of project rules without a
+	// path, or on files that can't have expectations, are all unexpected
+	var unexpected []string
+	for _, d := range groupedOutput.Diagnostics() {
+		if isFixture[d.Path] {
+			continue
+		}
+		location := reportPath(d.Path)
+		if d.Line > 0 {
+			location = fmt.Sprintf("%s:%d", location, d.Line)
+		}
+		unexpected = append(unexpected, fmt.Sprintf("%s: unexpected %s: %s", location, d.Rule, d.Message))
+	}
+	if len(unexpected) > 0 {
+		fmt.Fprintf(output, "%s outside of the fixtures\n", color.RedString("FAIL"))
+		for _, problem := range unexpected {
+			fmt.Fprintf(output, "    %s\n", problem)
+		}
+	}
+	fmt.Fprintf(output, "\n%d fixture(s), %d failed\n", len(fixtures), failed)
+	if len(unexpected) > 0 {
+		fmt.Fprintf(output, "%d unexpected diagnostic(s) outside of the fixtures\n", len(unexpected))
+	}
+	if failed > 0 || len(unexpected) > 0 {
+		return errRuleTestsFailed
+	}
+	return nil
+}
+
+// testFixture returns what is wrong with the output of the rules on a
+// fixture
+func testFixture(path string, groupedOutput GroupedOutput, ruleNames map[string]bool, config RuleTestConfig) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	expectations, err := parseExpectations(string(content))
+	if err != nil {
+		return []string{err.Error()}, nil
+	}
+
+	var problems []string
+	location := func(line int) string {
+		if line == 0 {
+			return reportPath(path)
+		}
+		return fmt.Sprintf("%s:%d", reportPath(path), line)
+	}
+	for _, e := range expectations {
+		if !ruleNames[e.rule] {
+			problems = append(problems, fmt.Sprintf("%s: unknown rule %s", location(e.commentLine), e.rule))
+		}
+	}
+
+	var diagnostics []RuleDiagnostic
+	for _, d := range groupedOutput.Diagnostics() {
+		if d.Path == path {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	matched := make([]bool, len(expectations))
+	for _, d := range diagnostics {
+		found := false
+		for i, e := range expectations {
+			if !matched[i] && e.matches(d.Rule, d.Diagnostic) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("%s: unexpected %s: %s", location(d.Line), d.Rule, d.Message))
+		}
+	}
+	for i, e := range expectations {
+		if !matched[i] && ruleNames[e.rule] {
+			problems = append(problems, fmt.Sprintf("%s: missing %s", location(e.commentLine), e))
+		}
+	}
+
+	if config.Golden || config.UpdateGolden {
+		goldenFile := path + ".golden"
+		actual := goldenOutput(path, groupedOutput, diagnostics)
+		if config.UpdateGolden {
+			if err := os.WriteFile(goldenFile, []byte(actual), 0644); err != nil {
+				return nil, fmt.Errorf("error writing golden file: %v", err)
+			}
+			return problems, nil
+		}
+		expected, err := os.ReadFile(goldenFile)
+		if os.IsNotExist(err) {
+			problems = append(problems, fmt.Sprintf("golden file not found: %s (create it with --update)", reportPath(goldenFile)))
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading golden file: %v", err)
+		} else if diff := UnifiedDiff(reportPath(goldenFile), "actual", string(expected), actual); diff != "" {
+			problems = append(problems, "output differs from the golden file:\n"+strings.TrimSuffix(diff, "\n"))
+		}
+	}
+	return problems, nil
+}
+
+// goldenOutput is all the output of the rules on a fixture: the
+// diagnostics sorted by position, then what the rules printed
+func goldenOutput(path string, groupedOutput GroupedOutput, diagnostics []RuleDiagnostic) string {
+	diagnostics = append([]RuleDiagnostic(nil), diagnostics...)
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Rule < b.Rule
+	})
+	var sb strings.Builder
+	for _, d := range diagnostics {
+		fmt.Fprintf(&sb, "%d:%d: %s %s", d.Line, d.Column, d.Severity, d.Rule)
+		if d.Code != "" {
+			fmt.Fprintf(&sb, "[%s]", d.Code)
+		}
+		fmt.Fprintf(&sb, ": %s\n", d.Message)
+	}
+	rules := make([]string, 0, len(groupedOutput))
+	for rule := range groupedOutput {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		for _, line := range groupedOutput[rule][path].Outputs {
+			fmt.Fprintf(&sb, "output %s: %s\n", rule, line)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,112 @@
+package nf
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseExpectations(t *testing.T) {
+	content := `process FOO { // expect: rule_no_labels "has no labels"
+    // expect: rule_container_tag "latest"
+    // expect: container_registry
+    container 'ubuntu:latest'
+}
+// expect: project_rule_readme "no \"README\""
+`
+	expectations, err := parseExpectations(content)
+	if err != nil {
+		t.Fatal("Failed to parse expectations:", err)
+	}
+	expected := []expectation{
+		{rule: "no_labels", message: "has no labels", line: 1, commentLine: 1},
+		{rule: "container_tag", message: "latest", line: 4, commentLine: 2},
+		{rule: "container_registry", line: 4, commentLine: 3},
+		{rule: "readme", message: `no "README"`, line: 7, commentLine: 6},
+	}
+	if !reflect.DeepEqual(expectations, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, expectations)
+	}
+
+	if _, err := parseExpectations(`// expect: rule_foo "\q"`); err == nil {
+		t.Error("Expected an error for an invalid message")
+	}
+}
+
+func TestRunRuleTests(t *testing.T) {
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	fixtures := filepath.Join(tmpDir, "tests")
+	process := func(name, comment string) string {
+		return "process " + name + " {" + comment + "\n    script:\n    \"\"\"\n    echo test\n    \"\"\"\n}\n"
+	}
+	passing := filepath.Join(fixtures, "passing.nf")
+	writeFiles(t, map[string]string{
+		rulesFile: "def rule_found(module):\n    for p in module.processes:\n        error(p.name, \"found\", line=p.line)\n",
+		passing:   process("FOO", ` // expect: rule_found "FOO found"`),
+		filepath.Join(fixtures, "failing.nf"): "// expect: rule_found \"BAR found\"\n" + process("BAZ", "") +
+			"// expect: rule_unknown\n",
+	})
+
+	var output strings.Builder
+	err := RunRuleTests(RuleTestConfig{RulesFile: rulesFile, Fixtures: fixtures}, &output)
+	if err != errRuleTestsFailed {
+		t.Errorf("Expected the rule tests to fail, got %v", err)
+	}
+	for _, expected := range []string{
+		"passing.nf\n",
+		"failing.nf:1: missing found \"BAR found\"\n",
+		"failing.nf:2: unexpected found: BAZ found\n",
+		"failing.nf:8: unknown rule unknown\n",
+		"2 fixture(s), 1 failed\n",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Expected output to contain %q, but got:\n%s", expected, output.String())
+		}
+	}
+
+	// golden files
+	os.Remove(filepath.Join(fixtures, "failing.nf"))
+	config := RuleTestConfig{RulesFile: rulesFile, Fixtures: fixtures, Golden: true}
+	output.Reset()
+	if err := RunRuleTests(config, &output); err != errRuleTestsFailed || !strings.Contains(output.String(), "golden file not found") {
+		t.Errorf("Expected the golden file to be missing, got %v:\n%s", err, output.String())
+	}
+	config.UpdateGolden = true
+	if err := RunRuleTests(config, &output); err != nil {
+		t.Fatal("Failed to update the golden files:", err)
+	}
+	golden, err := os.ReadFile(passing + ".golden")
+	if err != nil || string(golden) != "1:0: error found: FOO found\n" {
+		t.Errorf("Unexpected golden file %q, %v", golden, err)
+	}
+	config.UpdateGolden = false
+	writeFiles(t, map[string]string{passing + ".golden": "1:0: error found: FOO\n"})
+	output.Reset()
+	if err := RunRuleTests(config, &output); err != errRuleTestsFailed || !strings.Contains(output.String(), "+1:0: error found: FOO found") {
+		t.Errorf("Expected the output to differ from the golden file, got %v:\n%s", err, output.String())
+	}
+
+	// diagnostics outside of the fixtures can't be expected
+	projectRulesFile := filepath.Join(tmpDir, "project_rules.py")
+	writeFiles(t, map[string]string{
+		projectRulesFile: "def project_rule_readme(pipeline):\n    error(\"no README\")\n    error(\"no title\", path=\"README.md\", line=1)\n",
+	})
+	output.Reset()
+	err = RunRuleTests(RuleTestConfig{RulesFile: projectRulesFile, Fixtures: fixtures}, &output)
+	if err != errRuleTestsFailed {
+		t.Errorf("Expected the rule tests to fail, got %v", err)
+	}
+	for _, expected := range []string{
+		"outside of the fixtures\n",
+		"tests: unexpected readme: no README\n",
+		"README.md:1: unexpected readme: no title\n",
+		"2 unexpected diagnostic(s) outside of the fixtures\n",
+	} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Expected output to contain %q, but got:\n%s", expected, output.String())
+		}
+	}
+}
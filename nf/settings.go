@@ -0,0 +1,330 @@
+package nf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"gopkg.in/yaml.v3"
+)
+
+// SettingsFiles are the names of the settings file, in order of preference
+var SettingsFiles = []string{"reftrace.yml", ".reftrace.yml", "reftrace.yaml", ".reftrace.yaml"}
+
+/*
+Settings control which rules run on which files. They are read from a
+reftrace.yml file such as:
+
+	exclude: ["tests/**"]
+	rules:
+	  no_labels: false
+	  container_tag:
+	    severity: warning
+	    exclude: ["modules/local/**"]
+	    config:
+	      registries: ["quay.io"]
+	rules_path: ["rules/lib"]
+
+Rules are enabled unless they are disabled. A glob containing a slash
+is matched against the path relative to the directory of the settings
+file, where ** matches any number of directories. A glob without a slash
+matches any file or directory name in the path. The config of a rule is
+passed to rule functions that have a config parameter. The rules path
+lists directories load() looks for modules in.
+*/
+type Settings struct {
+	// Path of the settings file, empty if there is none
+	Path    string                   `yaml:"-"`
+	Include []string                 `yaml:"include"`
+	Exclude []string                 `yaml:"exclude"`
+	Rules   map[string]*RuleSettings `yaml:"rules"`
+	// RulesPath are directories load() looks for modules in
+	RulesPath []string `yaml:"rules_path"`
+}
+
+type RuleSettings struct {
+	Enabled  *bool                  `yaml:"enabled"`
+	Severity string                 `yaml:"severity"`
+	Include  []string               `yaml:"include"`
+	Exclude  []string               `yaml:"exclude"`
+	Config   map[string]interface{} `yaml:"config"`
+}
+
+var ruleSettingsKeys = []string{"enabled", "severity", "include", "exclude", "config"}
+
+// UnmarshalYAML accepts `rule: false` as a shorthand for `rule: {enabled: false}`
+func (r *RuleSettings) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		var enabled bool
+		if err := node.Decode(&enabled); err != nil {
+			return fmt.Errorf("line %d: a rule must be true, false or a mapping", node.Line)
+		}
+		r.Enabled = &enabled
+		return nil
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if !slices.Contains(ruleSettingsKeys, key.Value) {
+				return fmt.Errorf("line %d: unknown rule setting %q", key.Line, key.Value)
+			}
+		}
+		type plain RuleSettings
+		return node.Decode((*plain)(r))
+	default:
+		return fmt.Errorf("line %d: a rule must be true, false or a mapping", node.Line)
+	}
+}
+
+// FindSettings loads the first settings file found in dir or one of its
+// parents. The settings are empty if there is none.
+func FindSettings(dir string) (*Settings, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		for _, name := range SettingsFiles {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return LoadSettings(path)
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return &Settings{}, nil
+		}
+		dir = parent
+	}
+}
+
+// LoadSettings reads a settings file. The globs in it are made absolute.
+func LoadSettings(path string) (*Settings, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	settings := &Settings{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(settings); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid settings file %s: %v", path, err)
+	}
+	settings.Path = path
+
+	root := filepath.Dir(path)
+	settings.Include = resolveGlobs(root, settings.Include)
+	settings.Exclude = resolveGlobs(root, settings.Exclude)
+	for i, dir := range settings.RulesPath {
+		if !filepath.IsAbs(dir) {
+			settings.RulesPath[i] = filepath.Join(root, dir)
+		}
+	}
+	for name, rule := range settings.Rules {
+		if rule == nil {
+			return nil, fmt.Errorf("invalid settings file %s: rule %s has no settings", path, name)
+		}
+		if rule.Severity != "" {
+			if _, err := ParseSeverity(rule.Severity); err != nil {
+				return nil, fmt.Errorf("invalid settings file %s: rule %s: %v", path, name, err)
+			}
+		}
+		rule.Include = resolveGlobs(root, rule.Include)
+		rule.Exclude = resolveGlobs(root, rule.Exclude)
+	}
+	return settings, nil
+}
+
+// Globs with a slash are anchored to root, the others match any name
+func resolveGlobs(root string, globs []string) []string {
+	resolved := make([]string, len(globs))
+	for i, glob := range globs {
+		if strings.Contains(strings.TrimSuffix(glob, "/"), "/") {
+			// a trailing slash matches everything in the directory
+			if strings.HasSuffix(glob, "/") {
+				glob += "**"
+			}
+			glob = filepath.ToSlash(filepath.Join(root, glob))
+		}
+		resolved[i] = strings.TrimSuffix(glob, "/")
+	}
+	return resolved
+}
+
+func matchGlob(glob, path string) bool {
+	segments := strings.Split(filepath.ToSlash(path), "/")
+	if !strings.Contains(glob, "/") {
+		for _, segment := range segments {
+			if ok, _ := filepath.Match(glob, segment); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return matchSegments(strings.Split(glob, "/"), segments)
+}
+
+func matchSegments(glob, path []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(glob[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(glob[0], path[0]); !ok {
+			return false
+		}
+		glob, path = glob[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+// included reports whether path matches one of include, if there are any,
+// and none of exclude
+func included(include, exclude []string, path string) bool {
+	if len(include) > 0 {
+		found := false
+		for _, glob := range include {
+			if matchGlob(glob, path) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, glob := range exclude {
+		if matchGlob(glob, path) {
+			return false
+		}
+	}
+	return true
+}
+
+// Rule returns the settings of a rule, which has none if it isn't configured.
+func (s *Settings) Rule(name string) *RuleSettings {
+	if rule, ok := s.Rules[name]; ok {
+		return rule
+	}
+	return &RuleSettings{}
+}
+
+func (s *Settings) RuleEnabled(name string) bool {
+	enabled := s.Rule(name).Enabled
+	return enabled == nil || *enabled
+}
+
+// Applies reports whether a rule runs on the file at path.
+func (s *Settings) Applies(rule, path string) bool {
+	if !included(s.Include, s.Exclude, path) {
+		return false
+	}
+	settings := s.Rule(rule)
+	return included(settings.Include, settings.Exclude, path)
+}
+
+// RuleConfig returns the config of a rule as a frozen Starlark dict.
+func (s *Settings) RuleConfig(name string) (*starlark.Dict, error) {
+	value, err := toStarlark(s.Rule(name).Config)
+	if err != nil {
+		return nil, fmt.Errorf("config of rule %s: %v", name, err)
+	}
+	value.Freeze()
+	return value.(*starlark.Dict), nil
+}
+
+func toStarlark(value interface{}) (starlark.Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case int:
+		return starlark.MakeInt(v), nil
+	case int64:
+		return starlark.MakeInt64(v), nil
+	case uint64:
+		return starlark.MakeUint64(v), nil
+	case float64:
+		return starlark.Float(v), nil
+	case string:
+		return starlark.String(v), nil
+	case []interface{}:
+		items := make([]starlark.Value, len(v))
+		for i, item := range v {
+			converted, err := toStarlark(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = converted
+		}
+		return starlark.NewList(items), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(v))
+		for _, key := range keys {
+			converted, err := toStarlark(v[key])
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(key), converted); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	default:
+		return nil, fmt.Errorf("unsupported value %v of type %T", v, v)
+	}
+}
+
+/*
+Apply drops the diagnostics on files the settings exclude for their rule
+and overrides their severity.
+
+Diagnostics on dir, the linted directory, are those of project rules
+that aren't about a file, and are always kept.
+*/
+func (s *Settings) Apply(output GroupedOutput, dir string) {
+	for ruleName, byPath := range output {
+		var severity Severity
+		if name := s.Rule(ruleName).Severity; name != "" {
+			severity, _ = ParseSeverity(name)
+		}
+		for path, entry := range byPath {
+			kept := entry.Diagnostics[:0]
+			for _, d := range entry.Diagnostics {
+				if d.Path != dir && !s.Applies(ruleName, d.Path) {
+					continue
+				}
+				if severity != "" {
+					d.Severity = severity
+				}
+				kept = append(kept, d)
+			}
+			entry.Diagnostics = kept
+			byPath[path] = entry
+		}
+	}
+}
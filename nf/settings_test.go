@@ -0,0 +1,161 @@
+package nf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		glob  string
+		path  string
+		match bool
+	}{
+		{"/p/modules/**", "/p/modules/local/foo/main.nf", true},
+		{"/p/modules/**", "/p/main.nf", false},
+		{"/p/**/main.nf", "/p/main.nf", true},
+		{"/p/**/main.nf", "/p/modules/nf-core/fastqc/main.nf", true},
+		{"/p/modules/*.nf", "/p/modules/local/foo.nf", false},
+		{"/p/modules/*.nf", "/p/modules/foo.nf", true},
+		{"*.config", "/p/conf/base.config", true},
+		{"tests", "/p/tests/main.nf", true},
+		{"tests", "/p/subworkflows/local/main.nf", false},
+	}
+	for _, test := range tests {
+		if got := matchGlob(test.glob, test.path); got != test.match {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", test.glob, test.path, got, test.match)
+		}
+	}
+}
+
+func writeSettings(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "reftrace.yml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal("Failed to write settings:", err)
+	}
+	return path
+}
+
+func TestFindSettings(t *testing.T) {
+	root := t.TempDir()
+	writeSettings(t, root, `
+exclude: ["tests/"]
+rules:
+  no_labels: false
+  max_cpus:
+    severity: warning
+    include: ["modules/**"]
+    exclude: ["modules/local/skip.nf"]
+    config:
+      max: 8
+      registries: [quay.io]
+`)
+	nested := filepath.Join(root, "modules", "local")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := FindSettings(nested)
+	if err != nil {
+		t.Fatal("Failed to find settings:", err)
+	}
+	if settings.Path != filepath.Join(root, "reftrace.yml") {
+		t.Fatalf("Expected the settings of %s, got %q", root, settings.Path)
+	}
+	if settings.RuleEnabled("no_labels") || !settings.RuleEnabled("max_cpus") || !settings.RuleEnabled("other") {
+		t.Error("Expected only no_labels to be disabled")
+	}
+
+	module := filepath.Join(root, "modules", "local", "foo.nf")
+	if !settings.Applies("max_cpus", module) {
+		t.Error("Expected max_cpus to apply to", module)
+	}
+	for _, path := range []string{
+		filepath.Join(root, "main.nf"),
+		filepath.Join(root, "modules", "local", "skip.nf"),
+		filepath.Join(root, "tests", "modules", "foo.nf"),
+	} {
+		if settings.Applies("max_cpus", path) {
+			t.Error("Expected max_cpus not to apply to", path)
+		}
+	}
+
+	config, err := settings.RuleConfig("max_cpus")
+	if err != nil {
+		t.Fatal("Failed to convert config:", err)
+	}
+	if got := config.String(); got != `{"max": 8, "registries": ["quay.io"]}` {
+		t.Errorf("Unexpected config %s", got)
+	}
+	if err := config.SetKey(starlark.String("max"), starlark.MakeInt(1)); err == nil {
+		t.Error("Expected the config to be frozen")
+	}
+	if empty, _ := settings.RuleConfig("other"); empty.Len() != 0 {
+		t.Error("Expected an empty config for a rule without one")
+	}
+}
+
+func TestFindSettingsNone(t *testing.T) {
+	settings, err := FindSettings(t.TempDir())
+	if err != nil {
+		t.Fatal("Failed to find settings:", err)
+	}
+	if settings.Path != "" || !settings.RuleEnabled("any") || !settings.Applies("any", "/p/main.nf") {
+		t.Errorf("Expected empty settings, got %+v", settings)
+	}
+}
+
+func TestLoadSettingsErrors(t *testing.T) {
+	tests := map[string]string{
+		"rules:\n  max_cpus:\n    severty: warning\n": "unknown rule setting",
+		"rules:\n  max_cpus:\n    severity: fatal\n":  "severity",
+		"rules:\n  max_cpus: maybe\n":                 "must be true, false or a mapping",
+		"exclud: [tests]\n":                           "exclud",
+	}
+	for content, want := range tests {
+		path := writeSettings(t, t.TempDir(), content)
+		_, err := LoadSettings(path)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected an error about %q for %q, got %v", want, content, err)
+		}
+	}
+}
+
+func TestSettingsApply(t *testing.T) {
+	root := t.TempDir()
+	settings, err := LoadSettings(writeSettings(t, root, `
+rules:
+  max_cpus:
+    severity: info
+    exclude: [skip.nf]
+`))
+	if err != nil {
+		t.Fatal("Failed to load settings:", err)
+	}
+	main, skip := filepath.Join(root, "main.nf"), filepath.Join(root, "skip.nf")
+	output := GroupedOutput{
+		"max_cpus": {
+			main: {Diagnostics: []Diagnostic{{Path: main, Severity: SeverityError, Message: "too many"}}},
+			skip: {Diagnostics: []Diagnostic{{Path: skip, Severity: SeverityError, Message: "too many"}}},
+		},
+		"no_labels": {
+			skip: {Diagnostics: []Diagnostic{{Path: skip, Severity: SeverityWarning, Message: "no labels"}}},
+		},
+	}
+	settings.Apply(output, root)
+
+	if d := output["max_cpus"][main].Diagnostics; len(d) != 1 || d[0].Severity != SeverityInfo {
+		t.Errorf("Expected one info on main.nf, got %+v", d)
+	}
+	if d := output["max_cpus"][skip].Diagnostics; len(d) != 0 {
+		t.Errorf("Expected skip.nf to be excluded from max_cpus, got %+v", d)
+	}
+	if d := output["no_labels"][skip].Diagnostics; len(d) != 1 || d[0].Severity != SeverityWarning {
+		t.Errorf("Expected no_labels to be unchanged, got %+v", d)
+	}
+}
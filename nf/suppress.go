@@ -0,0 +1,290 @@
+package nf
+
+import (
+	"fmt"
+	"reft-go/parser"
+	"sort"
+	"strings"
+)
+
+/*
+Suppression comments silence diagnostics from specific rules:
+
+	// reftrace-disable-next-line rule_a, rule_b
+	// reftrace-disable rule_a
+	// reftrace-enable rule_a
+
+A disable that is closed by a matching enable covers the lines in between,
+otherwise it covers the whole file. Without rule names every rule is
+suppressed. Anything after ' -- ' is a free-form reason and is ignored.
+Rule names may be given with or without their 'rule_', 'configrule_' or
+'project_rule_' prefix, and a diagnostic code may be used in place of a
+rule name.
+*/
+const (
+	disableNextLineDirective = "reftrace-disable-next-line"
+	disableDirective         = "reftrace-disable"
+	enableDirective          = "reftrace-enable"
+)
+
+// UnusedSuppressionRule is the rule unused suppressions are reported under
+const UnusedSuppressionRule = "unused_suppression"
+
+type SuppressionKind int
+
+const (
+	SuppressNextLine SuppressionKind = iota
+	SuppressBlock
+	SuppressFile
+)
+
+type Suppression struct {
+	Kind SuppressionKind
+	// Line of the comment
+	Line int
+	// Rules is empty when every rule is suppressed
+	Rules []string
+	// Lines covered, unused for SuppressFile
+	StartLine int
+	EndLine   int
+	used      map[string]bool
+}
+
+func (s *Suppression) covers(rule, code string, line int) (string, bool) {
+	switch s.Kind {
+	case SuppressFile:
+	case SuppressNextLine, SuppressBlock:
+		if line < s.StartLine || line > s.EndLine {
+			return "", false
+		}
+	}
+	if len(s.Rules) == 0 {
+		return "", true
+	}
+	for _, name := range s.Rules {
+		if name == rule || (code != "" && name == code) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Suppressions holds the suppression comments of a single file.
+type Suppressions struct {
+	Path  string
+	Items []*Suppression
+	// enable comments that didn't close any disable
+	strayEnables []int
+}
+
+type suppressionComment struct {
+	directive string
+	rules     []string
+}
+
+func parseSuppressionComment(text string) (suppressionComment, bool) {
+	if strings.HasPrefix(text, "//") {
+		text = text[2:]
+	} else {
+		text = strings.TrimPrefix(text, "/*")
+		text = strings.TrimSuffix(text, "*/")
+		text = strings.TrimLeft(strings.TrimSpace(text), "*")
+	}
+	text = strings.TrimSpace(text)
+
+	var directive string
+	for _, d := range []string{disableNextLineDirective, disableDirective, enableDirective} {
+		if rest, ok := strings.CutPrefix(text, d); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			directive, text = d, rest
+			break
+		}
+	}
+	if directive == "" {
+		return suppressionComment{}, false
+	}
+	if idx := strings.Index(text, "--"); idx != -1 {
+		text = text[:idx]
+	}
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '*'
+	})
+	rules := []string{}
+	for _, field := range fields {
+		field = trimRulePrefix(field)
+		if field != "" {
+			rules = append(rules, field)
+		}
+	}
+	return suppressionComment{directive: directive, rules: rules}, true
+}
+
+func sameRules(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseSuppressions reads the suppression comments of a file.
+func ParseSuppressions(path string, comments []parser.Comment) *Suppressions {
+	s := &Suppressions{Path: path}
+	var open []*Suppression
+	for _, comment := range comments {
+		parsed, ok := parseSuppressionComment(comment.Text)
+		if !ok {
+			continue
+		}
+		switch parsed.directive {
+		case disableNextLineDirective:
+			s.Items = append(s.Items, &Suppression{
+				Kind:      SuppressNextLine,
+				Line:      comment.Line,
+				Rules:     parsed.rules,
+				StartLine: comment.EndLine + 1,
+				EndLine:   comment.EndLine + 1,
+				used:      make(map[string]bool),
+			})
+		case disableDirective:
+			suppression := &Suppression{
+				Kind:      SuppressFile,
+				Line:      comment.Line,
+				Rules:     parsed.rules,
+				StartLine: comment.EndLine + 1,
+				used:      make(map[string]bool),
+			}
+			s.Items = append(s.Items, suppression)
+			open = append(open, suppression)
+		case enableDirective:
+			closed := false
+			remaining := open[:0]
+			for _, suppression := range open {
+				if len(parsed.rules) == 0 || sameRules(parsed.rules, suppression.Rules) {
+					suppression.Kind = SuppressBlock
+					suppression.EndLine = comment.Line - 1
+					closed = true
+				} else {
+					remaining = append(remaining, suppression)
+				}
+			}
+			open = remaining
+			if !closed {
+				s.strayEnables = append(s.strayEnables, comment.Line)
+			}
+		}
+	}
+	return s
+}
+
+/*
+Suppressed reports whether a diagnostic of a rule is covered by a
+suppression comment, and marks the suppression as used.
+
+Diagnostics without a line are only covered by file-wide suppressions.
+*/
+func (s *Suppressions) Suppressed(rule string, d Diagnostic) bool {
+	if s == nil {
+		return false
+	}
+	suppressed := false
+	for _, suppression := range s.Items {
+		if d.Line == 0 && suppression.Kind != SuppressFile {
+			continue
+		}
+		if name, ok := suppression.covers(rule, d.Code, d.Line); ok {
+			suppression.used[name] = true
+			suppressed = true
+		}
+	}
+	return suppressed
+}
+
+/*
+Unused returns a warning for every suppression that silenced nothing.
+
+ran tells whether a rule was run, so that suppressions of rules that were
+skipped aren't reported. It is called with an empty name for suppressions
+that apply to every rule.
+*/
+func (s *Suppressions) Unused(ran func(rule string) bool) []Diagnostic {
+	if s == nil {
+		return nil
+	}
+	var diagnostics []Diagnostic
+	unused := func(line int, message string) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Path:     s.Path,
+			Line:     line,
+			Severity: SeverityWarning,
+			Code:     "unused-suppression",
+			Message:  message,
+		})
+	}
+	for _, suppression := range s.Items {
+		if len(suppression.Rules) == 0 {
+			if !suppression.used[""] && ran("") {
+				unused(suppression.Line, "suppression comment doesn't suppress any diagnostic")
+			}
+			continue
+		}
+		for _, rule := range suppression.Rules {
+			if !suppression.used[rule] && ran(rule) {
+				unused(suppression.Line, fmt.Sprintf("suppression of '%s' doesn't suppress any diagnostic", rule))
+			}
+		}
+	}
+	for _, line := range s.strayEnables {
+		unused(line, "reftrace-enable without a matching reftrace-disable")
+	}
+	return diagnostics
+}
+
+/*
+ApplySuppressions removes the suppressed diagnostics from the output and
+reports unused suppressions under UnusedSuppressionRule.
+*/
+func ApplySuppressions(output GroupedOutput, suppressions map[string]*Suppressions, ran func(rule string) bool) {
+	for ruleName, modules := range output {
+		for modulePath, entry := range modules {
+			if len(entry.Diagnostics) == 0 {
+				continue
+			}
+			kept := entry.Diagnostics[:0]
+			for _, d := range entry.Diagnostics {
+				path := d.Path
+				if path == "" {
+					path = modulePath
+				}
+				if !suppressions[path].Suppressed(ruleName, d) {
+					kept = append(kept, d)
+				}
+			}
+			entry.Diagnostics = kept
+			modules[modulePath] = entry
+		}
+	}
+
+	paths := make([]string, 0, len(suppressions))
+	for path := range suppressions {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		unused := suppressions[path].Unused(ran)
+		if len(unused) == 0 {
+			continue
+		}
+		if output[UnusedSuppressionRule] == nil {
+			output[UnusedSuppressionRule] = make(map[string]RuleModuleOutput)
+		}
+		output[UnusedSuppressionRule][path] = RuleModuleOutput{Diagnostics: unused}
+	}
+}
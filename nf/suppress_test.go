@@ -0,0 +1,135 @@
+package nf
+
+import (
+	"os"
+	"path/filepath"
+	"reft-go/parser"
+	"strings"
+	"testing"
+)
+
+func TestParseSuppressions(t *testing.T) {
+	comments := []parser.Comment{
+		{Text: "// reftrace-disable-next-line rule_no_cpus, max_memory -- legacy module", Line: 2, EndLine: 2},
+		{Text: "/* reftrace-disable no_cpus */", Line: 5, EndLine: 5, Multiline: true},
+		{Text: "// reftrace-enable no_cpus", Line: 9, EndLine: 9},
+		{Text: "// reftrace-disable configrule_selectors", Line: 12, EndLine: 12},
+		{Text: "// reftrace-disabled is not a directive", Line: 14, EndLine: 14},
+		{Text: "// reftrace-disable-next-line project_rule_readme", Line: 16, EndLine: 16},
+		{Text: "// reftrace-enable", Line: 20, EndLine: 20},
+	}
+	s := ParseSuppressions("main.nf", comments)
+
+	tests := []struct {
+		rule       string
+		line       int
+		suppressed bool
+	}{
+		{"no_cpus", 3, true},
+		{"max_memory", 3, true},
+		{"other", 3, false},
+		{"no_cpus", 4, false},
+		{"no_cpus", 7, true},
+		{"no_cpus", 10, false},
+		{"selectors", 13, true},
+		{"selectors", 30, false},
+		{"selectors", 0, false},
+		{"readme", 17, true},
+	}
+	for _, tt := range tests {
+		if got := s.Suppressed(tt.rule, Diagnostic{Line: tt.line}); got != tt.suppressed {
+			t.Errorf("Suppressed(%q, %d) = %v, expected %v", tt.rule, tt.line, got, tt.suppressed)
+		}
+	}
+
+	unused := s.Unused(func(rule string) bool { return true })
+	if len(unused) != 0 {
+		t.Errorf("Expected no unused suppressions, got %+v", unused)
+	}
+}
+
+func TestUnusedSuppressions(t *testing.T) {
+	comments := []parser.Comment{
+		{Text: "// reftrace-disable-next-line no_cpus", Line: 2, EndLine: 2},
+		{Text: "// reftrace-disable-next-line skipped_rule", Line: 4, EndLine: 4},
+		{Text: "// reftrace-enable no_cpus", Line: 6, EndLine: 6},
+		{Text: "// reftrace-disable", Line: 8, EndLine: 8},
+	}
+	s := ParseSuppressions("main.nf", comments)
+
+	unused := s.Unused(func(rule string) bool { return rule == "no_cpus" })
+	if len(unused) != 2 {
+		t.Fatalf("Expected 2 unused suppressions, got %+v", unused)
+	}
+	if unused[0].Line != 2 || unused[0].Code != "unused-suppression" || unused[0].Severity != SeverityWarning {
+		t.Errorf("Unexpected diagnostic for the unused suppression: %+v", unused[0])
+	}
+	if unused[1].Line != 6 {
+		t.Errorf("Expected the stray enable on line 6 to be reported, got %+v", unused[1])
+	}
+}
+
+func TestLintSuppressions(t *testing.T) {
+	rulesContent := `
+def rule_max_cpus(module):
+    for process in module.processes:
+        for cpus in process.directives.cpus:
+            if cpus.num > 8:
+                error("too many cpus", line=cpus.line)
+`
+	processContent := `
+process FOO {
+    // reftrace-disable-next-line max_cpus
+    cpus 16
+    script:
+    """
+    echo "test"
+    """
+}
+
+process BAR {
+    cpus 32
+    script:
+    """
+    echo "test"
+    """
+}
+
+// reftrace-disable-next-line max_cpus
+process BAZ {
+    cpus 1
+    script:
+    """
+    echo "test"
+    """
+}
+`
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	processFile := filepath.Join(tmpDir, "process.nf")
+	if err := os.WriteFile(rulesFile, []byte(rulesContent), 0644); err != nil {
+		t.Fatal("Failed to write rules file:", err)
+	}
+	if err := os.WriteFile(processFile, []byte(processContent), 0644); err != nil {
+		t.Fatal("Failed to write process file:", err)
+	}
+
+	var output strings.Builder
+	err := RunLintWithConfig(LintConfig{RulesFile: rulesFile, Directory: processFile}, &output)
+	if err == nil {
+		t.Fatal("Expected linting to fail, but it succeeded")
+	}
+
+	out := output.String()
+	if strings.Contains(out, processFile+":4:") {
+		t.Errorf("Expected the error on line 4 to be suppressed, but got:\n%s", out)
+	}
+	for _, expected := range []string{
+		"Error: " + processFile + ":12: too many cpus",
+		"Warning: " + processFile + ":19: suppression of 'max_cpus' doesn't suppress any diagnostic [unused-suppression]",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected output to contain %q, but got:\n%s", expected, out)
+		}
+	}
+}
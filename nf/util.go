@@ -1,9 +1,12 @@
 package nf
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"path/filepath"
+	"reft-go/parser"
+	"sort"
 	"strings"
 	"sync"
 
@@ -116,11 +119,60 @@ func ResolveIncludes(modules []*Module) ([]*ResolvedInclude, []*UnresolvedInclud
 	return resolvedIncludes, unresolvedIncludes
 }
 
-func ProcessDirectory(dir string) ([]*Module, error) {
+// ParseErrorRule is the rule modules that fail to parse are reported under
+const ParseErrorRule = "parse_error"
+
+// ParseError is a module that failed to parse
+type ParseError struct {
+	Path string
+	Err  error
+	// LikelyBug is set for errors that aren't syntax errors, which are
+	// more likely a bug in RefTrace than in the module
+	LikelyBug bool
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// Diagnostic returns the error as a diagnostic on the module, at the
+// syntax error if there is one. Likely bugs have the code parser-bug.
+func (e ParseError) Diagnostic() Diagnostic {
+	d := Diagnostic{
+		Path:     e.Path,
+		Severity: SeverityError,
+		Code:     "parse-error",
+		Message:  e.Err.Error(),
+	}
+	var syntaxErr *parser.SyntaxException
+	if errors.As(e.Err, &syntaxErr) {
+		d.Line = syntaxErr.StartLine
+		// ANTLR columns start at 0
+		d.Column = syntaxErr.StartCharPosition + 1
+		d.Message = syntaxErr.Message
+	}
+	if e.LikelyBug {
+		d.Code = "parser-bug"
+	}
+	return d
+}
+
+/*
+ParseDirectory parses the .nf files under dir. It returns the modules
+that parsed, and the errors of the others sorted by path. The error is
+only set when dir can't be walked.
+*/
+func ParseDirectory(dir string) ([]*Module, []ParseError, error) {
+	return ParseDirectoryCached(dir, nil)
+}
+
+// ParseDirectoryCached is ParseDirectory, but the modules that didn't
+// change since they were last parsed are built from the cache.
+func ParseDirectoryCached(dir string, cache *ModuleCache) ([]*Module, []ParseError, error) {
 	var modules []*Module
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	var errors []error
+	var parseErrors []ParseError
 
 	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -131,16 +183,14 @@ func ProcessDirectory(dir string) ([]*Module, error) {
 			wg.Add(1)
 			go func(path string) {
 				defer wg.Done()
-				module, err, _ := BuildModule(path)
+				module, err, likelyBug := cache.buildModule(path)
+				mu.Lock()
+				defer mu.Unlock()
 				if err != nil {
-					mu.Lock()
-					errors = append(errors, fmt.Errorf("%s: %v", path, err))
-					mu.Unlock()
+					parseErrors = append(parseErrors, ParseError{Path: path, Err: err, LikelyBug: likelyBug})
 					return
 				}
-				mu.Lock()
 				modules = append(modules, module)
-				mu.Unlock()
 			}(path)
 		}
 		return nil
@@ -148,12 +198,28 @@ func ProcessDirectory(dir string) ([]*Module, error) {
 
 	wg.Wait()
 
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Slice(parseErrors, func(i, j int) bool { return parseErrors[i].Path < parseErrors[j].Path })
+	return modules, parseErrors, nil
+}
+
+// ProcessDirectory is ParseDirectory, but fails if any module fails to
+// parse.
+func ProcessDirectory(dir string) ([]*Module, error) {
+	modules, parseErrors, err := ParseDirectory(dir)
 	if err != nil {
 		return nil, err
 	}
 
-	if len(errors) > 0 {
-		return nil, fmt.Errorf("encountered %d errors: %v", len(errors), errors)
+	if len(parseErrors) > 0 {
+		errs := make([]error, len(parseErrors))
+		for i, parseErr := range parseErrors {
+			errs[i] = parseErr
+		}
+		return nil, fmt.Errorf("encountered %d errors: %v", len(errs), errs)
 	}
 
 	return modules, nil
@@ -0,0 +1,244 @@
+package nf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// DefaultWatchInterval is how often watch mode looks for changes
+const DefaultWatchInterval = time.Second
+
+// fileState is what watch mode compares to find changed files
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+/*
+WatchLint lints like RunLintWithConfig, then again every time a file
+changes, until ctx is done:
+
+  - a changed module is parsed again, and the rules run on it again
+  - a changed config file reloads the config files, and the config rules
+    run on it again
+  - a changed rules file, a module it loads or a changed settings file
+    reloads the rules, which run on every file again
+
+Project rules and built-in rules run every time. Changes are polled for,
+and picked up right away on Linux, where inotify wakes the poller up.
+*/
+func WatchLint(ctx context.Context, config LintConfig, output io.Writer) error {
+	if config.Fix || config.UpdateBaseline {
+		return fmt.Errorf("watch mode can't be used with --fix or --update-baseline")
+	}
+	if output == nil {
+		output = os.Stdout
+	}
+	l, err := newLinter(config)
+	if err != nil {
+		return err
+	}
+	w := &watcher{linter: l, output: output}
+
+	interval := config.WatchInterval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	events, closeEvents := watchEvents(ctx, l.lintRoot)
+	defer closeEvents()
+
+	w.files = w.snapshot()
+	w.update(w.files)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-events:
+			// let the editor finish writing
+			time.Sleep(50 * time.Millisecond)
+		}
+		files := w.snapshot()
+		if changed := changedFiles(w.files, files); len(changed) > 0 {
+			w.files = files
+			w.update(changed)
+		}
+	}
+}
+
+type watcher struct {
+	*linter
+	output io.Writer
+	// the modules, config files, rules files and settings files
+	files map[string]fileState
+	// the rules file and the modules it loads
+	ruleFiles   map[string]bool
+	settingsErr error
+	rulesErr    error
+}
+
+// snapshot returns the state of the files watch mode reacts to
+func (w *watcher) snapshot() map[string]fileState {
+	files := make(map[string]fileState)
+	add := func(path string) {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			files[path] = fileState{info.ModTime(), info.Size()}
+		}
+	}
+	filepath.WalkDir(w.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ext := filepath.Ext(path); !d.IsDir() && (ext == ".nf" || ext == ".config") {
+			add(path)
+		}
+		return nil
+	})
+	add(w.rulesFile)
+	for path := range w.ruleFiles {
+		add(path)
+	}
+	// settings files that are created are picked up too
+	if w.settings.Path != "" {
+		add(w.settings.Path)
+	}
+	for _, name := range slices.Concat(SettingsFiles, w.config.SettingsFiles) {
+		add(filepath.Join(w.lintRoot, name))
+	}
+	return files
+}
+
+func changedFiles(before, after map[string]fileState) map[string]fileState {
+	changed := make(map[string]fileState)
+	for path, state := range after {
+		if previous, ok := before[path]; !ok || previous != state {
+			changed[path] = state
+		}
+	}
+	for path, state := range before {
+		if _, ok := after[path]; !ok {
+			changed[path] = state
+		}
+	}
+	return changed
+}
+
+func (w *watcher) isSettingsFile(path string) bool {
+	if path == w.settings.Path {
+		return true
+	}
+	for _, name := range slices.Concat(SettingsFiles, w.config.SettingsFiles) {
+		if path == filepath.Join(w.lintRoot, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// update parses the changed files, lints and redraws the output
+func (w *watcher) update(changed map[string]fileState) {
+	reloadSettings, reloadRules, reloadConfigs := false, w.rules == nil, false
+	changedPaths := make(map[string]bool)
+	var parse []string
+	for path := range changed {
+		switch {
+		case w.isSettingsFile(path):
+			reloadSettings, reloadRules = true, true
+		case path == w.rulesFile || w.ruleFiles[path]:
+			reloadRules = true
+		case filepath.Ext(path) == ".nf":
+			changedPaths[path] = true
+			delete(w.modules, path)
+			delete(w.parseErrors, path)
+			if _, exists := w.files[path]; exists {
+				parse = append(parse, path)
+			}
+		case filepath.Ext(path) == ".config":
+			changedPaths[path] = true
+			reloadConfigs = true
+		}
+	}
+	w.parseModules(parse)
+	if reloadConfigs {
+		w.loadConfigs()
+	}
+
+	if reloadSettings {
+		settings, err := w.config.loadSettings(w.lintRoot)
+		if err == nil {
+			w.settings = settings
+		}
+		w.settingsErr = err
+	}
+	if w.settingsErr != nil {
+		w.redraw(nil, w.settingsErr)
+		return
+	}
+	if reloadRules {
+		files, err := w.loadRules()
+		w.ruleFiles = make(map[string]bool)
+		for _, path := range files {
+			w.ruleFiles[path] = true
+		}
+		// watch the modules the rules file loads from now on
+		w.files = w.snapshot()
+		w.rulesErr = err
+	}
+	if w.rulesErr != nil {
+		w.redraw(nil, w.rulesErr)
+		return
+	}
+
+	groupedOutput, err := w.lint(changedPaths, w.output)
+	w.redraw(groupedOutput, err)
+}
+
+// parseModules parses the modules in parallel, like ProcessDirectory
+func (w *watcher) parseModules(paths []string) {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, path := range paths {
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			module, err, likelyBug := w.config.Cache.buildModule(path)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				w.parseErrors[path] = ParseError{Path: path, Err: err, LikelyBug: likelyBug}
+				return
+			}
+			w.modules[path] = module
+		}(path)
+	}
+	wg.Wait()
+}
+
+// redraw clears the terminal and writes the output of the last run
+func (w *watcher) redraw(groupedOutput GroupedOutput, err error) {
+	text := w.config.Format == "" || w.config.Format == FormatText
+	if text && w.output == os.Stdout && !color.NoColor {
+		fmt.Fprint(w.output, "\033[H\033[2J")
+	}
+
+	if err == nil {
+		err = w.report(groupedOutput, w.output)
+	}
+	if err != nil && err != errLintFailed {
+		color.New(color.FgRed).Fprintf(w.output, "Error: %v\n", err)
+	}
+	if text {
+		fmt.Fprintf(w.output, "\n[%s] Watching for changes, press Ctrl+C to stop\n", time.Now().Format("15:04:05"))
+	}
+}
@@ -0,0 +1,67 @@
+//go:build linux
+
+package nf
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF
+
+/*
+watchEvents returns a channel that receives a value when something changes
+in a directory under root. Directories created later are watched too.
+
+The events only wake watch mode up, which then compares the files to find
+what changed. The channel is nil if inotify can't be used.
+*/
+func watchEvents(ctx context.Context, root string) (<-chan struct{}, func()) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, func() {}
+	}
+	// a non-blocking file uses the runtime poller, so Close stops Read
+	file := os.NewFile(uintptr(fd), "inotify")
+	addWatches(fd, root)
+
+	events := make(chan struct{}, 1)
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			if _, err := file.Read(buf); err != nil {
+				return
+			}
+			// new directories have to be watched too, adding a watch again
+			// is a no-op
+			addWatches(fd, root)
+			select {
+			case events <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		file.Close()
+	}()
+	return events, func() { file.Close() }
+}
+
+func addWatches(fd int, root string) {
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if path != root && d.Name()[0] == '.' {
+			return filepath.SkipDir
+		}
+		unix.InotifyAddWatch(fd, path, inotifyMask)
+		return nil
+	})
+}
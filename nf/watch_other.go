@@ -0,0 +1,10 @@
+//go:build !linux
+
+package nf
+
+import "context"
+
+// watchEvents returns nil, so watch mode only polls for changes
+func watchEvents(ctx context.Context, root string) (<-chan struct{}, func()) {
+	return nil, func() {}
+}
@@ -0,0 +1,74 @@
+package nf
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is written by the watch loop and read by the test
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchLint(t *testing.T) {
+	tmpDir := t.TempDir()
+	rulesFile := filepath.Join(tmpDir, "rules.py")
+	mainFile := filepath.Join(tmpDir, "main.nf")
+	process := func(name string) string {
+		return "process " + name + " {\n    script:\n    \"\"\"\n    echo test\n    \"\"\"\n}\n"
+	}
+	writeFiles(t, map[string]string{
+		rulesFile: "def rule_names(module):\n    print(\"v1\", [p.name for p in module.processes])\n",
+		mainFile:  process("FOO"),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	output := &syncBuffer{}
+	done := make(chan error)
+	go func() {
+		done <- WatchLint(ctx, LintConfig{RulesFile: rulesFile, Directory: tmpDir, WatchInterval: 10 * time.Millisecond}, output)
+	}()
+	waitFor := func(expected string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(output.String(), expected) {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected output to contain %q, but got:\n%s", expected, output.String())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	waitFor(`Output: v1 ["FOO"]`)
+	writeFiles(t, map[string]string{mainFile: process("BARBAZ")})
+	waitFor(`Output: v1 ["BARBAZ"]`)
+	writeFiles(t, map[string]string{rulesFile: "def rule_names(module):\n    print(\"v2\", len(module.processes))\n"})
+	waitFor(`Output: v2 1`)
+	// a broken rules file is reported until it is fixed
+	writeFiles(t, map[string]string{rulesFile: "def rule_names(module)\n"})
+	waitFor("error parsing rules program")
+	writeFiles(t, map[string]string{rulesFile: "def rule_names(module):\n    print(\"v3\")\n"})
+	waitFor(`Output: v3`)
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Expected watch mode to stop without an error, got %v", err)
+	}
+}
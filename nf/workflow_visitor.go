@@ -108,12 +108,19 @@ func NewWorkflowBodyVisitor() *WorkflowBodyVisitor {
 }
 
 type Workflow struct {
-	Name    string
-	Takes   []string
-	Emits   []string
+	Name  string
+	Takes []string
+	Emits []string
+	// Calls are the processes and workflows the workflow calls
+	Calls   []WorkflowCall
 	Closure *parser.ClosureExpression
 }
 
+type WorkflowCall struct {
+	Name string
+	Line int
+}
+
 func (w *Workflow) ToProto() *pb.Workflow {
 	return &pb.Workflow{
 		Name:  w.Name,
@@ -175,6 +182,66 @@ func makeWorkflow(name string, closure *parser.ClosureExpression) Workflow {
 		Name:    name,
 		Takes:   visitor.Takes,
 		Emits:   visitor.Emits,
+		Calls:   workflowCalls(closure),
 		Closure: closure,
 	}
 }
+
+/*
+workflowCalls returns what the body of a workflow may call: the method
+calls without an object, like FOO(ch), and the right side of pipes, like
+ch | FOO. resolveWorkflowCalls keeps the processes and workflows.
+*/
+func workflowCalls(closure *parser.ClosureExpression) []WorkflowCall {
+	var calls []WorkflowCall
+	v := NewBaseVisitor()
+	v.VisitMethodCallExpressionHook = func(call *parser.MethodCallExpression) {
+		if call.IsImplicitThis() {
+			calls = append(calls, WorkflowCall{Name: call.GetMethodAsString(), Line: call.GetLineNumber()})
+		}
+		v.VisitExpression(call.GetObjectExpression())
+		v.VisitExpression(call.GetMethod())
+		v.VisitExpression(call.GetArguments())
+	}
+	v.VisitBinaryExpressionHook = func(expr *parser.BinaryExpression) {
+		if variable, ok := expr.GetRightExpression().(*parser.VariableExpression); ok && expr.GetOperation().GetText() == "|" {
+			calls = append(calls, WorkflowCall{Name: variable.GetName(), Line: variable.GetLineNumber()})
+		}
+		v.VisitExpression(expr.GetLeftExpression())
+		v.VisitExpression(expr.GetRightExpression())
+	}
+	v.VisitStatement(closure.GetCode())
+	return calls
+}
+
+// resolveWorkflowCalls drops the calls of the workflows that aren't to a
+// process or workflow defined in or included into the module.
+func resolveWorkflowCalls(workflows []Workflow, processes []Process, includes []IncludeStatement) {
+	callable := make(map[string]bool)
+	for _, process := range processes {
+		callable[process.Name] = true
+	}
+	for _, workflow := range workflows {
+		if workflow.Name != "" {
+			callable[workflow.Name] = true
+		}
+	}
+	for _, include := range includes {
+		for _, item := range include.Items {
+			if item.Alias != "" {
+				callable[item.Alias] = true
+			} else {
+				callable[item.Name] = true
+			}
+		}
+	}
+	for i := range workflows {
+		var calls []WorkflowCall
+		for _, call := range workflows[i].Calls {
+			if callable[call.Name] {
+				calls = append(calls, call)
+			}
+		}
+		workflows[i].Calls = calls
+	}
+}
@@ -24,6 +24,17 @@ type MyGroovyLexer struct {
 	lastTokenType     int
 	invalidDigitCount int
 	parenStack        []Paren
+	comments          []Comment
+}
+
+// Comment is a comment in the source, kept so that tools can read
+// directives such as lint suppressions. Lines and columns are 1-based.
+type Comment struct {
+	Text      string
+	Line      int
+	Column    int
+	EndLine   int
+	Multiline bool
 }
 
 func (l *GroovyLexer) Emit() antlr.Token {
@@ -254,10 +265,22 @@ func (l *GroovyLexer) ignoreTokenInsideParens() {
 	l.SetChannel(antlr.TokenHiddenChannel)
 }
 
+// _type is 0 for /* */ comments and 1 for // comments
 func (l *GroovyLexer) addComment(_type int) {
-	// TODO: implement this
-	//text := l.GetInputStream().GetText(antlr.NewInterval(l.GetTokenStartCharIndex(), l.GetCharIndex()-1))
-	// Handle the comment text as needed
+	text := l.GetInputStream().GetText(l.GetTokenStartCharIndex(), l.GetCharIndex()-1)
+	line := l.GetTokenStartLine()
+	l.comments = append(l.comments, Comment{
+		Text:      text,
+		Line:      line,
+		Column:    l.GetTokenStartColumn() + 1,
+		EndLine:   line + strings.Count(text, "\n"),
+		Multiline: _type == 0,
+	})
+}
+
+// GetComments returns the comments seen so far, in source order.
+func (l *GroovyLexer) GetComments() []Comment {
+	return l.comments
 }
 
 func (l *GroovyLexer) isFollowedByWhiteSpaces() bool {
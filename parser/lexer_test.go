@@ -165,3 +165,24 @@ func TestIncludeLexer(t *testing.T) {
 		}
 	}
 }
+
+func TestLexerComments(t *testing.T) {
+	input := antlr.NewInputStream("def a = 1 // trailing\n/* block\n   comment */\ndef b = 2\n")
+	lexer := NewGroovyLexer(input)
+	for token := lexer.NextToken(); token.GetTokenType() != antlr.TokenEOF; token = lexer.NextToken() {
+	}
+
+	comments := lexer.GetComments()
+	if len(comments) != 2 {
+		t.Fatalf("Expected 2 comments, got %d: %+v", len(comments), comments)
+	}
+	expected := []Comment{
+		{Text: "// trailing", Line: 1, Column: 11, EndLine: 1},
+		{Text: "/* block\n   comment */", Line: 2, Column: 1, EndLine: 3, Multiline: true},
+	}
+	for i, comment := range comments {
+		if comment != expected[i] {
+			t.Errorf("Comment %d: expected %+v, got %+v", i, expected[i], comment)
+		}
+	}
+}
@@ -23,6 +23,10 @@ type ModuleNode struct {
 	StatementBlock    *BlockStatement
 	ScriptDummy       IClassNode
 	ImportsResolved   bool
+	// Source is the text the module was parsed from
+	Source string
+	// Comments in source order
+	Comments []Comment
 }
 
 func NewModuleNode(description string) *ModuleNode {
@@ -40,6 +44,30 @@ func NewModuleNode(description string) *ModuleNode {
 	}
 }
 
+// SourceText returns the source code spanned by node.
+// Lines and columns are 1-based and the last column is exclusive.
+func (m *ModuleNode) SourceText(node ASTNodeNoVisit) string {
+	lines := strings.SplitAfter(m.Source, "\n")
+	first, last := node.GetLineNumber(), node.GetLastLineNumber()
+	if first < 1 || last < first || last > len(lines) {
+		return ""
+	}
+
+	var sb strings.Builder
+	for i := first; i <= last; i++ {
+		line := []rune(lines[i-1])
+		start, end := 0, len(line)
+		if i == first {
+			start = min(max(node.GetColumnNumber()-1, 0), len(line))
+		}
+		if i == last {
+			end = min(max(node.GetLastColumnNumber()-1, start), len(line))
+		}
+		sb.WriteString(string(line[start:end]))
+	}
+	return sb.String()
+}
+
 func (m *ModuleNode) GetClasses() []IClassNode {
 	mainClass := m.createStatementsClass()
 	m.MainClassName = mainClass.GetName()
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/antlr4-go/antlr/v4"
+)
+
+/*
+Snapshot is what the parser needs besides the source of a file to build
+its AST again: the tokens of the lexer, the comments it saw and the
+prediction mode that parsed the file. Building the AST from a snapshot
+skips the lexer, and the failed SLL attempt of the files that only parse
+in LL mode.
+
+A snapshot is only valid for the source it was taken of, and for the
+lexer and the parser that took it.
+*/
+type Snapshot struct {
+	Mode     string
+	Tokens   []SnapshotToken
+	Comments []Comment
+}
+
+// SnapshotToken is a token of a Snapshot. Text is only set when the lexer
+// changed the text of the token, it is read from the source otherwise.
+type SnapshotToken struct {
+	Type    int
+	Channel int
+	Start   int
+	Stop    int
+	Line    int
+	Column  int
+	Text    string
+}
+
+// BuildASTSnapshot is BuildAST, but it also returns the snapshot
+// BuildASTFromSnapshot builds the AST from again.
+func BuildASTSnapshot(filePath string) (*ModuleNode, *Snapshot, error) {
+	parseResult, err := BuildCST(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: failed to build CST: %w", filePath, err)
+	}
+	ast, err := buildASTFromCST(filePath, parseResult)
+	if err != nil {
+		return nil, nil, err
+	}
+	source, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	ast.Source = string(source)
+
+	input := antlr.NewInputStream(ast.Source)
+	snapshot := &Snapshot{Mode: parseResult.Mode, Comments: parseResult.Comments}
+	for _, token := range parseResult.Tokens {
+		t := SnapshotToken{
+			Type:    token.GetTokenType(),
+			Channel: token.GetChannel(),
+			Start:   token.GetStart(),
+			Stop:    token.GetStop(),
+			Line:    token.GetLine(),
+			Column:  token.GetColumn(),
+		}
+		if token.GetTokenType() != antlr.TokenEOF && token.GetText() != input.GetText(t.Start, t.Stop) {
+			t.Text = token.GetText()
+		}
+		snapshot.Tokens = append(snapshot.Tokens, t)
+	}
+	return ast, snapshot, nil
+}
+
+// BuildASTFromSnapshot builds the AST of a file from its source and the
+// snapshot BuildASTSnapshot took of it.
+func BuildASTFromSnapshot(filePath string, source []byte, snapshot *Snapshot) (*ModuleNode, error) {
+	var mode int
+	switch snapshot.Mode {
+	case "SLL":
+		mode = antlr.PredictionModeSLL
+	case "LL":
+		mode = antlr.PredictionModeLL
+	default:
+		return nil, fmt.Errorf("%s: invalid snapshot mode %q", filePath, snapshot.Mode)
+	}
+
+	lexer := &replayLexer{GroovyLexer: NewGroovyLexer(antlr.NewInputStream(string(source)))}
+	pair := lexer.GetTokenSourceCharStreamPair()
+	for _, t := range snapshot.Tokens {
+		lexer.tokens = append(lexer.tokens, antlr.CommonTokenFactoryDEFAULT.Create(pair, t.Type, t.Text, t.Channel, t.Start, t.Stop, t.Line, t.Column))
+	}
+	stream := antlr.NewCommonTokenStream(lexer, 0)
+	stream.Fill()
+
+	tree, err := parseTokens(stream, mode)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to build CST: %w", filePath, err)
+	}
+	ast, err := buildASTFromCST(filePath, ParseResult{Tree: tree, Mode: snapshot.Mode, Comments: snapshot.Comments})
+	if err != nil {
+		return nil, err
+	}
+	ast.Source = string(source)
+	return ast, nil
+}
+
+// replayLexer returns the tokens of a snapshot instead of lexing its input
+type replayLexer struct {
+	*GroovyLexer
+	tokens []antlr.Token
+	next   int
+}
+
+func (r *replayLexer) NextToken() antlr.Token {
+	if r.next >= len(r.tokens) {
+		// a snapshot ends with EOF, this is only reached by an empty one
+		return antlr.CommonTokenFactoryDEFAULT.Create(r.GetTokenSourceCharStreamPair(), antlr.TokenEOF, "", antlr.TokenDefaultChannel, -1, -1, 0, 0)
+	}
+	token := r.tokens[r.next]
+	r.next++
+	return token
+}
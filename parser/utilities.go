@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"io/fs"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -54,21 +55,24 @@ func IsJavaIdentifier(name string) bool {
 }
 
 type ParseResult struct {
-	Tree antlr.ParseTree
-	Mode string // "SLL", "LL", or "Failed"
+	Tree     antlr.ParseTree
+	Mode     string // "SLL", "LL", or "Failed"
+	Comments []Comment
+	// Tokens are all the tokens of the lexer, the hidden ones too
+	Tokens []antlr.Token
 }
 
 func BuildCST(filePath string) (ParseResult, error) {
 	// Try SLL mode first
-	result, err := TryBuildCST(filePath, antlr.PredictionModeSLL)
+	result, comments, tokens, err := tryBuildCST(filePath, antlr.PredictionModeSLL)
 	if err == nil {
-		return ParseResult{Tree: result, Mode: "SLL"}, nil
+		return ParseResult{Tree: result, Mode: "SLL", Comments: comments, Tokens: tokens}, nil
 	}
 
 	// If SLL failed, try LL mode
-	result, err = TryBuildCST(filePath, antlr.PredictionModeLL)
+	result, comments, tokens, err = tryBuildCST(filePath, antlr.PredictionModeLL)
 	if err == nil {
-		return ParseResult{Tree: result, Mode: "LL"}, nil
+		return ParseResult{Tree: result, Mode: "LL", Comments: comments, Tokens: tokens}, nil
 	}
 
 	// If both modes failed, return the error
@@ -76,9 +80,14 @@ func BuildCST(filePath string) (ParseResult, error) {
 }
 
 func TryBuildCST(filePath string, mode int) (antlr.ParseTree, error) {
+	tree, _, _, err := tryBuildCST(filePath, mode)
+	return tree, err
+}
+
+func tryBuildCST(filePath string, mode int) (antlr.ParseTree, []Comment, []antlr.Token, error) {
 	input, err := antlr.NewFileStream(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
+		return nil, nil, nil, fmt.Errorf("failed to open file %s: %w", filePath, err)
 	}
 
 	lexer := NewGroovyLexer(input)
@@ -89,6 +98,15 @@ func TryBuildCST(filePath string, mode int) (antlr.ParseTree, error) {
 	stream := antlr.NewCommonTokenStream(lexer, 0)
 	stream.Fill()
 
+	result, err := parseTokens(stream, mode)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return result, lexer.GetComments(), stream.GetAllTokens(), nil
+}
+
+// parseTokens parses the tokens of a stream in a prediction mode
+func parseTokens(stream antlr.TokenStream, mode int) (antlr.ParseTree, error) {
 	parser := NewGroovyParser(stream)
 	parser.RemoveErrorListeners()
 
@@ -176,8 +194,20 @@ func BuildAST(filePath string) (*ModuleNode, error) {
 		return nil, fmt.Errorf("%s: failed to build CST: %w", filePath, err)
 	}
 
-	// Now, build the AST
+	ast, err := buildASTFromCST(filePath, parseResult)
+	if err != nil {
+		return nil, err
+	}
+	if source, err := os.ReadFile(filePath); err == nil {
+		ast.Source = string(source)
+	}
+	return ast, nil
+}
+
+// buildASTFromCST builds the AST of a file from its CST, without its source
+func buildASTFromCST(filePath string, parseResult ParseResult) (*ModuleNode, error) {
 	var ast *ModuleNode
+	var err error
 	func() {
 		defer func() {
 			if r := recover(); r != nil {
@@ -197,7 +227,7 @@ func BuildAST(filePath string) (*ModuleNode, error) {
 	if err != nil {
 		return nil, err
 	}
-
+	ast.Comments = parseResult.Comments
 	return ast, nil
 }
 
@@ -12,23 +12,6 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-type ConfigFile struct {
-	Path          string
-	ProcessScopes []configlint.ProcessScope
-}
-
-func (c *ConfigFile) ToProto() *pb.ConfigFile {
-	protoConfig := &pb.ConfigFile{
-		Path: c.Path,
-	}
-
-	for _, scope := range c.ProcessScopes {
-		protoConfig.ProcessScopes = append(protoConfig.ProcessScopes, scope.ToProto())
-	}
-
-	return protoConfig
-}
-
 //export ConfigFile_New
 func ConfigFile_New(filePath *C.char) *C.char {
 	goPath := C.GoString(filePath)
@@ -51,9 +34,9 @@ func ConfigFile_New(filePath *C.char) *C.char {
 	}
 
 	// Parse the config
-	processScopes := configlint.ParseConfig(ast.StatementBlock)
+	processScopes := configlint.ParseConfigModule(ast)
 
-	config := &ConfigFile{
+	config := &configlint.ConfigFile{
 		Path:          goPath,
 		ProcessScopes: processScopes,
 	}
@@ -65,6 +48,21 @@ func ConfigFile_New(filePath *C.char) *C.char {
 	})
 }
 
+// returns 1 if the withName/withLabel pattern matches name, 0 if it doesn't
+// and -1 if the pattern is invalid
+//
+//export Selector_Match
+func Selector_Match(pattern *C.char, name *C.char) C.int {
+	selector, err := configlint.CompileSelector(C.GoString(pattern))
+	if err != nil {
+		return -1
+	}
+	if selector.Match(C.GoString(name)) {
+		return 1
+	}
+	return 0
+}
+
 //export ConfigFile_Free
 func ConfigFile_Free(ptr *C.char) {
 	C.free(unsafe.Pointer(ptr))
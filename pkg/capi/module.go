@@ -47,12 +47,13 @@ func Module_New(filePath *C.char) *C.char {
 type ProgressCallback func(int32, int32)
 
 type ModuleResult struct {
-	Module *nf.Module
-	Error  error
+	Result *pb.ModuleResult
 	Path   string
 }
 
-func ProcessDirectory(dir string, callback ProgressCallback) ([]ModuleResult, error) {
+// ProcessDirectory parses the .nf files under dir. With a cache, the
+// modules that didn't change since they were last parsed aren't parsed.
+func ProcessDirectory(dir string, cache *nf.ModuleCache, callback ProgressCallback) ([]ModuleResult, error) {
 	var results []ModuleResult
 	var wg sync.WaitGroup
 	var mu sync.Mutex
@@ -85,14 +86,17 @@ func ProcessDirectory(dir string, callback ProgressCallback) ([]ModuleResult, er
 			wg.Add(1)
 			go func(path string) {
 				defer wg.Done()
-				module, err, _ := nf.BuildModule(path)
+				var result *pb.ModuleResult
+				if cache != nil {
+					result = cache.ParseModule(path)
+				} else {
+					result = parseModule(path)
+				}
 
 				mu.Lock()
 				results = append(results, ModuleResult{
-					Module: module,
-					// TODO: add likely rt bug
-					Error: err,
-					Path:  path,
+					Result: result,
+					Path:   path,
 				})
 				processedFiles++
 				if callback != nil {
@@ -113,13 +117,48 @@ func ProcessDirectory(dir string, callback ProgressCallback) ([]ModuleResult, er
 	return results, nil
 }
 
+func parseModule(path string) *pb.ModuleResult {
+	module, err, likelyBug := nf.BuildModule(path)
+	result := &pb.ModuleResult{FilePath: path}
+	if err != nil {
+		result.Result = &pb.ModuleResult_Error{
+			Error: &pb.ParseError{
+				Error:       err.Error(),
+				LikelyRtBug: likelyBug,
+			},
+		}
+	} else {
+		result.Result = &pb.ModuleResult_Module{Module: module.ToProto()}
+	}
+	return result
+}
+
+// includesOf returns a module with the includes of a parsed module, which
+// is all ResolveIncludes looks at
+func includesOf(m *pb.Module) *nf.Module {
+	module := &nf.Module{Path: m.GetPath()}
+	for _, include := range m.GetIncludes() {
+		statement := nf.IncludeStatement{
+			ModulePath: include.GetFromModule(),
+			LineNumber: int(include.GetLine()),
+		}
+		for _, item := range include.GetItems() {
+			statement.Items = append(statement.Items, nf.IncludedItem{Name: item.GetName(), Alias: item.GetAlias()})
+		}
+		module.Includes = append(module.Includes, statement)
+	}
+	return module
+}
+
 var totalFiles int32
 var processedFiles int32
 
-// processes nextflow modules in a directory
+// processes nextflow modules in a directory. Modules are cached in
+// nf.CacheDir, keyed by cacheVersion, the version of the caller. An empty
+// cacheVersion parses every module.
 
 //export Parse_Modules
-func Parse_Modules(dir *C.char, callback unsafe.Pointer) *C.char {
+func Parse_Modules(dir *C.char, callback unsafe.Pointer, cacheVersion *C.char) *C.char {
 	goDir := C.GoString(dir)
 
 	var progressCallback ProgressCallback
@@ -129,7 +168,12 @@ func Parse_Modules(dir *C.char, callback unsafe.Pointer) *C.char {
 		}
 	}
 
-	results, err := ProcessDirectory(goDir, progressCallback)
+	var cache *nf.ModuleCache
+	if version := C.GoString(cacheVersion); version != "" {
+		cache = nf.NewModuleCache(version)
+	}
+
+	results, err := ProcessDirectory(goDir, cache, progressCallback)
 
 	listResult := &pb.ModuleListResult{}
 	if err != nil {
@@ -140,25 +184,10 @@ func Parse_Modules(dir *C.char, callback unsafe.Pointer) *C.char {
 	var modules []*nf.Module
 
 	for _, res := range results {
-		moduleResult := &pb.ModuleResult{
-			FilePath: res.Path,
-		}
-
-		if res.Error != nil {
-			moduleResult.Result = &pb.ModuleResult_Error{
-				Error: &pb.ParseError{
-					Error:       res.Error.Error(),
-					LikelyRtBug: false,
-				},
-			}
-		} else {
-			modules = append(modules, res.Module)
-			moduleResult.Result = &pb.ModuleResult_Module{
-				Module: res.Module.ToProto(),
-			}
+		if module := res.Result.GetModule(); module != nil {
+			modules = append(modules, includesOf(module))
 		}
-
-		listResult.Results = append(listResult.Results, moduleResult)
+		listResult.Results = append(listResult.Results, res.Result)
 	}
 
 	resolvedIncludes, unresolvedIncludes := nf.ResolveIncludes(modules)
@@ -1,7 +1,7 @@
 import os
 import sys
 import ctypes
-from ctypes import c_char_p, c_void_p
+from ctypes import c_char_p, c_void_p, c_int
 
 def load_library():
     _lib_dir = os.path.dirname(os.path.abspath(__file__))
@@ -39,5 +39,8 @@ _lib.ConfigFile_New.restype = c_void_p
 _lib.ConfigFile_Free.argtypes = [c_void_p]
 _lib.ConfigFile_Free.restype = None
 
-_lib.Parse_Modules.argtypes = [c_char_p, c_void_p]
+_lib.Selector_Match.argtypes = [c_char_p, c_char_p]
+_lib.Selector_Match.restype = c_int
+
+_lib.Parse_Modules.argtypes = [c_char_p, c_void_p, c_char_p]
 _lib.Parse_Modules.restype = c_void_p
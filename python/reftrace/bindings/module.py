@@ -7,6 +7,7 @@ from dataclasses import dataclass
 from functools import cached_property
 from .process import Process
 import json
+from importlib.metadata import version, PackageNotFoundError
 
 @dataclass
 class ResolvedInclude:
@@ -226,13 +227,23 @@ class ModuleListResult:
     resolved_includes: List[ResolvedInclude]
     unresolved_includes: List[UnresolvedInclude]
        
-def parse_modules(directory, progress_callback=None) -> ModuleListResult:
+def _cache_version() -> str:
+    """The version parsed modules are cached under, empty to not cache them."""
+    try:
+        return version("reftrace")
+    except PackageNotFoundError:
+        return ""
+
+def parse_modules(directory, progress_callback=None, use_cache=True) -> ModuleListResult:
     """
     Parse all Nextflow modules in a directory.
     
     Args:
         directory (str): Path to directory containing .nf files
         progress_callback (callable): Optional callback function(current, total)
+        use_cache (bool): Reuse the modules cached in reftrace/modules under the
+            user cache directory, or under $REFTRACE_CACHE_DIR, that didn't
+            change since they were parsed
     
     Returns:
         ModuleListResult: List of results, each containing either a Module or ParseError
@@ -243,9 +254,11 @@ def parse_modules(directory, progress_callback=None) -> ModuleListResult:
         CALLBACK_TYPE = ctypes.CFUNCTYPE(None, ctypes.c_int32, ctypes.c_int32)
         callback_ptr = CALLBACK_TYPE(progress_callback)
     
+    cache_version = _cache_version() if use_cache else ""
     result_ptr = _lib.Parse_Modules(
         directory.encode('utf-8'),
-        callback_ptr
+        callback_ptr,
+        cache_version.encode('utf-8')
     )
     
     if not result_ptr:
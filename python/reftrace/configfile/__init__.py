@@ -1,6 +1,54 @@
 from dataclasses import dataclass
+from typing import Any, Optional
 from ..proto import config_file_pb2
 
+@dataclass(frozen=True)
+class Memory:
+    """A memory amount such as 8.GB."""
+    bytes: float
+    text: str
+
+@dataclass(frozen=True)
+class Duration:
+    """A duration such as 2.h."""
+    millis: float
+    text: str
+
+@dataclass(frozen=True)
+class Closure:
+    """A closure whose value is only known at runtime."""
+    source: str
+
+@dataclass(frozen=True)
+class Param:
+    """A reference to a pipeline parameter, e.g. params.outdir."""
+    name: str
+
+@dataclass(frozen=True)
+class Expression:
+    """An expression that can't be evaluated statically."""
+    source: str
+
+def _typed_value(value: config_file_pb2.ConfigValue) -> Any:
+    kind = value.WhichOneof("value")
+    if kind is None or kind == "null_value":
+        return None
+    if kind == "memory":
+        return Memory(value.memory.bytes, value.memory.text)
+    if kind == "duration":
+        return Duration(value.duration.millis, value.duration.text)
+    if kind == "list":
+        return [_typed_value(item) for item in value.list.items]
+    if kind == "map":
+        return {entry.key: _typed_value(entry.value) for entry in value.map.entries}
+    if kind == "closure":
+        return Closure(value.closure.source)
+    if kind == "param":
+        return Param(value.param)
+    if kind == "expression":
+        return Expression(value.expression)
+    return getattr(value, kind)
+
 @dataclass(frozen=True)
 class Value:
     """Represents a directive value with parameters and closure information."""
@@ -16,6 +64,11 @@ class Value:
         """Whether the directive is in a closure."""
         return self._value.in_closure
 
+    @property
+    def typed(self) -> Any:
+        """The typed value: None, str, float, bool, list, dict, Memory, Duration, Closure, Param or Expression."""
+        return _typed_value(self._value.value)
+
 @dataclass(frozen=True)
 class NamedOption:
     """Represents a named option with its value."""
@@ -76,6 +129,24 @@ class NamedScope:
         """The scope name."""
         return self._value.name
 
+    @property
+    def kind(self) -> str:
+        """The selector kind, either 'withName' or 'withLabel'."""
+        return self._value.kind
+
+    @property
+    def pattern_error(self) -> Optional[str]:
+        """Why the name is not a valid regular expression, if it isn't."""
+        return self._value.pattern_error or None
+
+    def matches(self, name: str) -> bool:
+        """Whether the selector applies to a process name or label, using Nextflow's (Java) regex semantics."""
+        from ..bindings.lib import _lib
+        result = _lib.Selector_Match(self._value.name.encode('utf-8'), name.encode('utf-8'))
+        if result < 0:
+            raise ValueError(f"invalid selector '{self._value.name}': {self.pattern_error}")
+        return result == 1
+
     @property
     def directives(self) -> list[Directive]:
         """The directives in this scope."""
@@ -107,4 +178,9 @@ __all__ = [
     'Directive',
     'NamedOption',
     'Value',
+    'Memory',
+    'Duration',
+    'Closure',
+    'Param',
+    'Expression',
 ]
\ No newline at end of file